@@ -0,0 +1,82 @@
+// Package arena stores values of one type in chunks, so that the many small
+// nodes of a compilation take few allocations and little work from the
+// garbage collector
+package arena
+
+// Chunks start small for fragments and grow up to maxChunk values
+const (
+	minChunk = 32
+	maxChunk = 1024
+)
+
+// chunks hands out runs of values from a list of chunks, which are reused
+// after a reset
+type chunks[T any] struct {
+	chunks [][]T
+	index  int // number of chunks in use, the last of which is being filled
+	used   int // values taken from the last chunk in use
+}
+
+// take returns n zeroed values that share no memory with earlier ones
+func (c *chunks[T]) take(n int) []T {
+	if n > maxChunk {
+		return make([]T, n)
+	}
+	for c.index == 0 || c.used+n > len(c.chunks[c.index-1]) {
+		if c.index == len(c.chunks) {
+			c.chunks = append(c.chunks, make([]T, min(minChunk<<min(len(c.chunks), 5), maxChunk)))
+		}
+		c.index++
+		c.used = 0
+	}
+	values := c.chunks[c.index-1][c.used : c.used+n : c.used+n]
+	c.used += n
+	return values
+}
+
+func (c *chunks[T]) reset() {
+	for _, chunk := range c.chunks[:c.index] {
+		clear(chunk)
+	}
+	c.index, c.used = 0, 0
+}
+
+// Slab stores single values of type T. The zero value is ready to use. A
+// chunk stays alive as long as any of its values is referenced.
+type Slab[T any] struct {
+	chunks chunks[T]
+}
+
+// New stores v in the slab and returns a pointer to it
+func (s *Slab[T]) New(v T) *T {
+	p := &s.chunks.take(1)[0]
+	*p = v
+	return p
+}
+
+// Reset makes the chunks available again for a later compilation. Values
+// stored before must no longer be used.
+func (s *Slab[T]) Reset() {
+	s.chunks.reset()
+}
+
+// Slices stores slices of T, such as the children of nodes, like Slab
+type Slices[T any] struct {
+	chunks chunks[T]
+}
+
+// Copy stores a copy of items and returns it, or nil if items is empty.
+// Appending to the copy moves it rather than overwriting other slices.
+func (s *Slices[T]) Copy(items []T) []T {
+	if len(items) == 0 {
+		return nil
+	}
+	copied := s.chunks.take(len(items))
+	copy(copied, items)
+	return copied
+}
+
+// Reset makes the chunks available again like Slab.Reset
+func (s *Slices[T]) Reset() {
+	s.chunks.reset()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"compiler/config"
+	"compiler/diag"
+	"compiler/emit"
+	"compiler/parser"
+	"compiler/report"
+	"compiler/sourcefile"
+)
+
+// writeReports writes the error files, the listing and, if requested, the
+// HTML report. pars is nil if parsing was skipped.
+func writeReports(diagnostics []diag.Diagnostic, pars *parser.Parser) error {
+	source, err := sourcefile.Text(config.SOURCE_PATH)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{
+		writeErrors(diagnostics),
+		emit.File(config.LST_PATH, func(w io.Writer) error {
+			return diag.ListingSink{Source: source, TabWidth: config.TabWidth}.Write(w, diagnostics)
+		}),
+	}
+	if config.Report == "html" {
+		data := report.Data{SourcePath: config.SOURCE_PATH, Source: source, Diagnostics: diagnostics}
+		if pars != nil {
+			data.Variables, data.Procedures = pars.Variables(), pars.Procedures()
+		}
+		errs = append(errs, emit.File(config.HTML_PATH, func(w io.Writer) error {
+			return report.WriteHTML(w, data)
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// writeErrors writes the diagnostics of each phase to its own file and the
+// merged report of all phases to output.err. Every file is rewritten on each
+// run, so no phase can leave stale or clobbered errors behind.
+func writeErrors(diagnostics []diag.Diagnostic) error {
+	write := func(path string, list []diag.Diagnostic) error {
+		return emit.File(path, func(w io.Writer) error {
+			return emit.Text{}.EmitErrors(w, list)
+		})
+	}
+	return errors.Join(
+		write(config.ERR_PATH, diagnostics),
+		write(config.LEX_ERR_PATH, diag.Filter(diagnostics, diag.LexerPhase)),
+		write(config.PAR_ERR_PATH, diag.Filter(diagnostics, diag.ParserPhase)),
+	)
+}
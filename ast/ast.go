@@ -0,0 +1,204 @@
+// Package ast defines the abstract syntax tree of a program, lowered from
+// the concrete syntax tree of the parser
+package ast
+
+// Pos is the position of a node in the source
+type Pos struct {
+	File string `json:"file,omitempty"` // included file, empty for the compiled source
+	Line int    `json:"line"`
+}
+
+// Position returns the position itself, so that every node embedding a Pos
+// implements Node
+func (p Pos) Position() Pos {
+	return p
+}
+
+// Node is any node of the tree
+type Node interface {
+	Position() Pos
+}
+
+// Decl is a declaration
+type Decl interface {
+	Node
+	declNode()
+}
+
+// Stmt is an execution statement
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an arithmetic expression or a condition
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Program is the root of the tree
+type Program struct {
+	Pos
+	Body *Block `json:"body"`
+}
+
+// Block is the body of the program or of a procedure
+type Block struct {
+	Pos
+	Declarations []Decl `json:"declarations"`
+	Statements   []Stmt `json:"statements"`
+}
+
+// Declarations
+type (
+	// VarDecl declares a variable, or an array if Size is positive
+	VarDecl struct {
+		Pos
+		Name string `json:"name"`
+		Size int    `json:"size,omitempty"`
+	}
+
+	// ProcDecl declares a function, whose Result is "integer", or a
+	// procedure, whose Result is "void". A forward declaration has no body.
+	ProcDecl struct {
+		Pos
+		Name        string `json:"name"`
+		Result      string `json:"result"`
+		Param       string `json:"param"`
+		ByReference bool   `json:"byReference,omitempty"`
+		Body        *Block `json:"body,omitempty"`
+	}
+)
+
+// Statements
+type (
+	ReadStmt struct {
+		Pos
+		Targets []*VarRef `json:"targets"`
+	}
+
+	// WriteStmt writes its arguments, which may include strings, and ends
+	// the line if it is a writeln
+	WriteStmt struct {
+		Pos
+		Newline bool   `json:"newline,omitempty"`
+		Args    []Expr `json:"args"`
+	}
+
+	// AssignStmt assigns a variable, an array element or the result of the
+	// enclosing function
+	AssignStmt struct {
+		Pos
+		Target *VarRef `json:"target"`
+		Value  Expr    `json:"value"`
+	}
+
+	CallStmt struct {
+		Pos
+		Call *CallExpr `json:"call"`
+	}
+
+	IfStmt struct {
+		Pos
+		Cond Expr `json:"cond"`
+		Then Stmt `json:"then"`
+		Else Stmt `json:"else"`
+	}
+
+	CaseStmt struct {
+		Pos
+		Subject  Expr          `json:"subject"`
+		Branches []*CaseBranch `json:"branches"`
+		Else     Stmt          `json:"else,omitempty"`
+	}
+
+	WhileStmt struct {
+		Pos
+		Cond Expr `json:"cond"`
+		Body Stmt `json:"body"`
+	}
+
+	RepeatStmt struct {
+		Pos
+		Body []Stmt `json:"body"`
+		Cond Expr   `json:"cond"`
+	}
+
+	ForStmt struct {
+		Pos
+		Counter *VarRef `json:"counter"`
+		From    Expr    `json:"from"`
+		To      Expr    `json:"to"`
+		Body    Stmt    `json:"body"`
+	}
+)
+
+// CaseBranch is a branch of a case statement with its constant labels
+type CaseBranch struct {
+	Pos
+	Labels []int `json:"labels"`
+	Body   Stmt  `json:"body"`
+}
+
+// Expressions
+type (
+	// BinaryExpr applies an arithmetic operator (-, *, mod, div) or a
+	// relational one (=, <>, <, <=, >, >=)
+	BinaryExpr struct {
+		Pos
+		Op    string `json:"op"`
+		Left  Expr   `json:"left"`
+		Right Expr   `json:"right"`
+	}
+
+	UnaryExpr struct {
+		Pos
+		Op      string `json:"op"`
+		Operand Expr   `json:"operand"`
+	}
+
+	IntLit struct {
+		Pos
+		Value int `json:"value"`
+	}
+
+	// StringLit is a string argument of write, without its quotes
+	StringLit struct {
+		Pos
+		Value string `json:"value"`
+	}
+
+	// VarRef names a variable, or an element of an array if Index is set
+	VarRef struct {
+		Pos
+		Name  string `json:"name"`
+		Index Expr   `json:"index,omitempty"`
+	}
+
+	CallExpr struct {
+		Pos
+		Name string `json:"name"`
+		Arg  Expr   `json:"arg"`
+	}
+)
+
+func (*VarDecl) declNode()  {}
+func (*ProcDecl) declNode() {}
+
+func (*ReadStmt) stmtNode()   {}
+func (*WriteStmt) stmtNode()  {}
+func (*AssignStmt) stmtNode() {}
+func (*CallStmt) stmtNode()   {}
+func (*IfStmt) stmtNode()     {}
+func (*CaseStmt) stmtNode()   {}
+func (*WhileStmt) stmtNode()  {}
+func (*RepeatStmt) stmtNode() {}
+func (*ForStmt) stmtNode()    {}
+
+func (*BinaryExpr) exprNode() {}
+func (*UnaryExpr) exprNode()  {}
+func (*IntLit) exprNode()     {}
+func (*StringLit) exprNode()  {}
+func (*VarRef) exprNode()     {}
+func (*CallExpr) exprNode()   {}
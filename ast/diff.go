@@ -0,0 +1,253 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Difference is a place where two trees differ: a node of the first tree
+// changed into one of the second, or a node only one of them has, with the
+// other left nil
+type Difference struct {
+	A, B Node
+}
+
+// Diff compares two trees by their structure, ignoring positions. The
+// children of matching nodes are aligned on their longest common
+// subsequence, so that an inserted statement shows up as one difference
+// rather than as a change of every statement after it. With ignoreNames,
+// names may differ as long as the names declared in each block correspond
+// one to one, and every name refers to the counterpart of the declaration
+// it refers to in the other tree.
+func Diff(a, b Node, ignoreNames bool) []Difference {
+	d := differ{ignoreNames: ignoreNames, prints: make(map[Node]string)}
+	d.push() // names declared in the program, or not at all
+	d.compare(a, b)
+	return d.differences
+}
+
+// Fingerprint returns the tree below n as a string of the kinds and
+// contents of its nodes, without positions. With names false, names are
+// left out, so that trees differing only in their names have the same one.
+func Fingerprint(n Node, names bool) string {
+	d := differ{ignoreNames: !names, prints: make(map[Node]string)}
+	return d.fingerprint(n)
+}
+
+// Labels returns the kinds and contents of the nodes below n in depth first
+// order, with or without their names, as a sequence for comparing trees
+// that may differ in places
+func Labels(n Node, names bool) []string {
+	var labels []string
+	Walk(n, Funcs{EnterFunc: func(n Node) bool {
+		labels = append(labels, label(n, names))
+		return true
+	}})
+	return labels
+}
+
+type differ struct {
+	ignoreNames bool
+	prints      map[Node]string // fingerprints computed so far
+	scopes      []nameScope     // of the procedures being compared
+	differences []Difference
+}
+
+// nameScope holds the names declared in a block of each tree, each mapped
+// to its counterpart in the other
+type nameScope struct {
+	aToB, bToA map[string]string
+}
+
+func (d *differ) push() {
+	d.scopes = append(d.scopes, nameScope{make(map[string]string), make(map[string]string)})
+}
+
+func (d *differ) compare(a, b Node) {
+	if label(a, !d.ignoreNames) != label(b, !d.ignoreNames) || !d.sameNames(a, b) {
+		d.differences = append(d.differences, Difference{a, b})
+		return
+	}
+	if pa, ok := a.(*ProcDecl); ok && d.ignoreNames {
+		// The parameter is declared in the body, again by its declaration there
+		d.push()
+		defer func() { d.scopes = d.scopes[:len(d.scopes)-1] }()
+		if pa.Param != "" && !d.declare(pa.Param, b.(*ProcDecl).Param) {
+			d.differences = append(d.differences, Difference{a, b})
+			return
+		}
+	}
+
+	ca, cb := Children(a), Children(b)
+	pairs := d.align(ca, cb)
+	i, j := 0, 0
+	for _, p := range append(pairs, [2]int{len(ca), len(cb)}) {
+		// Between two aligned children, the ones left are changed in order,
+		// and the rest of the longer run removed or added
+		for ; i < p[0] && j < p[1]; i, j = i+1, j+1 {
+			d.compare(ca[i], cb[j])
+		}
+		for ; i < p[0]; i++ {
+			d.differences = append(d.differences, Difference{ca[i], nil})
+		}
+		for ; j < p[1]; j++ {
+			d.differences = append(d.differences, Difference{nil, cb[j]})
+		}
+		if p[0] < len(ca) {
+			d.compare(ca[i], cb[j])
+			i, j = i+1, j+1
+		}
+	}
+}
+
+// align returns the indexes of the longest common subsequence of two lists
+// of children, compared by their fingerprints
+func (d *differ) align(a, b []Node) [][2]int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if d.fingerprint(a[i]) == d.fingerprint(b[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case d.fingerprint(a[i]) == d.fingerprint(b[j]):
+			pairs = append(pairs, [2]int{i, j})
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+func (d *differ) fingerprint(n Node) string {
+	if s, ok := d.prints[n]; ok {
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteString(label(n, !d.ignoreNames))
+	if children := Children(n); len(children) > 0 {
+		sb.WriteString("(")
+		for i, c := range children {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(d.fingerprint(c))
+		}
+		sb.WriteString(")")
+	}
+	d.prints[n] = sb.String()
+	return sb.String()
+}
+
+// sameNames reports whether the names of a and b correspond to each other,
+// declaring those of declarations
+func (d *differ) sameNames(a, b Node) bool {
+	if !d.ignoreNames {
+		return true
+	}
+	switch a := a.(type) {
+	case *VarDecl:
+		return d.declare(a.Name, b.(*VarDecl).Name)
+	case *ProcDecl:
+		return d.declare(a.Name, b.(*ProcDecl).Name)
+	case *VarRef:
+		return d.refer(a.Name, b.(*VarRef).Name)
+	case *CallExpr:
+		return d.refer(a.Name, b.(*CallExpr).Name)
+	}
+	return true
+}
+
+// declare pairs a name declared in a with one declared in b in the current
+// block. A name may be declared again, as a function defined after its
+// forward declaration is, if it is paired the same way.
+func (d *differ) declare(x, y string) bool {
+	scope := d.scopes[len(d.scopes)-1]
+	if to, ok := scope.aToB[x]; ok && to != y {
+		return false
+	}
+	if from, ok := scope.bToA[y]; ok && from != x {
+		return false
+	}
+	scope.aToB[x], scope.bToA[y] = y, x
+	return true
+}
+
+// refer reports whether x and y refer to paired declarations. Undeclared
+// names are paired like names of the program.
+func (d *differ) refer(x, y string) bool {
+	for i := len(d.scopes) - 1; i >= 0; i-- {
+		to, declaredA := d.scopes[i].aToB[x]
+		from, declaredB := d.scopes[i].bToA[y]
+		if declaredA || declaredB {
+			return to == y && from == x
+		}
+	}
+	d.scopes[0].aToB[x], d.scopes[0].bToA[y] = y, x
+	return true
+}
+
+// names returns the names a node declares or refers to, with "" for a
+// missing parameter
+func names(n Node) []string {
+	switch n := n.(type) {
+	case *VarDecl:
+		return []string{n.Name}
+	case *ProcDecl:
+		return []string{n.Name, n.Param}
+	case *VarRef:
+		return []string{n.Name}
+	case *CallExpr:
+		return []string{n.Name}
+	}
+	return nil
+}
+
+// label returns the kind of a node and its contents other than children,
+// with or without its names
+func label(n Node, withNames bool) string {
+	kind := reflect.TypeOf(n).Elem().Name()
+	var contents []any
+	switch n := n.(type) {
+	case *VarDecl:
+		contents = []any{n.Size}
+	case *ProcDecl:
+		contents = []any{n.Result, n.ByReference, n.Body == nil}
+	case *WriteStmt:
+		contents = []any{n.Newline}
+	case *CaseBranch:
+		contents = []any{n.Labels}
+	case *BinaryExpr:
+		contents = []any{n.Op}
+	case *UnaryExpr:
+		contents = []any{n.Op}
+	case *IntLit:
+		contents = []any{n.Value}
+	case *StringLit:
+		contents = []any{n.Value}
+	case *VarRef:
+		contents = []any{n.Index != nil}
+	}
+	if withNames {
+		for _, name := range names(n) {
+			contents = append(contents, name)
+		}
+	}
+	if len(contents) == 0 {
+		return kind
+	}
+	return kind + fmt.Sprint(contents)
+}
@@ -0,0 +1,216 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// kinds maps the "kind" written for each node to its type
+var kinds = map[string]reflect.Type{}
+
+func init() {
+	for _, n := range []Node{
+		&Program{}, &Block{}, &VarDecl{}, &ProcDecl{},
+		&ReadStmt{}, &WriteStmt{}, &AssignStmt{}, &CallStmt{}, &IfStmt{}, &CaseStmt{}, &CaseBranch{}, &WhileStmt{}, &RepeatStmt{}, &ForStmt{},
+		&BinaryExpr{}, &UnaryExpr{}, &IntLit{}, &StringLit{}, &VarRef{}, &CallExpr{},
+	} {
+		t := reflect.TypeOf(n).Elem()
+		kinds[t.Name()] = t
+	}
+}
+
+var posType = reflect.TypeOf(Pos{})
+
+// WriteJSON writes the tree as indented JSON. Every node is an object whose
+// "kind" names its type, followed by its position and its fields in the
+// order of the Go declaration, so that tools in other languages can read
+// the tree without guessing.
+func WriteJSON(w io.Writer, prog *Program) error {
+	data, err := json.MarshalIndent(encode(reflect.ValueOf(prog)), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// ReadJSON reads a tree written by WriteJSON
+func ReadJSON(r io.Reader) (*Program, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("ast: %w", err)
+	}
+	prog, err := decode(value, reflect.TypeOf(&Program{}), "program")
+	if err != nil {
+		return nil, err
+	}
+	return prog.Interface().(*Program), nil
+}
+
+// object is a JSON object that keeps its keys in order
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func encode(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return encode(v.Elem())
+	case reflect.Slice:
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = encode(v.Index(i))
+		}
+		return list
+	case reflect.Struct:
+		o := object{{"kind", v.Type().Name()}}
+		for i := 0; i < v.NumField(); i++ {
+			field, value := v.Type().Field(i), v.Field(i)
+			if field.Type == posType {
+				pos := value.Interface().(Pos)
+				if pos.File != "" {
+					o = append(o, member{"file", pos.File})
+				}
+				o = append(o, member{"line", pos.Line})
+				continue
+			}
+			name, omitEmpty := jsonName(field)
+			if omitEmpty && value.IsZero() {
+				continue
+			}
+			o = append(o, member{name, encode(value)})
+		}
+		return o
+	}
+	return v.Interface()
+}
+
+func jsonName(field reflect.StructField) (name string, omitEmpty bool) {
+	name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name, options == "omitempty"
+}
+
+// decode builds a value of type t from the generic JSON value, naming the
+// path to it in errors
+func decode(value any, t reflect.Type, path string) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Zero(t), nil
+	}
+	fail := func(format string, args ...any) (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("ast: %s: %s", path, fmt.Sprintf(format, args...))
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return fail("expected an object")
+		}
+		kind, _ := fields["kind"].(string)
+		concrete, ok := kinds[kind]
+		if !ok || !reflect.PointerTo(concrete).Implements(t) {
+			return fail("unexpected kind '%s'", kind)
+		}
+		return decode(value, reflect.PointerTo(concrete), path)
+
+	case reflect.Pointer:
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return fail("expected an object")
+		}
+		if kind, ok := fields["kind"].(string); ok && kind != t.Elem().Name() {
+			return fail("expected kind '%s', but got '%s'", t.Elem().Name(), kind)
+		}
+		node := reflect.New(t.Elem())
+		for i := 0; i < t.Elem().NumField(); i++ {
+			field := t.Elem().Field(i)
+			if field.Type == posType {
+				file, _ := fields["file"].(string)
+				line, err := decode(fields["line"], reflect.TypeOf(0), path+".line")
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				node.Elem().Field(i).Set(reflect.ValueOf(Pos{File: file, Line: int(line.Int())}))
+				continue
+			}
+			name, _ := jsonName(field)
+			v, err := decode(fields[name], field.Type, path+"."+name)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			node.Elem().Field(i).Set(v)
+		}
+		return node, nil
+
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			return fail("expected an array")
+		}
+		if len(items) == 0 {
+			return reflect.Zero(t), nil // as lowering leaves an empty list, such as the arguments of a bare writeln
+		}
+		list := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			v, err := decode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			list.Index(i).Set(v)
+		}
+		return list, nil
+
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			return reflect.ValueOf(s), nil
+		}
+		return fail("expected a string")
+
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			return reflect.ValueOf(b), nil
+		}
+		return fail("expected true or false")
+
+	case reflect.Int:
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return reflect.ValueOf(int(i)), nil
+			}
+		}
+		return fail("expected an integer")
+	}
+	return fail("unsupported type %s", t)
+}
@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Summary returns a node in the syntax of the language on one line. An
+// expression is written in full; of a statement or declaration only its
+// head is, with the statements inside it written as "...".
+func Summary(n Node) string {
+	switch n := n.(type) {
+	case *Program:
+		return "program"
+	case *Block:
+		return "begin ... end"
+	case *VarDecl:
+		if n.Size > 0 {
+			return fmt.Sprintf("integer %s[%d]", n.Name, n.Size)
+		}
+		return "integer " + n.Name
+	case *ProcDecl:
+		head := "procedure "
+		if n.Result != "void" {
+			head = n.Result + " function "
+		}
+		param := n.Param
+		if n.ByReference {
+			param = "var " + param
+		}
+		head += n.Name + "(" + param + ")"
+		if n.Body == nil {
+			head += "; forward"
+		}
+		return head
+	case *ReadStmt:
+		targets := make([]string, len(n.Targets))
+		for i, t := range n.Targets {
+			targets[i] = Summary(t)
+		}
+		return "read(" + strings.Join(targets, ", ") + ")"
+	case *WriteStmt:
+		args := make([]string, len(n.Args))
+		for i, a := range n.Args {
+			args[i] = Summary(a)
+		}
+		if n.Newline {
+			if len(args) == 0 {
+				return "writeln"
+			}
+			return "writeln(" + strings.Join(args, ", ") + ")"
+		}
+		return "write(" + strings.Join(args, ", ") + ")"
+	case *AssignStmt:
+		return Summary(n.Target) + " := " + Summary(n.Value)
+	case *CallStmt:
+		return Summary(n.Call)
+	case *IfStmt:
+		if n.Else == nil {
+			return "if " + Summary(n.Cond) + " then ..."
+		}
+		return "if " + Summary(n.Cond) + " then ... else ..."
+	case *CaseStmt:
+		return "case " + Summary(n.Subject) + " of ... end"
+	case *CaseBranch:
+		labels := make([]string, len(n.Labels))
+		for i, label := range n.Labels {
+			labels[i] = strconv.Itoa(label)
+		}
+		return strings.Join(labels, ", ") + ": ..."
+	case *WhileStmt:
+		return "while " + Summary(n.Cond) + " do ..."
+	case *RepeatStmt:
+		return "repeat ... until " + Summary(n.Cond)
+	case *ForStmt:
+		return "for " + Summary(n.Counter) + " := " + Summary(n.From) + " to " + Summary(n.To) + " do ..."
+	case *BinaryExpr:
+		return Summary(n.Left) + " " + n.Op + " " + Summary(n.Right)
+	case *UnaryExpr:
+		return n.Op + Summary(n.Operand)
+	case *IntLit:
+		return strconv.Itoa(n.Value)
+	case *StringLit:
+		return "'" + strings.ReplaceAll(n.Value, "'", "''") + "'"
+	case *VarRef:
+		if n.Index != nil {
+			return n.Name + "[" + Summary(n.Index) + "]"
+		}
+		return n.Name
+	case *CallExpr:
+		return n.Name + "(" + Summary(n.Arg) + ")"
+	}
+	return ""
+}
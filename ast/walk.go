@@ -0,0 +1,136 @@
+package ast
+
+// Visitor is called by Walk on every node of a tree. Enter is called before
+// the children of a node and may return false to skip them; Leave is called
+// after them, also when they were skipped.
+type Visitor interface {
+	Enter(n Node) bool
+	Leave(n Node)
+}
+
+// Funcs turns a pair of functions into a Visitor; either may be nil
+type Funcs struct {
+	EnterFunc func(n Node) bool
+	LeaveFunc func(n Node)
+}
+
+func (f Funcs) Enter(n Node) bool {
+	return f.EnterFunc == nil || f.EnterFunc(n)
+}
+
+func (f Funcs) Leave(n Node) {
+	if f.LeaveFunc != nil {
+		f.LeaveFunc(n)
+	}
+}
+
+// Walk traverses the tree below node depth first, visiting the children of
+// each node in source order
+func Walk(node Node, v Visitor) {
+	if isNil(node) {
+		return
+	}
+	if v.Enter(node) {
+		for _, child := range Children(node) {
+			Walk(child, v)
+		}
+	}
+	v.Leave(node)
+}
+
+// Inspect calls f on every node of type T below root in depth first order.
+// If f returns false, the children of that node are skipped.
+func Inspect[T Node](root Node, f func(T) bool) {
+	Walk(root, Funcs{EnterFunc: func(n Node) bool {
+		if t, ok := n.(T); ok {
+			return f(t)
+		}
+		return true
+	}})
+}
+
+// Children returns the direct children of a node in source order
+func Children(node Node) []Node {
+	var children []Node
+	add := func(nodes ...Node) {
+		for _, n := range nodes {
+			if !isNil(n) {
+				children = append(children, n)
+			}
+		}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		add(n.Body)
+	case *Block:
+		for _, d := range n.Declarations {
+			add(d)
+		}
+		for _, s := range n.Statements {
+			add(s)
+		}
+	case *ProcDecl:
+		add(n.Body)
+	case *ReadStmt:
+		for _, t := range n.Targets {
+			add(t)
+		}
+	case *WriteStmt:
+		for _, a := range n.Args {
+			add(a)
+		}
+	case *AssignStmt:
+		add(n.Target, n.Value)
+	case *CallStmt:
+		add(n.Call)
+	case *IfStmt:
+		add(n.Cond, n.Then, n.Else)
+	case *CaseStmt:
+		add(n.Subject)
+		for _, b := range n.Branches {
+			add(b)
+		}
+		add(n.Else)
+	case *CaseBranch:
+		add(n.Body)
+	case *WhileStmt:
+		add(n.Cond, n.Body)
+	case *RepeatStmt:
+		for _, s := range n.Body {
+			add(s)
+		}
+		add(n.Cond)
+	case *ForStmt:
+		add(n.Counter, n.From, n.To, n.Body)
+	case *BinaryExpr:
+		add(n.Left, n.Right)
+	case *UnaryExpr:
+		add(n.Operand)
+	case *VarRef:
+		add(n.Index)
+	case *CallExpr:
+		add(n.Arg)
+	}
+	return children
+}
+
+// isNil reports whether n is nil or a nil pointer stored in the interface,
+// as left by optional fields such as IfStmt.Else
+func isNil(n Node) bool {
+	switch n := n.(type) {
+	case nil:
+		return true
+	case *Program:
+		return n == nil
+	case *Block:
+		return n == nil
+	case *VarRef:
+		return n == nil
+	case *CallExpr:
+		return n == nil
+	case *CaseBranch:
+		return n == nil
+	}
+	return false
+}
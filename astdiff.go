@@ -0,0 +1,134 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"compiler/ast"
+	"compiler/config"
+)
+
+// astdiff prints where two programs differ in their abstract syntax trees,
+// so that layout and, with --ignore-names, consistent renaming are not
+// differences
+func astdiff(args []string) int {
+	flags := flag.NewFlagSet("astdiff", flag.ContinueOnError)
+	ignoreNames := flags.Bool("ignore-names", false, "treat programs that differ only in the names of their symbols as equal")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: astdiff [--ignore-names] a.pas b.pas")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		if err == nil {
+			flags.Usage()
+		}
+		return EXIT_IO
+	}
+	pathA, pathB := flags.Arg(0), flags.Arg(1)
+	a, err := openTree(pathA)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	b, err := openTree(pathB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+
+	differences := ast.Diff(a, b, *ignoreNames)
+	if config.Format == "json" {
+		err = writeDifferencesJSON(os.Stdout, differences, pathA, pathB)
+	} else {
+		err = writeDifferences(os.Stdout, differences, pathA, pathB)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	return EXIT_SUCCESS
+}
+
+// openTree parses the file at path into its abstract syntax tree
+func openTree(path string) (*ast.Program, error) {
+	doc, err := openDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	if doc.AST() == nil {
+		return nil, fmt.Errorf("%s has syntax errors", path)
+	}
+	return doc.AST(), nil
+}
+
+// writeDifferences writes a difference per line as "position: change"
+func writeDifferences(w io.Writer, differences []ast.Difference, pathA, pathB string) error {
+	if len(differences) == 0 {
+		_, err := fmt.Fprintln(w, "no differences")
+		return err
+	}
+	for _, d := range differences {
+		var err error
+		switch {
+		case d.B == nil:
+			_, err = fmt.Fprintf(w, "%s: removed %s\n", nodePosition(d.A, pathA), ast.Summary(d.A))
+		case d.A == nil:
+			_, err = fmt.Fprintf(w, "%s: added %s\n", nodePosition(d.B, pathB), ast.Summary(d.B))
+		default:
+			_, err = fmt.Fprintf(w, "%s -> %s: changed %s to %s\n",
+				nodePosition(d.A, pathA), nodePosition(d.B, pathB), ast.Summary(d.A), ast.Summary(d.B))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonDifference is a difference in the JSON output of astdiff, with the
+// side a node is missing from left out
+type jsonDifference struct {
+	Change string    `json:"change"` // "changed", "removed" or "added"
+	A      *jsonNode `json:"a,omitempty"`
+	B      *jsonNode `json:"b,omitempty"`
+}
+
+type jsonNode struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+func writeDifferencesJSON(w io.Writer, differences []ast.Difference, pathA, pathB string) error {
+	side := func(n ast.Node, path string) *jsonNode {
+		if n == nil {
+			return nil
+		}
+		pos := n.Position()
+		return &jsonNode{cmp.Or(pos.File, path), pos.Line, ast.Summary(n)}
+	}
+	list := make([]jsonDifference, len(differences))
+	for i, d := range differences {
+		change := "changed"
+		switch {
+		case d.B == nil:
+			change = "removed"
+		case d.A == nil:
+			change = "added"
+		}
+		list[i] = jsonDifference{change, side(d.A, pathA), side(d.B, pathB)}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
+
+// nodePosition returns the file and line of a node of the program at path
+func nodePosition(n ast.Node, path string) string {
+	pos := n.Position()
+	return fmt.Sprintf("%s:%d", cmp.Or(pos.File, path), pos.Line)
+}
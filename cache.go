@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"compiler/config"
+	"compiler/diag"
+	"compiler/sourcefile"
+)
+
+// cacheEntry describes a compilation saved under output/.cache/<key>, next
+// to copies of the artifacts it wrote
+type cacheEntry struct {
+	Included    map[string]string // hashes of the included files, empty for a missing one
+	Artifacts   []string          // paths the artifacts are restored to
+	Diagnostics []diag.Diagnostic
+	Truncated   bool
+	Errors      int
+}
+
+// entryFile is the name of the description of a cache entry
+const entryFile = "entry.json"
+
+// cacheKey names the cache entry of the current compilation. It hashes the
+// compiler itself, the command line, the language of the diagnostics and
+// the source; included files are checked when the entry is loaded.
+func cacheKey() (string, error) {
+	h := sha256.New()
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	compiler, err := fileHash(executable)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%s\x00", compiler)
+	for _, arg := range os.Args[1:] {
+		fmt.Fprintf(h, "%s\x00", arg)
+	}
+	fmt.Fprintf(h, "%s\x00", config.Lang)
+
+	source, err := sourcefile.Open(config.SOURCE_PATH)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	h.Write(source.Bytes())
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// fileHash returns the SHA-256 hash of the file at path, or an empty string
+// if it doesn't exist
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// loadCache restores the artifacts of the entry named key and returns it.
+// The second result is false if there is no such entry or an included file
+// has changed since it was saved.
+func loadCache(key string) (*cacheEntry, bool) {
+	dir := filepath.Join(config.CACHE_DIR, key)
+	data, err := os.ReadFile(filepath.Join(dir, entryFile))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	for path, hash := range entry.Included {
+		if current, err := fileHash(path); err != nil || current != hash {
+			return nil, false
+		}
+	}
+
+	// Read every copy before writing any, so that a damaged entry leaves
+	// the artifacts of the last compilation alone
+	contents := make([][]byte, len(entry.Artifacts))
+	for i, path := range entry.Artifacts {
+		if contents[i], err = os.ReadFile(filepath.Join(dir, filepath.Base(path))); err != nil {
+			return nil, false
+		}
+	}
+	for i, path := range entry.Artifacts {
+		if err := os.WriteFile(path, contents[i], 0644); err != nil {
+			return nil, false
+		}
+	}
+	return &entry, true
+}
+
+// saveCache saves entry under key together with copies of its artifacts.
+// The entry is assembled in a temporary directory and renamed into place,
+// so that an interrupted save leaves no entry behind.
+func saveCache(key string, included []string, entry cacheEntry) error {
+	entry.Included = make(map[string]string, len(included))
+	for _, path := range included {
+		hash, err := fileHash(path)
+		if err != nil {
+			return err
+		}
+		entry.Included[path] = hash
+	}
+	entry.Artifacts = slices.Compact(slices.Sorted(slices.Values(entry.Artifacts)))
+
+	if err := os.MkdirAll(config.CACHE_DIR, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(config.CACHE_DIR, key+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for _, path := range entry.Artifacts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(tmp, filepath.Base(path)), data, 0644); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, entryFile), data, 0644); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(config.CACHE_DIR, key)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"compiler/config"
+	"compiler/diag"
+	"compiler/lint"
+	"compiler/parser"
+	"compiler/sourcefile"
+)
+
+// sourceArg returns the file named by the first of args, or the configured
+// source if there is none
+func sourceArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return config.SOURCE_PATH
+}
+
+// openDocument parses the file at path for the commands that look up names
+func openDocument(path string) (*parser.Document, error) {
+	text, err := sourcefile.Text(path)
+	if err != nil {
+		return nil, err
+	}
+	return parser.NewDocumentAt(path, text), nil
+}
+
+// outline prints the declarations of a program as an indented tree, or as
+// JSON with --format=json
+func outline(args []string) int {
+	doc, err := openDocument(sourceArg(args))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	root := doc.Parser().Outline()
+	if config.Format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(root)
+	} else {
+		err = writeOutline(os.Stdout, root, 0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	return EXIT_SUCCESS
+}
+
+// writeOutline writes a symbol as "kind name position" and its children
+// indented below it
+func writeOutline(w io.Writer, symbol parser.Symbol, depth int) error {
+	line := strings.Repeat("  ", depth) + symbol.Kind + " " + symbol.Name
+	if symbol.Line > 0 {
+		line += " " + position(symbol.File, symbol.Line, symbol.Column)
+	}
+	if _, err := fmt.Fprintln(w, line); err != nil {
+		return err
+	}
+	for _, child := range symbol.Children {
+		if err := writeOutline(w, child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// position formats a place in the source as line:column, preceded by the
+// file if it is an included one; the column is left out if it is unknown
+func position(file string, line, column int) string {
+	pos := fmt.Sprint(line)
+	if column > 0 {
+		pos += fmt.Sprintf(":%d", column)
+	}
+	if file != "" {
+		pos = file + ":" + pos
+	}
+	return pos
+}
+
+// defs prints where the name at file:line:column is declared
+func defs(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: defs file.pas:line:column")
+		return EXIT_IO
+	}
+	path, line, column, err := parsePosition(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	doc, err := openDocument(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	pars := doc.Parser()
+	u, ok := pars.UseAt("", line, column)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "no declared name at %s\n", args[0])
+		return EXIT_IO
+	}
+	d := pars.Definition(u)
+	kind, _ := pars.KindOf(d)
+	fmt.Printf("%s %s %s\n", position(cmp.Or(d.File, path), d.Line, d.Column), kind, d.Name)
+	return EXIT_SUCCESS
+}
+
+// refs prints every use of the symbols with the given name, each under the
+// kind and scope that tell them apart
+func refs(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: refs name [file.pas]")
+		return EXIT_IO
+	}
+	path := sourceArg(args[1:])
+	doc, err := openDocument(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	pars := doc.Parser()
+	listed := make(map[parser.Use]bool)
+	found := false
+	for _, u := range pars.Uses() {
+		if u.Name != args[0] || listed[u] {
+			continue
+		}
+		kind, scope := pars.KindOf(u)
+		fmt.Printf("%s %s in %s\n", kind, u.Name, scope)
+		for _, r := range pars.References(u) {
+			listed[r] = true
+			ref := "  " + position(cmp.Or(r.File, path), r.Line, r.Column)
+			if r.Declaration {
+				ref += " declaration"
+			}
+			fmt.Println(ref)
+		}
+		found = true
+	}
+	if !found {
+		fmt.Fprintf(os.Stderr, "%s is not declared\n", args[0])
+		return EXIT_IO
+	}
+	return EXIT_SUCCESS
+}
+
+// lintFile reports the diagnostics of a program together with the warnings
+// of the lint rules that compiler.toml enables
+func lintFile(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: lint [file.pas]")
+		return EXIT_IO
+	}
+	path := sourceArg(args)
+	cfg, err := lint.LoadConfig(config.LINT_PATH)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	sink, err := diag.NewSink(config.Format, path, colorEnabled())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	doc, err := openDocument(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+
+	reporter := diag.NewReporter(config.MaxErrors)
+	for _, d := range doc.Diagnostics() {
+		reporter.StartPhase(d.Phase)
+		reporter.Report(d)
+	}
+	reporter.StartPhase(diag.LintPhase)
+	lint.Run(reporter, doc.Parser(), doc.AST(), cfg)
+
+	diagnostics, truncated := reporter.Diagnostics()
+	sink.Write(os.Stdout, diagnostics)
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Too many errors, stopped after %d.\n", config.MaxErrors)
+	}
+	return exitStatus(diagnostics, false)
+}
+
+// parsePosition splits file:line:column, where the file may itself contain
+// colons
+func parsePosition(s string) (path string, line, column int, err error) {
+	parts := strings.Split(s, ":")
+	if len(parts) >= 3 {
+		path = strings.Join(parts[:len(parts)-2], ":")
+		line, err = strconv.Atoi(parts[len(parts)-2])
+		if err == nil {
+			column, err = strconv.Atoi(parts[len(parts)-1])
+		}
+		if err == nil && line > 0 && column > 0 {
+			return path, line, column, nil
+		}
+	}
+	return "", 0, 0, fmt.Errorf("%s is not of the form file.pas:line:column", s)
+}
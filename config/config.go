@@ -1,21 +1,164 @@
 package config
 
 import (
+	"flag"
+	"fmt"
 	"os"
+	"slices"
+	"strings"
 )
 
 // File paths
 const (
-	SOURCE_PATH = "input/test.pas"
-	ERR_PATH    = "output/output.err"
-	DYD_PATH    = "output/output.dyd"
-	DYS_PATH    = "output/output.dys"
-	VAR_PATH    = "output/output.var"
-	PRO_PATH    = "output/output.pro"
+	SOURCE_PATH   = "input/test.pas"
+	ERR_PATH      = "output/output.err"     // merged report of all phases
+	LEX_ERR_PATH  = "output/output.lex.err" // lexer diagnostics only
+	PAR_ERR_PATH  = "output/output.par.err" // parser diagnostics only
+	LST_PATH      = "output/output.lst"     // source listing with diagnostics
+	DYD_PATH      = "output/output.dyd"
+	DYD_JSON_PATH = "output/output.dyd.json"
+	DYS_PATH      = "output/output.dys"
+	VAR_PATH      = "output/output.var"
+	PRO_PATH      = "output/output.pro"
+	XRF_PATH      = "output/output.xrf"
+	HTML_PATH     = "output/report.html"
+	LR_PATH       = "output/output.lr"       // automaton and tables of --parser=lr
+	DRV_PATH      = "output/output.drv"      // leftmost derivation of --emit=derivation
+	CST_DOT_PATH  = "output/output.cst.dot"  // concrete syntax tree of --emit=cst-dot
+	AST_PATH      = "output/output.ast.json" // abstract syntax tree of --emit=ast-json
+	HL_PATH       = "output/output.hl.html"  // highlighted source of --emit=highlight-html
+	GO_PATH       = "output/output.go"       // Go translation of --emit=go
+	PB_PATH       = "output/output.pb"       // compilation as protocol buffers of --emit=pb, see emit/compiler.proto
+	FIXED_PATH    = "output/fixed.pas"       // source corrected by --fix
+	STATS_PATH    = "output/output.stats"    // token statistics of --stats
+	LINT_PATH     = "compiler.toml"          // rules of the lint command
+	ICE_DIR       = "output/ice"             // reproducer bundle of an internal compiler error
+	CACHE_DIR     = "output/.cache"          // artifacts of earlier compilations, see --cache
 )
 
-// Init creates the output directory if it doesn't exist
+// Command line options
+var (
+	TokenFormat = "dyd"  // format of the token file passed from lexer to parser: dyd or json
+	Lang        = ""     // diagnostic language: zh or en, defaults from LANG
+	Format      = "text" // format of diagnostics printed to stdout: text, json or sarif
+	Report      = ""     // additional compilation report: html, or empty for none
+	Stats       = ""     // format of the token statistics: text or json, or empty for none
+
+	SymbolsFormat = "text" // format of the .var and .pro files: text, json or csv
+
+	Verbose   bool // log each phase with its statistics and wall time
+	InputHash bool // stamp every artifact with the SHA-256 hash of the source
+	CRLF      bool // end the lines of every artifact with CRLF
+	Quiet     bool // don't print the success banner
+	Mmap      bool // map the source file into memory instead of reading it
+	Cache     bool // reuse the artifacts of an earlier compilation of the same input
+	Fix       bool // write the source with the fixes of its diagnostics applied
+
+	Warnings         []string // enabled warning categories
+	WarningsAsErrors bool     // treat enabled warnings as errors
+	MaxErrors        int      // stop after this many errors, 0 for no limit
+	TabWidth         = 8      // display width of a tab when computing columns
+	MaxIdentLength   = 16     // longest accepted identifier
+	TruncateIdents   bool     // truncate longer identifiers with a warning instead of rejecting them
+	UnaryMinus       bool     // allow an expression to start with '-'
+	Dialect          = "ext"  // language level: mini, std or ext
+	Parser           = "ll"   // parsing method: ll (recursive descent) or lr (SLR tables)
+
+	NoIncludes bool // refuse {$include} directives, as the serve command does for sources it is sent
+
+	Railroad = ""     // directory for the railroad diagrams written by the grammar command
+	Emit     []string // additional artifacts, see EmitKinds
+)
+
+// EmitKinds lists the --emit values
+var EmitKinds = []string{"derivation", "cst-dot", "ast-json", "highlight-html", "go", "pb"}
+
+// Dialects lists the --dialect values, each extending the ones before it:
+// mini is the course grammar, std adds mod, div, writeln, strings and
+// argument lists, and ext adds procedures, arrays, loops, case and includes
+var Dialects = []string{"mini", "std", "ext"}
+
+func init() {
+	flag.StringVar(&TokenFormat, "token-format", TokenFormat, "token file format: dyd or json")
+	flag.StringVar(&Lang, "lang", Lang, "diagnostic language: zh or en (default from LANG)")
+	flag.StringVar(&Format, "format", Format, "diagnostic output format: text, json or sarif")
+	flag.StringVar(&Report, "report", Report, "also write a compilation report: html")
+	flag.Var((*statsFlag)(&Stats), "stats", "also write token statistics to output/output.stats, or as JSON with --stats=json")
+	flag.StringVar(&SymbolsFormat, "symbols-format", SymbolsFormat, "symbol table file format: text, json or csv")
+	flag.Var((*listFlag)(&Warnings), "W", "enable a warning category, or 'all' (repeatable)")
+	flag.BoolVar(&WarningsAsErrors, "Werror", WarningsAsErrors, "treat enabled warnings as errors")
+	flag.BoolVar(&Verbose, "verbose", Verbose, "log each phase with its statistics and wall time")
+	flag.BoolVar(&Verbose, "v", Verbose, "shorthand for --verbose")
+	flag.BoolVar(&InputHash, "input-hash", InputHash, "stamp every artifact with the SHA-256 hash of the source")
+	flag.BoolVar(&CRLF, "crlf", CRLF, "write artifacts with Windows (CRLF) line endings")
+	flag.BoolVar(&Quiet, "quiet", Quiet, "don't print the success banner")
+	flag.BoolVar(&Mmap, "mmap", Mmap, "map the source file into memory instead of reading it, for very large inputs")
+	flag.BoolVar(&Cache, "cache", Cache, "reuse the artifacts of an earlier compilation with the same source, included files and options")
+	flag.BoolVar(&Fix, "fix", Fix, "write the source with the safe fixes of its diagnostics applied to output/fixed.pas")
+	flag.IntVar(&MaxIdentLength, "max-ident-len", MaxIdentLength, "maximum identifier length")
+	flag.BoolVar(&TruncateIdents, "truncate-idents", TruncateIdents, "truncate identifiers longer than --max-ident-len with a warning instead of an error")
+	flag.BoolVar(&UnaryMinus, "unary-minus", UnaryMinus, "allow a leading '-' in expressions, as in k := -1")
+	flag.IntVar(&TabWidth, "tab-width", TabWidth, "columns per tab stop in diagnostics")
+	flag.IntVar(&MaxErrors, "max-errors", MaxErrors, "stop after N errors (0 for no limit)")
+	flag.StringVar(&Dialect, "dialect", Dialect, "language level: mini (course grammar), std or ext")
+	flag.StringVar(&Parser, "parser", Parser, "parsing method: ll (recursive descent) or lr (SLR tables, syntax only)")
+	flag.Var((*listFlag)(&Emit), "emit", "also write an artifact: derivation, cst-dot, ast-json, highlight-html, go or pb (repeatable)")
+	flag.StringVar(&Railroad, "railroad", Railroad, "with the grammar command, also write a railroad diagram of each rule as SVG to `dir`")
+}
+
+// Init parses the command line and creates the output directory if it doesn't exist
 func Init() error {
+	flag.Parse()
+
+	if TokenFormat != "dyd" && TokenFormat != "json" {
+		return fmt.Errorf("unknown token format '%s', expected dyd or json", TokenFormat)
+	}
+
+	if SymbolsFormat != "text" && SymbolsFormat != "json" && SymbolsFormat != "csv" {
+		return fmt.Errorf("unknown symbols format '%s', expected text, json or csv", SymbolsFormat)
+	}
+
+	if Report != "" && Report != "html" {
+		return fmt.Errorf("unknown report format '%s', expected html", Report)
+	}
+
+	if Stats != "" && Stats != "text" && Stats != "json" {
+		return fmt.Errorf("unknown statistics format '%s', expected text or json", Stats)
+	}
+
+	if MaxErrors < 0 {
+		return fmt.Errorf("--max-errors must not be negative")
+	}
+
+	if MaxIdentLength < 1 {
+		return fmt.Errorf("--max-ident-len must be at least 1")
+	}
+
+	if TabWidth < 1 {
+		return fmt.Errorf("--tab-width must be at least 1")
+	}
+
+	if !slices.Contains(Dialects, Dialect) {
+		return fmt.Errorf("unknown dialect '%s', expected mini, std or ext", Dialect)
+	}
+
+	if Parser != "ll" && Parser != "lr" {
+		return fmt.Errorf("unknown parser '%s', expected ll or lr", Parser)
+	}
+
+	for _, kind := range Emit {
+		if !slices.Contains(EmitKinds, kind) {
+			return fmt.Errorf("unknown artifact '%s', expected %s", kind, strings.Join(EmitKinds, " or "))
+		}
+	}
+
+	if Lang == "" {
+		Lang = "en"
+		if strings.HasPrefix(strings.ToLower(os.Getenv("LANG")), "zh") {
+			Lang = "zh"
+		}
+	}
+
 	if _, err := os.Stat("output"); os.IsNotExist(err) {
 		err := os.Mkdir("output", 0755)
 		if err != nil {
@@ -24,3 +167,72 @@ func Init() error {
 	}
 	return nil
 }
+
+// DialectAtLeast reports whether the selected dialect includes dialect
+func DialectAtLeast(dialect string) bool {
+	return slices.Index(Dialects, Dialect) >= slices.Index(Dialects, dialect)
+}
+
+// Emits reports whether --emit requested the artifact kind
+func Emits(kind string) bool {
+	return slices.Contains(Emit, kind)
+}
+
+// TokenPath returns the token file path for the selected token format
+func TokenPath() string {
+	if TokenFormat == "json" {
+		return DYD_JSON_PATH
+	}
+	return DYD_PATH
+}
+
+// SymbolPaths returns the variable and procedure table paths for the
+// selected symbols format
+func SymbolPaths() (string, string) {
+	if SymbolsFormat == "text" {
+		return VAR_PATH, PRO_PATH
+	}
+	return VAR_PATH + "." + SymbolsFormat, PRO_PATH + "." + SymbolsFormat
+}
+
+// StatsPath returns the token statistics path for the selected format
+func StatsPath() string {
+	if Stats == "json" {
+		return STATS_PATH + ".json"
+	}
+	return STATS_PATH
+}
+
+// listFlag collects the comma separated values of a repeatable flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// statsFlag is the value of --stats, which selects text when given alone
+type statsFlag string
+
+func (s *statsFlag) String() string {
+	return string(*s)
+}
+
+func (s *statsFlag) Set(value string) error {
+	switch value {
+	case "true":
+		value = "text"
+	case "false":
+		value = ""
+	}
+	*s = statsFlag(value)
+	return nil
+}
+
+func (s *statsFlag) IsBoolFlag() bool {
+	return true
+}
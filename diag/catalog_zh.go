@@ -0,0 +1,319 @@
+package diag
+
+var catalogZh = map[Code]entry{
+	L001: {
+		Title:       "单词不完整",
+		Message:     "冒号使用错误",
+		Explanation: "':' 后必须紧跟 '='，组成赋值运算符 ':='。在没有 case 标号的方言 mini 与 std 中，单独的 ':' 报告 L001。在 ext 中 ':' 用于分隔 case 标号与执行语句，单独的 ':' 是一个单词，由语法分析报告缺少 ':='。",
+		Example:     "k: 1      <- 应为 k := 1",
+	},
+	L002: {
+		Title:       "非法字符",
+		Message:     "非法字符 '%c'",
+		Explanation: "该字符不属于本语言。只允许字母、数字、空格、换行以及符号 = <> <= < >= > - * := : ( ) [ ] , ;，以及单引号括起的字符串和 {$include} 指令。",
+		Example:     "k := k + 1      <- 不支持 '+'，可写作 k - (0 - 1)",
+	},
+	L003: {
+		Title:       "标识符过长",
+		Message:     "标识符 '%s' 超过 %d 个字符",
+		Explanation: "标识符长度默认不超过 16 个字符，可用 --max-ident-len 修改。请使用更短的名字，或使用 --truncate-idents 将过长的名字截断并给出警告。",
+	},
+	L004: {
+		Title:       "读取源文件失败",
+		Message:     "读取源文件失败：%v",
+		Explanation: "源文件未能完整读取，例如输入流被意外关闭。",
+	},
+	L005: {
+		Title:       "非法的数字",
+		Message:     "非法的数字 '%s'",
+		Explanation: "数字只能由数字字符组成。紧跟在数字后面的字母不会被拆分为单独的标识符。",
+		Example:     "k := 123abc      <- 应写作 123，或使用以字母开头的名字",
+	},
+	L006: {
+		Title:       "常数过大",
+		Message:     "常数 %s 超过最大值 %d",
+		Explanation: "整数为 32 位有符号数，常数最大为 2147483647。",
+	},
+	L007: {
+		Title:       "字符串未结束",
+		Message:     "字符串没有结束",
+		Explanation: "字符串必须在同一行内以单引号结束。字符串中的单引号要写两次。",
+		Example:     "write('it's')      <- 应写作 'it''s'",
+	},
+	L008: {
+		Title:       "指令格式错误",
+		Message:     "指令 '%s' 格式错误",
+		Explanation: "花括号括起的是编译指令，必须写在一行之内。目前只有 {$include 'file'} 一种指令，它在所在位置编译指定的文件。",
+		Example:     "{$include lib.pas}      <- 应写作 {$include 'lib.pas'}",
+	},
+	L009: {
+		Title:       "无法包含文件",
+		Message:     "无法包含 '%s'：%v",
+		Explanation: "{$include} 指令指定的文件无法打开，或者会包含它自身。文件名相对于包含它的文件所在的目录。",
+	},
+	P001: {
+		Title:       "意外的单词",
+		Message:     "应为 %s，但得到 '%s'",
+		Explanation: "语法分析器在此处期望的是另一个单词。",
+		Example:     "read m      <- 缺少 '('",
+	},
+	P002: {
+		Title:       "缺少说明语句",
+		Message:     "每个程序或过程至少需要一条说明语句",
+		Explanation: "每个分程序都必须先有一条或多条 'integer' 说明语句，然后才是执行语句。",
+		Example:     "begin\n  read(m)      <- 先声明 'integer m;'\nend",
+	},
+	P003: {
+		Title:       "非法的变量名",
+		Message:     "'%s' 不是合法的变量名",
+		Explanation: "'integer' 之后必须是变量名或 'function'。",
+	},
+	P004: {
+		Title:       "括号不匹配",
+		Message:     "'(' 未匹配",
+		Explanation: "每个 '(' 都必须由对应的 ')' 闭合。",
+	},
+	P005: {
+		Title:       "执行语句后出现说明语句",
+		Message:     "请将所有说明语句移到过程开头",
+		Explanation: "说明语句只能出现在分程序的第一条执行语句之前。",
+		Example:     "begin\n  integer k;\n  read(k);\n  integer m;      <- 移到 read(k) 之前\n  write(k)\nend",
+	},
+	P006: {
+		Title:       "非法的执行语句",
+		Message:     "执行语句不能以 '%s' 开头",
+		Explanation: "执行语句只能是读语句、写语句、赋值语句、过程调用语句、条件语句、case 语句或循环语句。",
+	},
+	P007: {
+		Title:       "非法的因子",
+		Message:     "应为变量、函数或常数，但得到 '%s'",
+		Explanation: "算术表达式由变量、函数调用和常数组成。",
+	},
+	P008: {
+		Title:       "非法的关系运算符",
+		Message:     "%s 不是合法的运算符",
+		Explanation: "条件表达式使用 = <> < <= > >= 之一比较两个表达式。",
+	},
+	P009: {
+		Title:       "缺少 'begin'",
+		Message:     "应为 %s，但得到 '%s'",
+		Explanation: "程序和函数体必须以 'begin' 开头。",
+		Example:     "integer function F(n);\n  integer n;      <- 缺少 'begin'",
+	},
+	P010: {
+		Title:       "缺少 'end'",
+		Message:     "应为 %s，但得到 '%s'",
+		Explanation: "每个 'begin' 都必须由 'end' 闭合。这通常是两条执行语句之间漏写了 ';'。",
+		Example:     "begin\n  integer k;\n  k := 1\n  write(k)      <- k := 1 之后缺少 ';'\nend",
+	},
+	P011: {
+		Title:       "缺少 'then'",
+		Message:     "应为 %s，但得到 '%s'",
+		Explanation: "条件表达式之后必须是 'then'。",
+	},
+	P012: {
+		Title:       "缺少 'else'",
+		Message:     "应为 %s，但得到 '%s'",
+		Explanation: "每个条件语句都必须有 'else' 分支。",
+	},
+	P013: {
+		Title:       "缺少 ':='",
+		Message:     "应为 %s，但得到 '%s'",
+		Explanation: "以名字开头的执行语句必须是使用 ':=' 的赋值语句。",
+	},
+	P014: {
+		Title:       "缺少分号",
+		Message:     "应为 %s，但得到 '%s'",
+		Explanation: "说明语句必须以 ';' 结尾，分程序中的执行语句之间必须用 ';' 分隔。",
+	},
+	P015: {
+		Title:       "关键字拼写错误",
+		Message:     "'%s' 疑似 %s 的拼写错误",
+		Explanation: "在应为关键字的位置出现了一个与该关键字仅相差一两个字母的标识符。语法分析器会将其视为该关键字继续分析。",
+	},
+	P016: {
+		Title:       "保留字用作标识符",
+		Message:     "'%s' 是保留字，不能用作标识符",
+		Explanation: "begin、end、integer、if、then、else、function、read、write、writeln、mod、div、var、procedure、repeat、until、for、to、do、while、case、of、forward 等关键字是保留字，不能用作变量、函数或参数的名字。",
+		Example:     "integer if;      <- 请换一个名字",
+	},
+	P017: {
+		Title:       "关系运算符连用",
+		Message:     "关系运算符不能连用，但又得到了 '%s'",
+		Explanation: "条件只比较两个表达式，a < b < c 并不能判断 b 是否位于 a 与 c 之间。Pascal 中应使用 and 连接两个比较，如 (a < b) and (b < c)；本语言没有 and 运算符，请改用两层嵌套的 if 语句。",
+		Example:     "if a < b < c then k := 1 else k := 0      <- if a < b then if b < c then ...",
+	},
+	P018: {
+		Title:       "LR 分析中的语法错误",
+		Message:     "意外的 '%s'，期望 %s",
+		Explanation: "使用 --parser=lr 时，程序由 SLR(1) 自动机分析，当前状态的 ACTION 表中没有这个单词的表项。期望的单词即该状态有表项的单词；output/output.lr 列出了各状态及其分析表。为了继续分析，分析器会弹出状态，直到某个状态能处理该单词，否则跳过单词直到出现这样的状态。",
+		Example:     "begin integer k; k := k * end      <- 期望标识符或常数",
+	},
+	S001: {
+		Title:       "变量重复声明",
+		Message:     "变量 '%s' 已被声明",
+		Explanation: "同一过程中的变量只能声明一次。",
+	},
+	S002: {
+		Title:       "参数重复声明",
+		Message:     "参数 '%s' 已被声明",
+		Explanation: "每个函数的参数只能声明一次。",
+	},
+	S003: {
+		Title:       "变量未声明",
+		Message:     "未定义的变量 '%s'",
+		Explanation: "使用的变量既未在当前过程中声明，也未在外层过程中声明。",
+		Example:     "begin\n  integer k;\n  read(m)      <- 'm' 未声明\nend",
+	},
+	S004: {
+		Title:       "参数未在函数体中声明",
+		Message:     "变量 '%s' 未声明",
+		Explanation: "函数参数还必须在函数体开头用 'integer' 声明。",
+		Example:     "integer function F(n);\nbegin\n  integer k;      <- 添加 'integer n;'\n  F := n\nend;",
+	},
+	S005: {
+		Title:       "过程未声明",
+		Message:     "未定义的过程 '%s'",
+		Explanation: "调用的过程既未在当前过程中声明，也未在外层过程中声明。",
+		Example:     "k := G(1)      <- 'G' 未声明",
+	},
+	S006: {
+		Title:       "过程重复声明",
+		Message:     "过程 '%s' 已被声明",
+		Explanation: "同一层次中的过程只能声明一次。",
+	},
+	S007: {
+		Title:       "变量或过程未声明",
+		Message:     "未定义的变量或过程 '%s'",
+		Explanation: "该名字既不是可见的变量，也不是可见的过程。",
+		Example:     "k := m(1)      <- 'm' 未声明",
+	},
+	S008: {
+		Title:       "var 参数的实参不是变量",
+		Message:     "函数 '%[2]s' 的 var 参数 '%[1]s' 的实参必须是变量",
+		Explanation: "var 参数按引用传递，函数可以通过它给调用者的变量赋值，因此实参必须是单个变量，而不能是常数或表达式。",
+		Example:     "integer function Swap(var n);\n...\nk := Swap(m - 1)      <- 应传入变量，如 m",
+	},
+	S009: {
+		Title:       "过程用作值",
+		Message:     "过程 '%s' 没有返回值",
+		Explanation: "用 'procedure' 声明的过程没有返回值，只能作为单独的执行语句调用，不能出现在表达式中，也不能给过程名赋值。",
+		Example:     "procedure Show(n);\n...\nk := Show(k)      <- 应单独写 Show(k)",
+	},
+	S010: {
+		Title:       "在函数体外为函数结果赋值",
+		Message:     "在函数 '%s' 的函数体之外为其赋值",
+		Explanation: "为函数名赋值即设置该函数的返回值，因此只允许在该函数自身的函数体内进行。",
+	},
+	S011: {
+		Title:       "下标变量不是数组",
+		Message:     "变量 '%s' 不是数组",
+		Explanation: "只有声明时给出大小的变量（如 integer a[10]）才能使用下标。",
+	},
+	S012: {
+		Title:       "数组缺少下标",
+		Message:     "数组 '%s' 必须带下标使用",
+		Explanation: "数组只能逐个元素地读、写和赋值，因此每次使用数组都需要在方括号中给出下标。",
+		Example:     "integer a[10];\n...\nread(a)      <- 应写作 read(a[0])",
+	},
+	S013: {
+		Title:       "下标越界",
+		Message:     "下标 %d 超出数组 '%s' 的范围（共 %d 个元素）",
+		Explanation: "声明为 integer a[n] 的数组的元素为 a[0] 到 a[n-1]。常数下标在编译时检查。",
+		Example:     "integer a[10];\n...\na[10] := 0      <- 最后一个元素是 a[9]",
+	},
+	S014: {
+		Title:       "数组大小不是正数",
+		Message:     "数组 '%s' 至少要有一个元素",
+		Explanation: "数组声明中的大小是元素个数，必须大于零。",
+	},
+	S015: {
+		Title:       "case 标号重复",
+		Message:     "case 标号 %s 出现了不止一次",
+		Explanation: "每个值只能作为 case 语句中一个分支的标号，否则无法确定执行哪个分支。",
+		Example:     "case k of\n  1: write(k);\n  1: read(k)      <- 标号 1 已被使用\nend",
+	},
+	S016: {
+		Title:       "定义与前置声明不一致",
+		Message:     "'%s' 的定义与其前置声明不一致",
+		Explanation: "用 forward 声明的函数，之后必须以相同的种类定义，参数的名字和传递方式也必须相同。",
+		Example:     "integer function F(n); forward;\n...\nprocedure F(var n);      <- 应为 integer function F(n)",
+	},
+	S017: {
+		Title:       "前置声明没有定义",
+		Message:     "函数 '%s' 有前置声明但没有定义",
+		Explanation: "每个前置声明之后，都必须在同一分程序中给出带函数体的定义。",
+	},
+	W001: {
+		Title:       "未使用的变量",
+		Message:     "变量 '%s' 已声明但从未使用",
+		Explanation: "该变量已声明但从未被引用。请删除该声明或使用该变量。",
+	},
+	W002: {
+		Title:       "未使用的参数",
+		Message:     "'%[2]s' 的参数 '%[1]s' 从未使用",
+		Explanation: "函数从未读取其参数，因此调用者传入的实参不起作用。",
+	},
+	W003: {
+		Title:       "在函数体外为函数结果赋值",
+		Message:     "在函数 '%s' 的函数体之外为其赋值",
+		Explanation: "为函数名赋值即设置该函数的返回值，只应在该函数自身的函数体内进行。现在这种情况报告为错误 S010，不再报告 W003。",
+	},
+	W004: {
+		Title:       "未使用的过程",
+		Message:     "过程 '%s' 已声明但从未调用",
+		Explanation: "该过程已声明但从未被调用。请删除它或调用它。",
+	},
+	W005: {
+		Title:       "变量在赋值前被使用",
+		Message:     "变量 '%s' 可能在赋值前被使用",
+		Explanation: "在过程的至少一条执行路径上，该变量在被赋值或被 read() 读入之前就被读取，其值是未定义的。",
+	},
+	W006: {
+		Title:       "标识符被截断",
+		Message:     "标识符 '%s' 超过 %d 个字符，已截断为 '%s'",
+		Explanation: "使用 --truncate-idents 时，超过 --max-ident-len 的标识符会被截断而不是报错，与部分参考实现的行为一致。前若干个字符相同的两个长名字因此会表示同一个变量。",
+		Example:     "integer averageOfAllTheScores;      <- 视为 'averageOfAllTheS'",
+	},
+	W007: {
+		Title:       "函数结果未赋值",
+		Message:     "函数 '%s' 可能在未给结果赋值的情况下结束",
+		Explanation: "函数的返回值是最后一次赋给函数名的值。函数体中至少有一条路径没有这样的赋值，因此结果不确定。",
+		Example:     "integer function F(n);\nbegin\n  integer n;\n  if n > 0 then F := n else n := 0      <- n <= 0 时 F 未赋值\nend;",
+	},
+	W008: {
+		Title:       "命名约定",
+		Message:     "名字 '%s' 不符合 %[3]s 的命名约定 %[2]s",
+		Explanation: "lint 命令的命名规则用 compiler.toml 中设置的模式检查变量和过程的名字，默认变量为小驼峰式，过程和函数为大驼峰式。",
+		Example:     "integer Total;      <- 应写作 total",
+	},
+	W009: {
+		Title:       "变量被遮蔽",
+		Message:     "'%[2]s' 的变量 '%[1]s' 遮蔽了第 %[4]d 行说明的 '%[3]s' 的同名变量",
+		Explanation: "过程的变量或参数与外层分程序的变量同名，过程中便无法再使用外层的变量。重命名其中之一可以表明所指的是哪一个。",
+		Example:     "begin\n  integer k;\n  integer function F(n);\n  begin\n    integer n;\n    integer k;      <- 遮蔽了主程序的 k\n    ...",
+	},
+	W010: {
+		Title:       "嵌套过深",
+		Message:     "语句嵌套了 %d 层，超过 %d 层",
+		Explanation: "if、case 和循环语句的嵌套层数超过了 compiler.toml 中设置的上限。把内层部分移到单独的过程中更容易理解。",
+		Example:     "while ... do\n  if ... then\n    for ... do\n      if ... then      <- 第四层",
+	},
+	W011: {
+		Title:       "过程过长",
+		Message:     "'%s' 有 %d 条语句，超过 %d 条",
+		Explanation: "主程序、过程或函数体中的语句数超过了 compiler.toml 中设置的上限，其中计入 if、case 和循环语句内的语句，但不计入嵌套的过程。把它拆分为较小的过程更容易理解。",
+	},
+	W012: {
+		Title:       "魔数",
+		Message:     "直接使用了数 %d",
+		Explanation: "表达式中出现了 compiler.toml 允许之外的数。本语言没有常量，因此该规则默认关闭；可以用在开头赋值一次的变量为这个数命名。",
+		Example:     "if k > 86400 then ...      <- 86400 是什么？",
+	},
+	W013: {
+		Title:       "不可达的过程",
+		Message:     "过程 '%s' 从未被主程序调用",
+		Explanation: "该过程虽被调用，但调用它的只有它自己，或主程序直接和间接都不会调用的过程，因此永远不会执行，可以与这些调用者一起删除。",
+		Example:     "integer function G(n);      <- 只被自身调用\nbegin\n  integer n;\n  G := G(n - 1)\nend;",
+	},
+}
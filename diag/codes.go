@@ -0,0 +1,507 @@
+package diag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Code identifies a diagnostic independently of its message wording
+type Code string
+
+// Lexer diagnostics
+const (
+	L001 Code = "L001" // unterminated token; a lone ':' is a token in ext
+	L002 Code = "L002" // invalid character
+	L003 Code = "L003" // identifier too long
+	L004 Code = "L004" // source read failure
+	L005 Code = "L005" // malformed number
+	L006 Code = "L006" // constant too large
+	L007 Code = "L007" // unterminated string
+	L008 Code = "L008" // malformed directive
+	L009 Code = "L009" // include failure
+)
+
+// IncludeFailure is the reason an {$include} directive fails, given to L009
+// and worded in the language of the diagnostics
+type IncludeFailure string
+
+const (
+	IncludeForbidden  IncludeFailure = "files cannot be included here"
+	IncludeItself     IncludeFailure = "the file includes itself"
+	IncludeMissing    IncludeFailure = "the file does not exist"
+	IncludeDenied     IncludeFailure = "permission denied"
+	IncludeUnreadable IncludeFailure = "the file cannot be opened"
+)
+
+func (f IncludeFailure) String() string {
+	return Term(string(f))
+}
+
+// Parser diagnostics
+const (
+	P001 Code = "P001" // unexpected token
+	P002 Code = "P002" // missing declaration
+	P003 Code = "P003" // invalid declaration name
+	P004 Code = "P004" // unmatched parenthesis
+	P005 Code = "P005" // declaration after execution
+	P006 Code = "P006" // invalid execution start
+	P007 Code = "P007" // invalid factor
+	P008 Code = "P008" // invalid relational operator
+	P009 Code = "P009" // missing 'begin'
+	P010 Code = "P010" // missing 'end'
+	P011 Code = "P011" // missing 'then'
+	P012 Code = "P012" // missing 'else'
+	P013 Code = "P013" // missing ':='
+	P014 Code = "P014" // missing ';'
+	P015 Code = "P015" // misspelled keyword
+	P016 Code = "P016" // reserved word used as identifier
+	P017 Code = "P017" // chained relational operators
+	P018 Code = "P018" // no entry in the LR action table
+)
+
+// Semantic diagnostics
+const (
+	S001 Code = "S001" // duplicate variable
+	S002 Code = "S002" // duplicate parameter
+	S003 Code = "S003" // undeclared variable
+	S004 Code = "S004" // parameter not declared in body
+	S005 Code = "S005" // undeclared procedure
+	S006 Code = "S006" // duplicate procedure
+	S007 Code = "S007" // undeclared variable or procedure
+	S008 Code = "S008" // var argument is not a variable
+	S009 Code = "S009" // procedure used as a value
+	S010 Code = "S010" // function result assigned outside its body
+	S011 Code = "S011" // indexed variable is not an array
+	S012 Code = "S012" // array used without an index
+	S013 Code = "S013" // constant index out of bounds
+	S014 Code = "S014" // array size not positive
+	S015 Code = "S015" // duplicate case label
+	S016 Code = "S016" // definition does not match forward declaration
+	S017 Code = "S017" // forward declaration never defined
+)
+
+// Warning diagnostics
+const (
+	W001 Code = "W001" // unused variable
+	W002 Code = "W002" // unused parameter
+	W003 Code = "W003" // function result assigned outside its body; now reported as S010
+	W004 Code = "W004" // unused procedure
+	W005 Code = "W005" // variable used before assignment
+	W006 Code = "W006" // identifier truncated
+	W007 Code = "W007" // function result not assigned
+
+	// Reported by the lint command, whose rules compiler.toml selects
+	W008 Code = "W008" // name breaks the naming convention
+	W009 Code = "W009" // variable shadows an outer one
+	W010 Code = "W010" // statements nested too deeply
+	W011 Code = "W011" // procedure body too long
+	W012 Code = "W012" // magic number
+
+	W013 Code = "W013" // procedure only called from unreachable ones
+)
+
+// entry describes a diagnostic code
+type entry struct {
+	Title       string
+	Message     string // fmt format of the short message
+	Explanation string
+	Example     string
+	Category    string // -W category name; warnings without one are always reported
+}
+
+var catalog = map[Code]entry{
+	L001: {
+		Title:       "unterminated token",
+		Message:     "Misused colon",
+		Explanation: "A ':' must be immediately followed by '=' to form the assignment operator ':='. L001 is reported for a lone ':' in the dialects without case labels, mini and std. In ext, where ':' separates case labels from their executions, a lone ':' is a token and the parser reports the missing ':=' instead.",
+		Example:     "k: 1      <- should be k := 1",
+	},
+	L002: {
+		Title:       "invalid character",
+		Message:     "Invalid character '%c'",
+		Explanation: "The character is not part of the language. Only letters, digits, spaces, line breaks and the symbols = <> <= < >= > - * := : ( ) [ ] , ; are allowed, besides string literals in single quotes and {$include} directives.",
+		Example:     "k := k + 1      <- '+' is not supported, write k - (0 - 1)",
+	},
+	L003: {
+		Title:       "identifier too long",
+		Message:     "Identifier name '%s' exceeds %d characters",
+		Explanation: "Identifiers are limited to 16 characters unless --max-ident-len says otherwise. Use a shorter name, or pass --truncate-idents to cut long names with a warning.",
+		Example:     "integer averageOfAllTheScores;",
+	},
+	L004: {
+		Title:       "source read failure",
+		Message:     "Failed to read source: %v",
+		Explanation: "The source file could not be read completely, for example because the input stream was closed unexpectedly.",
+		Example:     "",
+	},
+	L005: {
+		Title:       "malformed number",
+		Message:     "Malformed number '%s'",
+		Explanation: "A number must consist of digits only. Letters directly after the digits are not split off into a separate identifier.",
+		Example:     "k := 123abc      <- write 123 or a name that starts with a letter",
+	},
+	L006: {
+		Title:       "constant too large",
+		Message:     "Constant %s exceeds the maximum of %d",
+		Explanation: "Integers are 32-bit signed values, so a constant may be at most 2147483647.",
+		Example:     "k := 3000000000",
+	},
+	L007: {
+		Title:       "unterminated string",
+		Message:     "String literal is not terminated",
+		Explanation: "A string literal must be closed with a single quote on the same line. A quote inside the string is written twice.",
+		Example:     "write('it's')      <- write 'it''s'",
+	},
+	L008: {
+		Title:       "malformed directive",
+		Message:     "Malformed directive '%s'",
+		Explanation: "Braces enclose a compiler directive, which must fit on one line. The only directive is {$include 'file'}, which compiles the named file in its place.",
+		Example:     "{$include lib.pas}      <- write {$include 'lib.pas'}",
+	},
+	L009: {
+		Title:       "include failure",
+		Message:     "Cannot include '%s': %v",
+		Explanation: "The file named in an {$include} directive could not be opened, or it would include itself. The name is relative to the directory of the including file.",
+		Example:     "{$include 'missing.pas'}",
+	},
+	P001: {
+		Title:       "unexpected token",
+		Message:     "Expect %s, but got '%s'",
+		Explanation: "The parser expected a different token at this position.",
+		Example:     "read m      <- expected '('",
+	},
+	P002: {
+		Title:       "missing declaration",
+		Message:     "Every program or procedure should have at least one declaration",
+		Explanation: "Every block starts with one or more 'integer' declarations before its executions.",
+		Example:     "begin\n  read(m)      <- declare 'integer m;' first\nend",
+	},
+	P003: {
+		Title:       "invalid declaration name",
+		Message:     "'%s' is not a valid variable name",
+		Explanation: "'integer' must be followed by a variable name or 'function'.",
+		Example:     "integer 1k;",
+	},
+	P004: {
+		Title:       "unmatched parenthesis",
+		Message:     "Unmatched '('",
+		Explanation: "Every '(' must be closed by a matching ')'.",
+		Example:     "k := F(n - 1;",
+	},
+	P005: {
+		Title:       "declaration after execution",
+		Message:     "Please move all declarations to the beginning of the procedure",
+		Explanation: "Declarations may only appear before the first execution of a block.",
+		Example:     "begin\n  integer k;\n  read(k);\n  integer m;      <- move above read(k)\n  write(k)\nend",
+	},
+	P006: {
+		Title:       "invalid execution start",
+		Message:     "Execution cannot begin with '%s'",
+		Explanation: "An execution must be a read, a write, an assignment, a procedure call, an if or case statement or a loop.",
+		Example:     "then k := 1",
+	},
+	P007: {
+		Title:       "invalid factor",
+		Message:     "Expect variable, procedure or constant, but got '%s'",
+		Explanation: "Arithmetic expressions are built from variables, function calls and constants.",
+		Example:     "k := * 2",
+	},
+	P008: {
+		Title:       "invalid relational operator",
+		Message:     "%s is not a valid operator",
+		Explanation: "A condition compares two expressions with one of = <> < <= > >=.",
+		Example:     "if k := 1 then ...",
+	},
+	P009: {
+		Title:       "missing 'begin'",
+		Message:     "Expect %s, but got '%s'",
+		Explanation: "Programs and function bodies must start with 'begin'.",
+		Example:     "integer function F(n);\n  integer n;      <- 'begin' is missing",
+	},
+	P010: {
+		Title:       "missing 'end'",
+		Message:     "Expect %s, but got '%s'",
+		Explanation: "Every 'begin' must be closed by 'end'. This is often caused by a missing ';' between two executions.",
+		Example:     "begin\n  integer k;\n  k := 1\n  write(k)      <- ';' is missing after k := 1\nend",
+	},
+	P011: {
+		Title:       "missing 'then'",
+		Message:     "Expect %s, but got '%s'",
+		Explanation: "A condition must be followed by 'then'.",
+		Example:     "if n <= 0 F := 1 else F := n",
+	},
+	P012: {
+		Title:       "missing 'else'",
+		Message:     "Expect %s, but got '%s'",
+		Explanation: "Every if statement must have an 'else' branch.",
+		Example:     "if n <= 0 then F := 1;",
+	},
+	P013: {
+		Title:       "missing ':='",
+		Message:     "Expect %s, but got '%s'",
+		Explanation: "An execution starting with a name must be an assignment using ':='.",
+		Example:     "k = 1",
+	},
+	P014: {
+		Title:       "missing semicolon",
+		Message:     "Expect %s, but got '%s'",
+		Explanation: "Declarations must end with ';', and executions in a block must be separated by ';'.",
+		Example:     "begin\n  integer k\n  read(k)\nend",
+	},
+	P015: {
+		Title:       "misspelled keyword",
+		Message:     "'%s' looks like a misspelling of %s",
+		Explanation: "An identifier was found where a keyword was expected, and it differs from that keyword by only one or two letters. The parser continues as if the keyword had been written.",
+		Example:     "bgein      <- should be begin\n  integer k;\n  read(k)\nend",
+	},
+	P016: {
+		Title:       "reserved word used as identifier",
+		Message:     "'%s' is a reserved word and cannot be used as an identifier",
+		Explanation: "Keywords such as begin, end, integer, if, then, else, function, read, write, writeln, mod, div, var, procedure, repeat, until, for, to, do, while, case, of and forward are reserved and cannot name variables, functions or parameters.",
+		Example:     "integer if;      <- choose another name",
+	},
+	P017: {
+		Title:       "chained relational operators",
+		Message:     "Relational operators cannot be chained, but got another '%s'",
+		Explanation: "A condition compares exactly two expressions, so a < b < c does not test whether b lies between a and c. In Pascal the comparisons would be joined with and, as in (a < b) and (b < c); this language has no and operator, so nest two if statements instead.",
+		Example:     "if a < b < c then k := 1 else k := 0      <- if a < b then if b < c then ...",
+	},
+	P018: {
+		Title:       "syntax error in LR parsing",
+		Message:     "Unexpected '%s', expected %s",
+		Explanation: "With --parser=lr the program is parsed by an SLR(1) automaton, whose ACTION table has no entry for this token in the current state. The expected tokens are those the state has an entry for; output/output.lr lists the states and their tables. To continue, the parser pops states until one can handle the token, or skips tokens until there is such a state.",
+		Example:     "begin integer k; k := k * end      <- expected identifier or constant",
+	},
+	S001: {
+		Title:       "duplicate variable",
+		Message:     "Variable '%s' has already been declared",
+		Explanation: "A variable may only be declared once in the same procedure.",
+		Example:     "integer k;\ninteger k;",
+	},
+	S002: {
+		Title:       "duplicate parameter",
+		Message:     "Parameter '%s' has already been declared",
+		Explanation: "A parameter may only be declared once per function.",
+		Example:     "",
+	},
+	S003: {
+		Title:       "undeclared variable",
+		Message:     "Undefined variable '%s'",
+		Explanation: "The variable is used without being declared in the current procedure or an enclosing one.",
+		Example:     "begin\n  integer k;\n  read(m)      <- 'm' is not declared\nend",
+	},
+	S004: {
+		Title:       "parameter not declared in body",
+		Message:     "Variable '%s' has not been declared",
+		Explanation: "A function parameter must also be declared with 'integer' at the beginning of the function body.",
+		Example:     "integer function F(n);\nbegin\n  integer k;      <- add 'integer n;'\n  F := n\nend;",
+	},
+	S005: {
+		Title:       "undeclared procedure",
+		Message:     "Undefined procedure '%s'",
+		Explanation: "The procedure is called without being declared in the current procedure or an enclosing one.",
+		Example:     "k := G(1)      <- 'G' is not declared",
+	},
+	S006: {
+		Title:       "duplicate procedure",
+		Message:     "Procedure '%s' has already been declared",
+		Explanation: "A procedure may only be declared once at the same level.",
+		Example:     "",
+	},
+	S007: {
+		Title:       "undeclared variable or procedure",
+		Message:     "Undefined variable or procedure '%s'",
+		Explanation: "The name is neither a visible variable nor a visible procedure.",
+		Example:     "k := m(1)      <- 'm' is not declared",
+	},
+	S008: {
+		Title:       "var argument is not a variable",
+		Message:     "Argument for var parameter '%s' of '%s' must be a variable",
+		Explanation: "A var parameter is passed by reference, so the function can assign the caller's variable through it. The argument must therefore be a single variable, not a constant or an expression.",
+		Example:     "integer function Swap(var n);\n...\nk := Swap(m - 1)      <- pass a variable such as m",
+	},
+	S009: {
+		Title:       "procedure used as a value",
+		Message:     "Procedure '%s' has no result",
+		Explanation: "A procedure declared with 'procedure' returns nothing, so it can only be called as an execution of its own. It cannot appear in an expression, and its name cannot be assigned a result.",
+		Example:     "procedure Show(n);\n...\nk := Show(k)      <- write Show(k) on its own",
+	},
+	S010: {
+		Title:       "function result assigned outside its body",
+		Message:     "Assignment to function '%s' outside its own body",
+		Explanation: "Assigning to a function name sets that function's result, so it is only allowed inside the body of the function itself.",
+		Example:     "integer function F(n);\nbegin\n  integer n;\n  F := n\nend;\nF := 1      <- outside the body of F",
+	},
+	S011: {
+		Title:       "indexed variable is not an array",
+		Message:     "Variable '%s' is not an array",
+		Explanation: "Only variables declared with a size, such as integer a[10], can be indexed.",
+		Example:     "integer k;\n...\nk[1] := 0",
+	},
+	S012: {
+		Title:       "array used without an index",
+		Message:     "Array '%s' must be indexed",
+		Explanation: "Arrays can only be read, written and assigned one element at a time, so every use of an array needs an index in brackets.",
+		Example:     "integer a[10];\n...\nread(a)      <- read(a[0])",
+	},
+	S013: {
+		Title:       "index out of bounds",
+		Message:     "Index %d is out of bounds for array '%s' of %d elements",
+		Explanation: "The elements of an array declared as integer a[n] are a[0] to a[n-1]. Constant indices are checked at compile time.",
+		Example:     "integer a[10];\n...\na[10] := 0      <- the last element is a[9]",
+	},
+	S014: {
+		Title:       "array size not positive",
+		Message:     "Array '%s' must have at least one element",
+		Explanation: "The size in an array declaration is the number of elements and must be greater than zero.",
+		Example:     "integer a[0];",
+	},
+	S015: {
+		Title:       "duplicate case label",
+		Message:     "Case label %s appears more than once",
+		Explanation: "Each value may label only one branch of a case statement, otherwise it would be unclear which branch runs.",
+		Example:     "case k of\n  1: write(k);\n  1: read(k)      <- label 1 is already used\nend",
+	},
+	S016: {
+		Title:       "definition does not match forward declaration",
+		Message:     "Definition of '%s' does not match its forward declaration",
+		Explanation: "A function declared with forward must later be defined as the same kind of function, with the same parameter passed the same way.",
+		Example:     "integer function F(n); forward;\n...\nprocedure F(var n);      <- should be integer function F(n)",
+	},
+	S017: {
+		Title:       "forward declaration never defined",
+		Message:     "Function '%s' is declared forward but never defined",
+		Explanation: "Every forward declaration must be followed by a definition with a body later in the same block.",
+		Example:     "integer function F(n); forward;      <- no definition of F follows",
+	},
+	W001: {
+		Title:       "unused variable",
+		Message:     "Variable '%s' is declared but never used",
+		Explanation: "The variable is declared but never referenced. Remove the declaration or use the variable.",
+		Example:     "begin\n  integer k;\n  integer m;      <- 'm' is never used\n  read(k);\n  write(k)\nend",
+		Category:    "unused-variable",
+	},
+	W002: {
+		Title:       "unused parameter",
+		Message:     "Parameter '%s' of '%s' is never used",
+		Explanation: "The function never reads its parameter, so the argument passed by the caller has no effect.",
+		Example:     "integer function F(n);\nbegin\n  integer n;\n  F := 1      <- 'n' is never used\nend;",
+		Category:    "unused-parameter",
+	},
+	W003: {
+		Title:       "function result assigned outside its body",
+		Message:     "Assignment to function '%s' outside its own body",
+		Explanation: "Assigning to a function name sets that function's result, which only makes sense inside the function itself. This is now the error S010, and W003 is no longer reported.",
+		Example:     "integer function F(n);\nbegin\n  integer n;\n  F := n\nend;\nF := 1      <- outside the body of F",
+		Category:    "function-result",
+	},
+	W004: {
+		Title:       "unused procedure",
+		Message:     "Procedure '%s' is declared but never called",
+		Explanation: "The procedure is declared but never called. Remove it or call it.",
+		Example:     "begin\n  integer k;\n  integer function F(n);      <- 'F' is never called\n  begin\n    integer n;\n    F := n\n  end;\n  read(k);\n  write(k)\nend",
+		Category:    "unused-procedure",
+	},
+	W005: {
+		Title:       "variable used before assignment",
+		Message:     "Variable '%s' may be used before it is assigned",
+		Explanation: "On at least one path through the procedure the variable is read before any assignment or read() gives it a value, so its content is undefined.",
+		Example:     "begin\n  integer k;\n  integer m;\n  read(k);\n  if k > 0 then m := 1 else k := 0;\n  write(m)      <- 'm' is unassigned when k <= 0\nend",
+		Category:    "uninitialized",
+	},
+	W006: {
+		Title:       "identifier truncated",
+		Message:     "Identifier '%s' is longer than %d characters and was truncated to '%s'",
+		Explanation: "With --truncate-idents, identifiers longer than --max-ident-len are cut to that length instead of being rejected, as some reference implementations do. Two long names that share their first characters then denote the same variable.",
+		Example:     "integer averageOfAllTheScores;      <- treated as 'averageOfAllTheS'",
+	},
+	W007: {
+		Title:       "function result not assigned",
+		Message:     "Function '%s' may end without assigning its result",
+		Explanation: "A function returns the value last assigned to its name. On at least one path through the body no such assignment happens, so the result is undefined.",
+		Example:     "integer function F(n);\nbegin\n  integer n;\n  if n > 0 then F := n else n := 0      <- F is unassigned when n <= 0\nend;",
+		Category:    "function-result",
+	},
+	W008: {
+		Title:       "naming convention",
+		Message:     "Name '%s' does not match the convention %s for %s",
+		Explanation: "The naming rule of the lint command checks the names of variables and of procedures against the patterns set in compiler.toml, by default lowerCamelCase for variables and UpperCamelCase for procedures and functions.",
+		Example:     "integer Total;      <- write total",
+	},
+	W009: {
+		Title:       "shadowed variable",
+		Message:     "Variable '%s' of '%s' shadows the one of '%s' declared on line %d",
+		Explanation: "A variable or parameter of a procedure has the name of a variable of an enclosing block, which can then no longer be used inside the procedure. Renaming one of them makes clear which is meant.",
+		Example:     "begin\n  integer k;\n  integer function F(n);\n  begin\n    integer n;\n    integer k;      <- hides the k of the program\n    ...",
+	},
+	W010: {
+		Title:       "deep nesting",
+		Message:     "Statement is nested %d deep, more than %d",
+		Explanation: "if, case and loop statements are nested deeper than the limit set in compiler.toml. Moving the inner part into a procedure of its own makes it easier to follow.",
+		Example:     "while ... do\n  if ... then\n    for ... do\n      if ... then      <- fourth level",
+	},
+	W011: {
+		Title:       "long procedure",
+		Message:     "'%s' has %d statements, more than %d",
+		Explanation: "The body of the program, a procedure or a function has more statements than the limit set in compiler.toml, counting the ones inside if, case and loop statements but not those of nested procedures. Splitting it into smaller procedures makes it easier to follow.",
+		Example:     "",
+	},
+	W012: {
+		Title:       "magic number",
+		Message:     "Number %d is used directly",
+		Explanation: "A number other than those allowed in compiler.toml appears in an expression. The language has no constants, so this rule is off unless enabled; a variable assigned once near the start can name the number instead.",
+		Example:     "if k > 86400 then ...      <- what is 86400?",
+	},
+	W013: {
+		Title:       "unreachable procedure",
+		Message:     "Procedure '%s' is never called from the main program",
+		Explanation: "The procedure is called, but only by itself or by other procedures that the main program never calls, directly or through other procedures. It can never run, so it can be removed with its callers.",
+		Example:     "integer function G(n);      <- only called by itself\nbegin\n  integer n;\n  G := G(n - 1)\nend;",
+		Category:    "unused-procedure",
+	},
+}
+
+// Message renders the short message for the code in the current language
+func (c Code) Message(args ...any) string {
+	return fmt.Sprintf(lookup(c).Message, args...)
+}
+
+// Format renders the short message prefixed with the code itself
+func (c Code) Format(args ...any) string {
+	return fmt.Sprintf("[%s] %s", c, c.Message(args...))
+}
+
+// Title returns the one-line summary of the code
+func (c Code) Title() string {
+	return lookup(c).Title
+}
+
+// Explain returns the long description of a diagnostic code
+func Explain(code string) (string, error) {
+	c := Code(strings.ToUpper(code))
+	if _, ok := catalog[c]; !ok {
+		return "", fmt.Errorf("unknown diagnostic code '%s'", code)
+	}
+	e := lookup(c)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s: %s\n\n%s\n", c, e.Title, e.Explanation))
+	if e.Example != "" {
+		sb.WriteString("\n" + Term("Example") + ":\n")
+		for _, line := range strings.Split(e.Example, "\n") {
+			sb.WriteString("    " + line + "\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// Codes returns every known diagnostic code in order
+func Codes() []Code {
+	codes := make([]Code, 0, len(catalog))
+	for c := range catalog {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
@@ -0,0 +1,215 @@
+package diag
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Severity ranks a diagnostic
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return "error"
+}
+
+// Pos is a position in the source file
+type Pos struct {
+	File   string // included file, empty for the compiled source
+	Line   int
+	Column int // 1-based, 0 if unknown
+}
+
+func (p Pos) String() string {
+	if p.Column == 0 {
+		return fmt.Sprintf("%d", p.Line)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Range is the part of the source from Start up to End, which is exclusive;
+// an insertion has the same start and end
+type Range struct {
+	Start, End Pos
+}
+
+// Fix is an edit of the source that corrects a diagnostic mechanically
+type Fix struct {
+	Range   Range
+	NewText string
+}
+
+// Phase names the compiler phase that reported a diagnostic
+type Phase string
+
+const (
+	LexerPhase  Phase = "lexer"
+	ParserPhase Phase = "parser"
+	OutputPhase Phase = "output" // writing the artifacts
+	LintPhase   Phase = "lint"   // the rules of the lint command
+)
+
+// Diagnostic is a single message attached to a source position
+type Diagnostic struct {
+	Phase    Phase // set by the Reporter
+	Pos      Pos
+	Severity Severity
+	Code     Code // empty for internal failures
+	Msg      string
+	Notes    []string // supplementary remarks, rendered below the message
+	Fatal    bool     // parsing stopped at this diagnostic
+	Fixes    []Fix    // edits that together correct the problem, none if it has no safe fix
+}
+
+// New creates an error diagnostic with the localized message for code
+func New(line int, code Code, args ...any) Diagnostic {
+	return Diagnostic{Pos: Pos{Line: line}, Severity: Error, Code: code, Msg: code.Message(args...)}
+}
+
+// NewWarning creates a warning diagnostic for code, promoted to an error
+// under -Werror. The second result is false if the warning's category is
+// not enabled.
+func NewWarning(line int, code Code, args ...any) (Diagnostic, bool) {
+	if category := lookup(code).Category; category != "" && !enabled[category] {
+		return Diagnostic{}, false
+	}
+	d := New(line, code, args...)
+	if !warningsAsErrors {
+		d.Severity = Warning
+	}
+	return d, true
+}
+
+// NewFatal creates a fatal error that is not tied to a source position
+func NewFatal(err error) Diagnostic {
+	return Diagnostic{Severity: Error, Msg: err.Error(), Fatal: true}
+}
+
+// WithNote returns a copy of d with a localized note appended
+func (d Diagnostic) WithNote(format string, args ...any) Diagnostic {
+	d.Notes = append(slices.Clone(d.Notes), fmt.Sprintf(Term(format), args...))
+	return d
+}
+
+// WithFixes returns a copy of d with fixes appended
+func (d Diagnostic) WithFixes(fixes ...Fix) Diagnostic {
+	d.Fixes = append(slices.Clone(d.Fixes), fixes...)
+	return d
+}
+
+func (d Diagnostic) String() string {
+	if d.Pos.Line == 0 {
+		return d.summary() // not tied to the source, such as an I/O failure
+	}
+	if d.Pos.File != "" {
+		return fmt.Sprintf("***LINE %d (%s): %s", d.Pos.Line, d.Pos.File, d.summary())
+	}
+	return fmt.Sprintf("***LINE %d: %s", d.Pos.Line, d.summary())
+}
+
+// summary renders the diagnostic without its position
+func (d Diagnostic) summary() string {
+	var sb strings.Builder
+	if d.Severity != Error {
+		sb.WriteString(Term(d.Severity.String()) + ": ")
+	}
+	if d.Code != "" {
+		sb.WriteString(fmt.Sprintf("[%s] ", d.Code))
+	}
+	sb.WriteString(d.Msg)
+	if d.Fatal {
+		sb.WriteString(" [FATAL]")
+	}
+	return sb.String()
+}
+
+// Error lets a diagnostic be raised through panic and error values
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+// Sort orders diagnostics by file, line and column, keeping the order of
+// diagnostics reported at the same position. The compiled source comes
+// before included files.
+func Sort(diagnostics []Diagnostic) {
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		if a.Pos.File != b.Pos.File {
+			return strings.Compare(a.Pos.File, b.Pos.File)
+		}
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line - b.Pos.Line
+		}
+		return a.Pos.Column - b.Pos.Column
+	})
+}
+
+// Limit keeps the diagnostics up to and including the max-th error, and
+// reports whether any were dropped. A max of 0 keeps everything.
+func Limit(diagnostics []Diagnostic, max int) ([]Diagnostic, bool) {
+	if max <= 0 {
+		return diagnostics, false
+	}
+	errors := 0
+	for i, d := range diagnostics {
+		if d.Severity == Error {
+			errors++
+		}
+		if errors == max {
+			return diagnostics[:i+1], i+1 < len(diagnostics)
+		}
+	}
+	return diagnostics, false
+}
+
+var (
+	enabled          = map[string]bool{}
+	warningsAsErrors bool
+)
+
+// EnableWarnings turns on the named warning categories; "all" enables every category
+func EnableWarnings(categories ...string) error {
+	known := Categories()
+	for _, category := range categories {
+		if category == "all" {
+			for _, c := range known {
+				enabled[c] = true
+			}
+			continue
+		}
+		if !slices.Contains(known, category) {
+			return fmt.Errorf("unknown warning category '%s', expected one of: all, %s",
+				category, strings.Join(known, ", "))
+		}
+		enabled[category] = true
+	}
+	return nil
+}
+
+// SetWarningsAsErrors makes enabled warnings count as errors
+func SetWarningsAsErrors(on bool) {
+	warningsAsErrors = on
+}
+
+// Categories returns the names of all warning categories in order
+func Categories() []string {
+	var categories []string
+	for _, e := range catalog {
+		if e.Category != "" && !slices.Contains(categories, e.Category) {
+			categories = append(categories, e.Category)
+		}
+	}
+	slices.Sort(categories)
+	return categories
+}
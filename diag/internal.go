@@ -0,0 +1,29 @@
+package diag
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// InternalError is raised through panic when the compiler itself fails. It
+// records where the failing phase was in the source, so that the bug can
+// be reproduced.
+type InternalError struct {
+	Phase Phase
+	Pos   Pos
+	Token string // token being processed, if known
+	Value any    // the original panic value
+	Stack []byte
+}
+
+// NewInternalError wraps the value recovered from a panic
+func NewInternalError(phase Phase, pos Pos, token string, value any) *InternalError {
+	if ice, ok := value.(*InternalError); ok {
+		return ice
+	}
+	return &InternalError{Phase: phase, Pos: pos, Token: token, Value: value, Stack: debug.Stack()}
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("internal compiler error in %s at line %d: %v", e.Phase, e.Pos.Line, e.Value)
+}
@@ -0,0 +1,83 @@
+package diag
+
+import "fmt"
+
+// Language selects the message catalog used to render diagnostics
+type Language string
+
+const (
+	English Language = "en"
+	Chinese Language = "zh"
+)
+
+var language = English
+
+// translations holds the non-English catalogs; missing fields fall back to English
+var translations = map[Language]map[Code]entry{
+	Chinese: catalogZh,
+}
+
+// terms translates the token names that appear inside messages
+var terms = map[Language]map[string]string{
+	Chinese: {
+		"identifier": "标识符",
+		"constant":   "常数",
+		"string":     "字符串",
+		"EOLN":       "行尾",
+		"EOF":        "文件结尾",
+		"Example":    "示例",
+		"warning":    "警告",
+		"note":       "注",
+		"or":         "或",
+
+		string(IncludeForbidden):  "此处不允许包含文件",
+		string(IncludeItself):     "文件包含了它自身",
+		string(IncludeMissing):    "文件不存在",
+		string(IncludeDenied):     "没有访问权限",
+		string(IncludeUnreadable): "文件无法打开",
+
+		"previously declared on line %d":                                     "先前声明于第 %d 行",
+		"previously used on line %d":                                         "先前使用于第 %d 行",
+		"forward declared on line %d":                                        "前置声明于第 %d 行",
+		"Pascal would write '(%s) and (%s)'; nest two if statements instead": "Pascal 中应写作 '(%s) and (%s)'，这里请改用两层嵌套的 if 语句",
+	},
+}
+
+// SetLanguage selects the catalog used by Format, Title and Explain
+func SetLanguage(lang Language) error {
+	if lang != English && lang != Chinese {
+		return fmt.Errorf("unknown language '%s', expected zh or en", lang)
+	}
+	language = lang
+	return nil
+}
+
+// Term translates a word used inside messages, returning it unchanged if
+// the current language has no translation
+func Term(word string) string {
+	if translated, ok := terms[language][word]; ok {
+		return translated
+	}
+	return word
+}
+
+func lookup(c Code) entry {
+	e := catalog[c]
+	translated, ok := translations[language][c]
+	if !ok {
+		return e
+	}
+	if translated.Title != "" {
+		e.Title = translated.Title
+	}
+	if translated.Message != "" {
+		e.Message = translated.Message
+	}
+	if translated.Explanation != "" {
+		e.Explanation = translated.Explanation
+	}
+	if translated.Example != "" {
+		e.Example = translated.Example
+	}
+	return e
+}
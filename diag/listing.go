@@ -0,0 +1,67 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ListingSink writes the numbered source listing with every diagnostic
+// printed beneath the line it belongs to, like classic Pascal compilers
+type ListingSink struct {
+	Source   string // full text of the compiled file
+	TabWidth int    // columns per tab stop, as used for the diagnostic columns
+}
+
+func (s ListingSink) Write(w io.Writer, diagnostics []Diagnostic) error {
+	lines := strings.Split(strings.TrimSuffix(s.Source, "\n"), "\n")
+	byLine := make(map[int][]Diagnostic)
+	for _, d := range diagnostics {
+		line := d.Pos.Line
+		if line < 1 || line > len(lines) || d.Pos.File != "" {
+			line = len(lines) // attach stray diagnostics, such as at EOF or in included files, to the last line
+		}
+		byLine[line] = append(byLine[line], d)
+	}
+
+	var sb strings.Builder
+	for i, text := range lines {
+		sb.WriteString(fmt.Sprintf("%5d  %s\n", i+1, text))
+		for _, d := range byLine[i+1] {
+			if d.Pos.File != "" {
+				sb.WriteString(fmt.Sprintf("*****  %s:%s: %s\n", d.Pos.File, d.Pos, d.summary()))
+			} else {
+				if d.Pos.Line == i+1 && d.Pos.Column > 0 {
+					sb.WriteString("       " + caretPadding(text, d.Pos.Column, s.TabWidth) + "^\n")
+				}
+				sb.WriteString("*****  " + d.summary() + "\n")
+			}
+			for _, note := range d.Notes {
+				sb.WriteString("       " + Term("note") + ": " + note + "\n")
+			}
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// caretPadding returns the blanks that put a caret under the given 1-based
+// display column of text, keeping tabs so that the caret lines up with the
+// source whatever tab width the reader uses
+func caretPadding(text string, column, tabWidth int) string {
+	var sb strings.Builder
+	current := 1
+	for _, r := range text {
+		if current >= column {
+			break
+		}
+		if r == '\t' && tabWidth > 0 {
+			sb.WriteRune('\t')
+			current = (current-1)/tabWidth*tabWidth + tabWidth + 1
+		} else {
+			sb.WriteRune(' ')
+			current++
+		}
+	}
+	return sb.String()
+}
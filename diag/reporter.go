@@ -0,0 +1,93 @@
+package diag
+
+import "slices"
+
+// Reporter collects the diagnostics of every phase of a compilation, so
+// that lexer and parser contribute to one ordered report
+type Reporter struct {
+	diagnostics []Diagnostic
+	errors      int
+	maxErrors   int
+	phase       Phase
+}
+
+// NewReporter creates a Reporter that considers itself full after
+// maxErrors errors; a maxErrors of 0 means no limit
+func NewReporter(maxErrors int) *Reporter {
+	return &Reporter{maxErrors: maxErrors}
+}
+
+// StartPhase attributes the diagnostics reported from now on to phase
+func (r *Reporter) StartPhase(phase Phase) {
+	r.phase = phase
+}
+
+// Report records a diagnostic for the current phase
+func (r *Reporter) Report(d Diagnostic) {
+	d.Phase = r.phase
+	r.diagnostics = append(r.diagnostics, d)
+	if d.Severity == Error {
+		r.errors++
+	}
+}
+
+// AddFixes appends fixes to the i-th diagnostic reported, such as those of
+// a diagnostic left out in favor of it
+func (r *Reporter) AddFixes(i int, fixes ...Fix) {
+	r.diagnostics[i] = r.diagnostics[i].WithFixes(fixes...)
+}
+
+// TrimFixes keeps the first n fixes of the i-th diagnostic reported,
+// dropping those added later with AddFixes
+func (r *Reporter) TrimFixes(i, n int) {
+	d := &r.diagnostics[i]
+	d.Fixes = slices.Clip(d.Fixes[:n])
+}
+
+// ErrorCount returns the number of errors reported so far
+func (r *Reporter) ErrorCount() int {
+	return r.errors
+}
+
+// Len returns the number of diagnostics reported so far
+func (r *Reporter) Len() int {
+	return len(r.diagnostics)
+}
+
+// Rewind drops the diagnostics reported after the first n, as when part of
+// the input is analyzed again
+func (r *Reporter) Rewind(n int) {
+	r.diagnostics = r.diagnostics[:n]
+	r.errors = 0
+	for _, d := range r.diagnostics {
+		if d.Severity == Error {
+			r.errors++
+		}
+	}
+}
+
+// Full reports whether the error limit has been reached, after which
+// phases should stop looking for further problems
+func (r *Reporter) Full() bool {
+	return r.maxErrors > 0 && r.errors >= r.maxErrors
+}
+
+// Diagnostics returns the reported diagnostics ordered by position and cut
+// off after the max-th error. The second result reports whether any were
+// dropped.
+func (r *Reporter) Diagnostics() ([]Diagnostic, bool) {
+	diagnostics := append([]Diagnostic(nil), r.diagnostics...)
+	Sort(diagnostics)
+	return Limit(diagnostics, r.maxErrors)
+}
+
+// Filter returns the diagnostics reported by phase, keeping their order
+func Filter(diagnostics []Diagnostic, phase Phase) []Diagnostic {
+	var filtered []Diagnostic
+	for _, d := range diagnostics {
+		if d.Phase == phase {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
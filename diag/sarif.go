@@ -0,0 +1,133 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// SARIFSink writes diagnostics as a SARIF 2.1.0 log, which CI services such
+// as GitHub code scanning turn into inline annotations
+type SARIFSink struct {
+	Source string // path of the compiled file, relative to the repository root
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	RuleIndex *int            `json:"ruleIndex,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func (s SARIFSink) Write(w io.Writer, diagnostics []Diagnostic) error {
+	// Only the rules that actually fired are described, in code order
+	var codes []Code
+	for _, d := range diagnostics {
+		if d.Code != "" && !slices.Contains(codes, d.Code) {
+			codes = append(codes, d.Code)
+		}
+	}
+	slices.Sort(codes)
+
+	rules := make([]sarifRule, 0, len(codes))
+	for _, c := range codes {
+		e := lookup(c)
+		rules = append(rules, sarifRule{
+			ID:               string(c),
+			ShortDescription: sarifMessage{Text: e.Title},
+			FullDescription:  sarifMessage{Text: e.Explanation},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		text := d.Msg
+		if len(d.Notes) > 0 {
+			text += "\n" + strings.Join(d.Notes, "\n")
+		}
+		uri := s.Source
+		if d.Pos.File != "" {
+			uri = d.Pos.File
+		}
+		result := sarifResult{
+			RuleID:  string(d.Code),
+			Level:   d.Severity.String(),
+			Message: sarifMessage{Text: text},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: uri},
+					Region:           sarifRegion{StartLine: d.Pos.Line, StartColumn: d.Pos.Column},
+				},
+			}},
+		}
+		if i := slices.Index(codes, d.Code); i >= 0 {
+			result.RuleIndex = &i
+		}
+		results = append(results, result)
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "compiler", Rules: rules}},
+			Results: results,
+		}},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,147 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Sink renders a list of diagnostics
+type Sink interface {
+	Write(w io.Writer, diagnostics []Diagnostic) error
+}
+
+// NewSink returns the sink for a --format value. source is the compiled
+// file as named in SARIF locations; color only affects the text format.
+func NewSink(format string, source string, color bool) (Sink, error) {
+	switch format {
+	case "text":
+		return TTYSink{Color: color}, nil
+	case "json":
+		return JSONSink{}, nil
+	case "sarif":
+		return SARIFSink{Source: source}, nil
+	}
+	return nil, fmt.Errorf("unknown diagnostic format '%s', expected text, json or sarif", format)
+}
+
+// TextSink writes the plain "***LINE n: ..." report stored in output.err
+type TextSink struct{}
+
+func (TextSink) Write(w io.Writer, diagnostics []Diagnostic) error {
+	var lines []string
+	for _, d := range diagnostics {
+		lines = append(lines, d.String())
+		for _, note := range d.Notes {
+			lines = append(lines, "    "+Term("note")+": "+note)
+		}
+	}
+	_, err := io.WriteString(w, joinRecords(lines))
+	return err
+}
+
+// joinRecords terminates every record with a newline, so that a report
+// without records is an empty file
+func joinRecords(records []string) string {
+	if len(records) == 0 {
+		return ""
+	}
+	return strings.Join(records, "\n") + "\n"
+}
+
+// TTYSink lists diagnostics for a terminal, numbering errors and warnings
+// separately and optionally highlighting them with ANSI colors
+type TTYSink struct {
+	Color bool
+}
+
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[1;31m"
+	ansiYellow = "\033[1;33m"
+	ansiCyan   = "\033[36m"
+)
+
+func (s TTYSink) Write(w io.Writer, diagnostics []Diagnostic) error {
+	errors, warnings := 0, 0
+	for _, d := range diagnostics {
+		var label string
+		if d.Severity == Error {
+			errors++
+			label = s.paint(ansiRed, fmt.Sprintf("Error %d:", errors))
+		} else {
+			warnings++
+			label = s.paint(ansiYellow, fmt.Sprintf("Warning %d:", warnings))
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", label, d); err != nil {
+			return err
+		}
+		for _, note := range d.Notes {
+			if _, err := fmt.Fprintf(w, "    %s %s\n", s.paint(ansiCyan, Term("note")+":"), note); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s TTYSink) paint(color, text string) string {
+	if !s.Color {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// JSONSink writes diagnostics as a JSON array for editors and scripts
+type JSONSink struct{}
+
+type jsonDiagnostic struct {
+	File     string    `json:"file,omitempty"`
+	Line     int       `json:"line"`
+	Column   int       `json:"column,omitempty"`
+	Severity string    `json:"severity"`
+	Code     Code      `json:"code,omitempty"`
+	Message  string    `json:"message"`
+	Notes    []string  `json:"notes,omitempty"`
+	Fatal    bool      `json:"fatal,omitempty"`
+	Fixes    []jsonFix `json:"fixes,omitempty"`
+}
+
+// jsonFix is an edit of a fix, with the end of its range exclusive
+type jsonFix struct {
+	File        string `json:"file,omitempty"`
+	StartLine   int    `json:"startLine"`
+	StartColumn int    `json:"startColumn"`
+	EndLine     int    `json:"endLine"`
+	EndColumn   int    `json:"endColumn"`
+	NewText     string `json:"newText"`
+}
+
+func (JSONSink) Write(w io.Writer, diagnostics []Diagnostic) error {
+	list := make([]jsonDiagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		var fixes []jsonFix
+		for _, fix := range d.Fixes {
+			start, end := fix.Range.Start, fix.Range.End
+			fixes = append(fixes, jsonFix{start.File, start.Line, start.Column, end.Line, end.Column, fix.NewText})
+		}
+		list = append(list, jsonDiagnostic{
+			File:     d.Pos.File,
+			Line:     d.Pos.Line,
+			Column:   d.Pos.Column,
+			Severity: d.Severity.String(),
+			Code:     d.Code,
+			Message:  d.Msg,
+			Notes:    d.Notes,
+			Fatal:    d.Fatal,
+			Fixes:    fixes,
+		})
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,43 @@
+package emit
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"compiler/parser"
+)
+
+// CSV emits the symbol tables as CSV for spreadsheets; the other artifacts
+// keep the text formats
+type CSV struct {
+	Text
+}
+
+func (CSV) EmitSymbols(variables, procedures io.Writer, vars []parser.Variable, procs []parser.Procedure) error {
+	rows := [][]string{{"name", "procedure", "kind", "type", "size", "level", "address", "line", "references"}}
+	for _, v := range vars {
+		rows = append(rows, []string{v.Name, v.Procedure, strconv.Itoa(v.Kind), v.Type, strconv.Itoa(v.Size),
+			strconv.Itoa(v.Level), strconv.Itoa(v.Address), strconv.Itoa(v.Line), joinLines(v.References)})
+	}
+	if err := csv.NewWriter(variables).WriteAll(rows); err != nil {
+		return err
+	}
+
+	rows = [][]string{{"name", "type", "level", "parent", "first_variable", "last_variable", "line", "references"}}
+	for _, p := range procs {
+		rows = append(rows, []string{p.Name, p.Type, strconv.Itoa(p.Level), p.Parent,
+			strconv.Itoa(p.FirstVariableAddress), strconv.Itoa(p.LastVariableAddress), strconv.Itoa(p.Line), joinLines(p.References)})
+	}
+	return csv.NewWriter(procedures).WriteAll(rows)
+}
+
+// joinLines renders line numbers as a space separated list
+func joinLines(lines []int) string {
+	var fields []string
+	for _, line := range lines {
+		fields = append(fields, strconv.Itoa(line))
+	}
+	return strings.Join(fields, " ")
+}
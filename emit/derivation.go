@@ -0,0 +1,49 @@
+package emit
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"compiler/parser"
+)
+
+// Derivation writes the leftmost derivation of a concrete syntax tree for
+// output.drv. Each numbered step names the rule expanded at the leftmost
+// nonterminal and the sentential form it yields; nonterminals are written
+// as <rule> and tokens as they appear in the source.
+func Derivation(w io.Writer, tree *parser.Node) error {
+	form := []*parser.Node{tree}
+	lines := []string{"      " + sententialForm(form)}
+	for step := 1; ; step++ {
+		i := slices.IndexFunc(form, func(n *parser.Node) bool { return !n.IsLeaf() })
+		if i < 0 {
+			break
+		}
+		node := form[i]
+		form = slices.Concat(form[:i], node.Children, form[i+1:])
+
+		body := sententialForm(node.Children)
+		if body == "" {
+			body = "ε"
+		}
+		lines = append(lines,
+			fmt.Sprintf("%4d  <%s> -> %s", step, node.Rule, body),
+			"      => "+sententialForm(form))
+	}
+	_, err := io.WriteString(w, joinRecords(lines))
+	return err
+}
+
+func sententialForm(nodes []*parser.Node) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		if n.IsLeaf() {
+			parts[i] = n.Token.Value
+		} else {
+			parts[i] = "<" + n.Rule + ">"
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,46 @@
+package emit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"compiler/parser"
+)
+
+// CSTDot writes a concrete syntax tree as a Graphviz digraph for
+// output.cst.dot. Rules are ellipses and tokens are boxes, all of which are
+// placed on the bottom rank so that they read as the source left to right.
+func CSTDot(w io.Writer, tree *parser.Node) error {
+	lines := []string{"digraph cst {", "  ordering=out;", "  node [fontname=\"monospace\"];"}
+	var leaves []string
+	id := 0
+	var visit func(n *parser.Node) string
+	visit = func(n *parser.Node) string {
+		name := fmt.Sprintf("n%d", id)
+		id++
+		if n.IsLeaf() {
+			lines = append(lines, fmt.Sprintf("  %s [shape=box, label=%s];", name, dotString(n.Token.Value)))
+			leaves = append(leaves, name)
+			return name
+		}
+		lines = append(lines, fmt.Sprintf("  %s [shape=ellipse, label=%s];", name, dotString(n.Rule)))
+		for _, child := range n.Children {
+			lines = append(lines, fmt.Sprintf("  %s -> %s;", name, visit(child)))
+		}
+		return name
+	}
+	visit(tree)
+	if len(leaves) > 0 {
+		lines = append(lines, "  { rank=same; "+strings.Join(leaves, "; ")+"; }")
+	}
+	lines = append(lines, "}")
+
+	_, err := io.WriteString(w, joinRecords(lines))
+	return err
+}
+
+// dotString quotes s as a DOT string
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,529 @@
+package emit
+
+import (
+	"cmp"
+	"fmt"
+	"go/format"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"compiler/ast"
+)
+
+// GoSource writes prog as a Go program that behaves like it, for --emit=go.
+// Variables of the program become package variables and its procedures
+// functions, while nested procedures become closures over the variables of
+// the enclosing ones. The file is excluded from builds of the module it is
+// written into, but can be run with go run.
+func GoSource(w io.Writer, prog *ast.Program) error {
+	var g goWriter
+	src, err := format.Source([]byte(g.program(prog)))
+	if err != nil {
+		return fmt.Errorf("generated Go source: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GoPlaceholder writes the file of --emit=go for a program that has no
+// translation. It is excluded from builds like a translation, since an
+// empty file would break the package of the directory it is written into.
+func GoPlaceholder(w io.Writer) error {
+	_, err := io.WriteString(w, "//go:build ignore\n\n// Not translated: the program has errors.\n\npackage main\n")
+	return err
+}
+
+// goReserved holds the names a variable or procedure cannot keep in Go: its
+// keywords and predeclared identifiers, and the names the generated
+// program uses itself. Such a name gets a trailing '_', which no name of
+// the language has.
+var goReserved = make(map[string]bool)
+
+func init() {
+	for _, name := range strings.Fields(`
+		break case chan const continue default defer else fallthrough for func go goto if
+		import interface map package range return select struct switch type var
+		any bool byte comparable complex64 complex128 error float32 float64 int int8 int16
+		int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr true false iota nil
+		append cap clear close complex copy delete imag len make max min new panic print
+		println real recover
+		fmt os init main read result`) {
+		goReserved[name] = true
+	}
+}
+
+// goIdent returns the Go name of a variable or procedure
+func goIdent(name string) string {
+	if goReserved[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// goWriter translates a program into Go source
+type goWriter struct {
+	scopes []goScope
+	reads  bool // the program reads input, so it needs the read function
+	prints bool
+	bounds int // temporaries holding the final value of a for loop so far
+}
+
+// goScope holds the names declared in a block. Variables include the
+// parameter and the result of a function, which is assigned through its
+// name.
+type goScope struct {
+	variables  map[string]*goName
+	procedures map[string]*goName
+}
+
+// goName is a name of the program as it appears in the Go source
+type goName struct {
+	ident       string
+	byReference bool // a var parameter, or a procedure taking one
+	used        bool // read somewhere, as Go requires of local variables
+}
+
+func (g *goWriter) push() {
+	g.scopes = append(g.scopes, goScope{make(map[string]*goName), make(map[string]*goName)})
+}
+
+func (g *goWriter) pop() {
+	g.scopes = g.scopes[:len(g.scopes)-1]
+}
+
+func (g *goWriter) scope() goScope {
+	return g.scopes[len(g.scopes)-1]
+}
+
+// lookup returns the variable or procedure visible by name, or nil if it
+// is undeclared
+func (g *goWriter) lookup(name string, procedure bool) *goName {
+	for i := len(g.scopes) - 1; i >= 0; i-- {
+		names := g.scopes[i].variables
+		if procedure {
+			names = g.scopes[i].procedures
+		}
+		if n, ok := names[name]; ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// ident returns the Go name of a variable or procedure and marks it used
+func (g *goWriter) ident(name string, procedure bool) (string, bool) {
+	n := g.lookup(name, procedure)
+	if n == nil {
+		return goIdent(name), false
+	}
+	n.used = true
+	return n.ident, n.byReference
+}
+
+// declareProcedure declares a procedure in the current block and reports
+// whether it was declared forward before
+func (g *goWriter) declareProcedure(decl *ast.ProcDecl) (*goName, bool) {
+	if n, ok := g.scope().procedures[decl.Name]; ok {
+		return n, true
+	}
+	n := &goName{ident: goIdent(decl.Name), byReference: decl.ByReference}
+	g.scope().procedures[decl.Name] = n
+	return n, false
+}
+
+func (g *goWriter) program(prog *ast.Program) string {
+	g.push()
+	var decls strings.Builder
+	for _, decl := range prog.Body.Declarations {
+		switch decl := decl.(type) {
+		case *ast.VarDecl:
+			n := &goName{ident: goIdent(decl.Name)}
+			g.scope().variables[decl.Name] = n
+			fmt.Fprintf(&decls, "var %s %s\n", n.ident, goType(decl.Size))
+		case *ast.ProcDecl:
+			// Functions of the package may call each other in any order, so a
+			// forward declaration has nothing to write
+			n, _ := g.declareProcedure(decl)
+			if decl.Body != nil {
+				fmt.Fprintf(&decls, "\nfunc %s%s %s\n", n.ident, g.signature(decl), g.body(decl))
+			}
+		}
+	}
+	statements := g.statements(prog.Body.Statements)
+
+	var sb strings.Builder
+	sb.WriteString("//go:build ignore\n\n// Code generated by the compiler with --emit=go. DO NOT EDIT.\n\npackage main\n\n")
+	switch {
+	case g.reads:
+		sb.WriteString("import (\n\"fmt\"\n\"os\"\n)\n\n")
+	case g.prints:
+		sb.WriteString("import \"fmt\"\n\n")
+	}
+	sb.WriteString(decls.String())
+	fmt.Fprintf(&sb, "\nfunc main() {\n%s}\n", statements)
+	if g.reads {
+		sb.WriteString(goRead)
+	}
+	return sb.String()
+}
+
+// goRead is the function read statements call
+const goRead = `
+// read returns the next integer of the input, and ends the program if there
+// is none
+func read() int {
+	var n int
+	if _, err := fmt.Scan(&n); err != nil {
+		fmt.Fprintln(os.Stderr, "read:", err)
+		os.Exit(1)
+	}
+	return n
+}
+`
+
+// goType returns the Go type of a variable, or of an array of size elements
+func goType(size int) string {
+	if size > 0 {
+		return fmt.Sprintf("[%d]int", size)
+	}
+	return "int"
+}
+
+// signature returns the parameters and the named result of a procedure
+func (g *goWriter) signature(decl *ast.ProcDecl) string {
+	param := ""
+	if decl.Param != "" {
+		param = goIdent(decl.Param) + " int"
+		if decl.ByReference {
+			param = goIdent(decl.Param) + " *int"
+		}
+	}
+	if decl.Result == "void" {
+		return "(" + param + ")"
+	}
+	return "(" + param + ") (result int)"
+}
+
+// funcType returns the type of the closure of a nested procedure
+func funcType(decl *ast.ProcDecl) string {
+	param := ""
+	if decl.Param != "" {
+		param = "int"
+		if decl.ByReference {
+			param = "*int"
+		}
+	}
+	if decl.Result == "void" {
+		return "func(" + param + ")"
+	}
+	return "func(" + param + ") int"
+}
+
+// body returns the block of a procedure. Its local variables and closures
+// are declared in the order of the source, so that a closure sees the same
+// variables as the procedure it comes from.
+func (g *goWriter) body(decl *ast.ProcDecl) string {
+	g.push()
+	defer g.pop()
+	if decl.Param != "" {
+		g.scope().variables[decl.Param] = &goName{ident: goIdent(decl.Param), byReference: decl.ByReference}
+	}
+	function := decl.Result != "void"
+	if function {
+		g.scope().variables[decl.Name] = &goName{ident: "result"}
+	}
+
+	var decls strings.Builder
+	var locals []*goName
+	for _, d := range decl.Body.Declarations {
+		switch d := d.(type) {
+		case *ast.VarDecl:
+			if d.Name == decl.Param {
+				continue // the parameter, declared again in the body
+			}
+			n := &goName{ident: goIdent(d.Name)}
+			g.scope().variables[d.Name] = n
+			locals = append(locals, n)
+			fmt.Fprintf(&decls, "var %s %s\n", n.ident, goType(d.Size))
+		case *ast.ProcDecl:
+			n, forward := g.declareProcedure(d)
+			switch {
+			case d.Body == nil:
+				locals = append(locals, n)
+				fmt.Fprintf(&decls, "var %s %s\n", n.ident, funcType(d))
+			case forward:
+				fmt.Fprintf(&decls, "%s = func%s %s\n", n.ident, g.signature(d), g.body(d))
+			case calls(d.Body, d.Name):
+				// A closure can only call itself through a variable declared before it
+				locals = append(locals, n)
+				fmt.Fprintf(&decls, "var %s %s\n%[1]s = func%[3]s %[4]s\n", n.ident, funcType(d), g.signature(d), g.body(d))
+			default:
+				locals = append(locals, n)
+				fmt.Fprintf(&decls, "%s := func%s %s\n", n.ident, g.signature(d), g.body(d))
+			}
+		}
+	}
+	statements := g.statements(decl.Body.Statements)
+
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	sb.WriteString(decls.String())
+	for _, n := range locals {
+		if !n.used {
+			fmt.Fprintf(&sb, "_ = %s\n", n.ident)
+		}
+	}
+	sb.WriteString(statements)
+	if function {
+		sb.WriteString("return\n")
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// calls reports whether body, nested procedures included, calls the
+// procedure name
+func calls(body *ast.Block, name string) bool {
+	found := false
+	ast.Inspect(body, func(c *ast.CallExpr) bool {
+		found = found || c.Name == name
+		return !found
+	})
+	return found
+}
+
+func (g *goWriter) statements(stmts []ast.Stmt) string {
+	var sb strings.Builder
+	for _, stmt := range stmts {
+		g.statement(&sb, stmt)
+	}
+	return sb.String()
+}
+
+func (g *goWriter) statement(sb *strings.Builder, stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		fmt.Fprintf(sb, "%s = %s\n", g.target(s.Target), g.expr(s.Value, 0))
+	case *ast.ReadStmt:
+		g.reads = true
+		for _, target := range s.Targets {
+			fmt.Fprintf(sb, "%s = read()\n", g.target(target))
+		}
+	case *ast.WriteStmt:
+		g.prints = true
+		g.write(sb, s)
+	case *ast.CallStmt:
+		fmt.Fprintf(sb, "%s\n", g.call(s.Call))
+	case *ast.IfStmt:
+		g.ifStatement(sb, s)
+		sb.WriteString("\n")
+	case *ast.CaseStmt:
+		fmt.Fprintf(sb, "switch %s {\n", g.expr(s.Subject, 0))
+		for _, branch := range s.Branches {
+			labels := make([]string, len(branch.Labels))
+			for i, label := range branch.Labels {
+				labels[i] = strconv.Itoa(label)
+			}
+			fmt.Fprintf(sb, "case %s:\n", strings.Join(labels, ", "))
+			g.optional(sb, branch.Body)
+		}
+		if s.Else != nil {
+			sb.WriteString("default:\n")
+			g.statement(sb, s.Else)
+		}
+		sb.WriteString("}\n")
+	case *ast.WhileStmt:
+		fmt.Fprintf(sb, "for %s {\n", g.expr(s.Cond, 0))
+		g.optional(sb, s.Body)
+		sb.WriteString("}\n")
+	case *ast.RepeatStmt:
+		sb.WriteString("for {\n")
+		sb.WriteString(g.statements(s.Body))
+		fmt.Fprintf(sb, "if %s {\nbreak\n}\n}\n", g.expr(s.Cond, 0))
+	case *ast.ForStmt:
+		g.forStatement(sb, s)
+	}
+}
+
+// optional writes a statement that may be missing, as the body of a loop
+// or a branch can be
+func (g *goWriter) optional(sb *strings.Builder, stmt ast.Stmt) {
+	if stmt != nil {
+		g.statement(sb, stmt)
+	}
+}
+
+// ifStatement writes an if statement without the final line break, with an
+// if statement in the else branch as an else if
+func (g *goWriter) ifStatement(sb *strings.Builder, s *ast.IfStmt) {
+	fmt.Fprintf(sb, "if %s {\n", g.expr(s.Cond, 0))
+	g.optional(sb, s.Then)
+	sb.WriteString("}")
+	switch e := s.Else.(type) {
+	case nil:
+	case *ast.IfStmt:
+		sb.WriteString(" else ")
+		g.ifStatement(sb, e)
+	default:
+		sb.WriteString(" else {\n")
+		g.statement(sb, e)
+		sb.WriteString("}")
+	}
+}
+
+// forStatement writes a for loop. Its final value is computed once before
+// the loop, like the source does, unless it cannot change meanwhile.
+func (g *goWriter) forStatement(sb *strings.Builder, s *ast.ForStmt) {
+	counter := g.target(s.Counter)
+	from := g.expr(s.From, 0)
+	last := g.expr(s.To, 0)
+	if !constantBound(s) {
+		g.bounds++
+		fmt.Fprintf(sb, "to_%d := %s\n", g.bounds, last)
+		last = fmt.Sprintf("to_%d", g.bounds)
+	}
+	fmt.Fprintf(sb, "for %[1]s = %[2]s; %[1]s <= %[3]s; %[1]s++ {\n", counter, from, last)
+	g.optional(sb, s.Body)
+	sb.WriteString("}\n")
+}
+
+// constantBound reports whether the final value of a for loop is a number
+// or a variable the loop does not assign, directly or through a call
+func constantBound(s *ast.ForStmt) bool {
+	switch to := s.To.(type) {
+	case *ast.IntLit:
+		return true
+	case *ast.VarRef:
+		if to.Index != nil || to.Name == s.Counter.Name {
+			return false
+		}
+		assigned := false
+		ast.Inspect(s.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				assigned = true
+			case *ast.AssignStmt:
+				assigned = assigned || n.Target.Name == to.Name
+			case *ast.ReadStmt:
+				assigned = assigned || slices.ContainsFunc(n.Targets, func(v *ast.VarRef) bool { return v.Name == to.Name })
+			case *ast.ForStmt:
+				assigned = assigned || n.Counter.Name == to.Name
+			}
+			return !assigned
+		})
+		return !assigned
+	}
+	return false
+}
+
+// write writes a write or writeln statement as a single call of fmt
+func (g *goWriter) write(sb *strings.Builder, s *ast.WriteStmt) {
+	var text, format strings.Builder
+	var args []string
+	for _, arg := range s.Args {
+		if str, ok := arg.(*ast.StringLit); ok {
+			text.WriteString(str.Value)
+			format.WriteString(strings.ReplaceAll(str.Value, "%", "%%"))
+			continue
+		}
+		format.WriteString("%d")
+		args = append(args, g.expr(arg, 0))
+	}
+
+	if len(args) > 0 {
+		if s.Newline {
+			format.WriteString("\n")
+		}
+		fmt.Fprintf(sb, "fmt.Printf(%s, %s)\n", strconv.Quote(format.String()), strings.Join(args, ", "))
+		return
+	}
+	print := "Print"
+	if s.Newline {
+		print = "Println"
+	}
+	quoted := ""
+	if text.Len() > 0 {
+		quoted = strconv.Quote(text.String())
+	}
+	fmt.Fprintf(sb, "fmt.%s(%s)\n", print, quoted)
+}
+
+// target returns a variable, array element or function result as the left
+// side of an assignment
+func (g *goWriter) target(v *ast.VarRef) string {
+	n := g.lookup(v.Name, false)
+	ident := goIdent(v.Name)
+	if n != nil {
+		ident = n.ident
+		if n.byReference {
+			ident = "*" + ident
+		}
+	}
+	if v.Index != nil {
+		return fmt.Sprintf("%s[%s]", ident, g.expr(v.Index, 0))
+	}
+	return ident
+}
+
+// goOperators maps the operators of the language that Go spells otherwise
+var goOperators = map[string]string{"mod": "%", "div": "/", "=": "==", "<>": "!="}
+
+// goPrecedence returns the precedence of a Go binary operator
+func goPrecedence(op string) int {
+	switch op {
+	case "*", "/", "%":
+		return 5
+	case "-":
+		return 4
+	}
+	return 3
+}
+
+// expr returns an expression, in parentheses if its operator binds less
+// tightly than min
+func (g *goWriter) expr(e ast.Expr, min int) string {
+	switch e := e.(type) {
+	case *ast.IntLit:
+		return strconv.Itoa(e.Value)
+	case *ast.VarRef:
+		ident, byReference := g.ident(e.Name, false)
+		if byReference {
+			ident = "*" + ident
+		}
+		if e.Index != nil {
+			return fmt.Sprintf("%s[%s]", ident, g.expr(e.Index, 0))
+		}
+		return ident
+	case *ast.CallExpr:
+		return g.call(e)
+	case *ast.UnaryExpr:
+		return e.Op + g.expr(e.Operand, 6)
+	case *ast.BinaryExpr:
+		op := cmp.Or(goOperators[e.Op], e.Op)
+		prec := goPrecedence(op)
+		// The operators are left associative, so a right operand of the same
+		// precedence needs parentheses
+		s := fmt.Sprintf("%s %s %s", g.expr(e.Left, prec), op, g.expr(e.Right, prec+1))
+		if prec < min {
+			return "(" + s + ")"
+		}
+		return s
+	}
+	return ""
+}
+
+// call returns a call, passing the address of the argument to a var
+// parameter
+func (g *goWriter) call(c *ast.CallExpr) string {
+	ident, byReference := g.ident(c.Name, true)
+	if v, ok := c.Arg.(*ast.VarRef); ok && byReference {
+		if n := g.lookup(v.Name, false); n != nil && n.byReference && v.Index == nil {
+			n.used = true
+			return fmt.Sprintf("%s(%s)", ident, n.ident) // already a pointer
+		}
+		return fmt.Sprintf("%s(&%s)", ident, g.expr(v, 0))
+	}
+	return fmt.Sprintf("%s(%s)", ident, g.expr(c.Arg, 0))
+}
@@ -0,0 +1,98 @@
+package emit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+
+	"compiler/diag"
+	"compiler/parser"
+	"compiler/token"
+)
+
+// JSON emits every artifact as indented JSON
+type JSON struct{}
+
+// EmitTokens writes the array one token at a time so that the lexer can
+// stream into it
+func (JSON) EmitTokens(w io.Writer, tokens iter.Seq[token.Token]) error {
+	out := bufio.NewWriter(w)
+	separator := "[\n  "
+	for tok := range tokens {
+		data, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		out.WriteString(separator)
+		out.Write(data)
+		separator = ",\n  "
+	}
+	if separator == "[\n  " {
+		out.WriteString("[")
+	}
+	out.WriteString("\n]\n")
+	return out.Flush()
+}
+
+type jsonVariable struct {
+	Name       string `json:"name"`
+	Procedure  string `json:"procedure"`
+	Kind       int    `json:"kind"`
+	Type       string `json:"type"`
+	Size       int    `json:"size,omitempty"`
+	Level      int    `json:"level"`
+	Address    int    `json:"address"`
+	Line       int    `json:"line"`
+	References []int  `json:"references"`
+}
+
+type jsonProcedure struct {
+	Name                 string `json:"name"`
+	Type                 string `json:"type"`
+	Level                int    `json:"level"`
+	Parent               string `json:"parent"`
+	FirstVariableAddress int    `json:"firstVariable"`
+	LastVariableAddress  int    `json:"lastVariable"`
+	Line                 int    `json:"line"`
+	References           []int  `json:"references"`
+}
+
+func (JSON) EmitSymbols(variables, procedures io.Writer, vars []parser.Variable, procs []parser.Procedure) error {
+	varList := make([]jsonVariable, 0, len(vars))
+	for _, v := range vars {
+		varList = append(varList, jsonVariable{v.Name, v.Procedure, v.Kind, v.Type, v.Size, v.Level, v.Address, v.Line, nonNil(v.References)})
+	}
+	if err := writeJSON(variables, varList); err != nil {
+		return err
+	}
+
+	procList := make([]jsonProcedure, 0, len(procs))
+	for _, p := range procs {
+		procList = append(procList, jsonProcedure{p.Name, p.Type, p.Level, p.Parent,
+			p.FirstVariableAddress, p.LastVariableAddress, p.Line, nonNil(p.References)})
+	}
+	return writeJSON(procedures, procList)
+}
+
+func (JSON) EmitErrors(w io.Writer, diagnostics []diag.Diagnostic) error {
+	return diag.JSONSink{}.Write(w, diagnostics)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// nonNil makes empty reference lists serialize as [] rather than null
+func nonNil(lines []int) []int {
+	if lines == nil {
+		return []int{}
+	}
+	return lines
+}
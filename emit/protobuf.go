@@ -0,0 +1,322 @@
+package emit
+
+import (
+	"encoding/binary"
+	"io"
+
+	"compiler/ast"
+	"compiler/diag"
+	"compiler/parser"
+	"compiler/token"
+)
+
+// Protobuf writes a compilation as a Compilation message of compiler.proto
+// in the protocol buffers wire format. prog is nil if the program has
+// syntax errors.
+func Protobuf(w io.Writer, tokens []token.Token, prog *ast.Program, vars []parser.Variable, procs []parser.Procedure, diagnostics []diag.Diagnostic) error {
+	var m pbMessage
+	for _, tok := range tokens {
+		var t pbMessage
+		t.int(1, int(tok.Type))
+		t.string(2, tok.Value)
+		t.int(3, tok.Column)
+		m.message(1, &t)
+	}
+	if prog != nil {
+		p := pbNode(prog.Pos)
+		p.message(2, pbBlock(prog.Body))
+		m.message(2, p)
+	}
+	for _, v := range vars {
+		var s pbMessage
+		s.string(1, v.Name)
+		s.string(2, v.Procedure)
+		s.int(3, v.Kind)
+		s.string(4, v.Type)
+		s.int(5, v.Size)
+		s.int(6, v.Level)
+		s.int(7, v.Address)
+		s.bool(8, v.IsDeclared)
+		s.string(9, v.File)
+		s.int(10, v.Line)
+		s.ints(11, v.References)
+		m.message(3, &s)
+	}
+	for _, p := range procs {
+		var s pbMessage
+		s.string(1, p.Name)
+		s.string(2, p.Type)
+		s.int(3, p.Level)
+		s.int(4, p.FirstVariableAddress)
+		s.int(5, p.LastVariableAddress)
+		s.string(6, p.Parent)
+		s.bool(7, p.Forward)
+		s.string(8, p.File)
+		s.int(9, p.Line)
+		s.ints(10, p.References)
+		for _, caller := range p.Callers {
+			s.repeatedString(11, caller)
+		}
+		m.message(4, &s)
+	}
+	for _, d := range diagnostics {
+		var s pbMessage
+		s.string(1, string(d.Phase))
+		s.message(2, pbDiagPos(d.Pos))
+		s.int(3, int(d.Severity))
+		s.string(4, string(d.Code))
+		s.string(5, d.Msg)
+		for _, note := range d.Notes {
+			s.repeatedString(6, note)
+		}
+		s.bool(7, d.Fatal)
+		for _, fix := range d.Fixes {
+			var f pbMessage
+			f.message(1, pbDiagPos(fix.Range.Start))
+			f.message(2, pbDiagPos(fix.Range.End))
+			f.string(3, fix.NewText)
+			s.message(8, &f)
+		}
+		m.message(5, &s)
+	}
+	_, err := w.Write(m)
+	return err
+}
+
+// pbMessage is an encoded message, to which fields are appended in the
+// wire format. Fields of scalar types with their default value are left
+// out, as proto3 does.
+type pbMessage []byte
+
+const (
+	pbVarint    = 0
+	pbDelimited = 2 // length-delimited: strings, messages and packed numbers
+)
+
+func (m *pbMessage) tag(field, wireType int) {
+	*m = binary.AppendUvarint(*m, uint64(field<<3|wireType))
+}
+
+// int appends an int32 or int64 field, a negative value taking ten bytes
+func (m *pbMessage) int(field, v int) {
+	if v != 0 {
+		m.tag(field, pbVarint)
+		*m = binary.AppendUvarint(*m, uint64(int64(v)))
+	}
+}
+
+func (m *pbMessage) bool(field int, v bool) {
+	if v {
+		m.int(field, 1)
+	}
+}
+
+func (m *pbMessage) string(field int, s string) {
+	if s != "" {
+		m.repeatedString(field, s)
+	}
+}
+
+// repeatedString appends an element of a repeated string field, which is
+// written even if empty
+func (m *pbMessage) repeatedString(field int, s string) {
+	m.tag(field, pbDelimited)
+	*m = binary.AppendUvarint(*m, uint64(len(s)))
+	*m = append(*m, s...)
+}
+
+// ints appends a repeated int32 field, packed
+func (m *pbMessage) ints(field int, values []int) {
+	if len(values) == 0 {
+		return
+	}
+	var packed []byte
+	for _, v := range values {
+		packed = binary.AppendUvarint(packed, uint64(int64(v)))
+	}
+	m.repeatedString(field, string(packed))
+}
+
+// message appends a field of a message type, which is written even if all
+// its fields are left out, so that it is set. A nil message is not.
+func (m *pbMessage) message(field int, sub *pbMessage) {
+	if sub != nil {
+		m.repeatedString(field, string(*sub))
+	}
+}
+
+func pbPos(pos ast.Pos) *pbMessage {
+	var m pbMessage
+	m.string(1, pos.File)
+	m.int(2, pos.Line)
+	return &m
+}
+
+func pbDiagPos(pos diag.Pos) *pbMessage {
+	m := pbPos(ast.Pos{File: pos.File, Line: pos.Line})
+	m.int(3, pos.Column)
+	return m
+}
+
+// pbNode starts the message of a node of the tree with its position
+func pbNode(pos ast.Pos) *pbMessage {
+	var m pbMessage
+	m.message(1, pbPos(pos))
+	return &m
+}
+
+// pbOneof wraps the message of a node in the Decl, Stmt or Expr message
+// holding it in the field of its kind
+func pbOneof(field int, node *pbMessage) *pbMessage {
+	var m pbMessage
+	m.message(field, node)
+	return &m
+}
+
+func pbBlock(b *ast.Block) *pbMessage {
+	if b == nil {
+		return nil
+	}
+	m := pbNode(b.Pos)
+	for _, d := range b.Declarations {
+		m.message(2, pbDecl(d))
+	}
+	for _, s := range b.Statements {
+		m.message(3, pbStmt(s))
+	}
+	return m
+}
+
+func pbDecl(d ast.Decl) *pbMessage {
+	switch d := d.(type) {
+	case *ast.VarDecl:
+		m := pbNode(d.Pos)
+		m.string(2, d.Name)
+		m.int(3, d.Size)
+		return pbOneof(1, m)
+	case *ast.ProcDecl:
+		m := pbNode(d.Pos)
+		m.string(2, d.Name)
+		m.string(3, d.Result)
+		m.string(4, d.Param)
+		m.bool(5, d.ByReference)
+		m.message(6, pbBlock(d.Body))
+		return pbOneof(2, m)
+	}
+	return nil
+}
+
+func pbStmt(s ast.Stmt) *pbMessage {
+	switch s := s.(type) {
+	case *ast.ReadStmt:
+		m := pbNode(s.Pos)
+		for _, t := range s.Targets {
+			m.message(2, pbVarRef(t))
+		}
+		return pbOneof(1, m)
+	case *ast.WriteStmt:
+		m := pbNode(s.Pos)
+		m.bool(2, s.Newline)
+		for _, a := range s.Args {
+			m.message(3, pbExpr(a))
+		}
+		return pbOneof(2, m)
+	case *ast.AssignStmt:
+		m := pbNode(s.Pos)
+		m.message(2, pbVarRef(s.Target))
+		m.message(3, pbExpr(s.Value))
+		return pbOneof(3, m)
+	case *ast.CallStmt:
+		m := pbNode(s.Pos)
+		m.message(2, pbCallExpr(s.Call))
+		return pbOneof(4, m)
+	case *ast.IfStmt:
+		m := pbNode(s.Pos)
+		m.message(2, pbExpr(s.Cond))
+		m.message(3, pbStmt(s.Then))
+		m.message(4, pbStmt(s.Else))
+		return pbOneof(5, m)
+	case *ast.CaseStmt:
+		m := pbNode(s.Pos)
+		m.message(2, pbExpr(s.Subject))
+		for _, b := range s.Branches {
+			branch := pbNode(b.Pos)
+			branch.ints(2, b.Labels)
+			branch.message(3, pbStmt(b.Body))
+			m.message(3, branch)
+		}
+		m.message(4, pbStmt(s.Else))
+		return pbOneof(6, m)
+	case *ast.WhileStmt:
+		m := pbNode(s.Pos)
+		m.message(2, pbExpr(s.Cond))
+		m.message(3, pbStmt(s.Body))
+		return pbOneof(7, m)
+	case *ast.RepeatStmt:
+		m := pbNode(s.Pos)
+		for _, body := range s.Body {
+			m.message(2, pbStmt(body))
+		}
+		m.message(3, pbExpr(s.Cond))
+		return pbOneof(8, m)
+	case *ast.ForStmt:
+		m := pbNode(s.Pos)
+		m.message(2, pbVarRef(s.Counter))
+		m.message(3, pbExpr(s.From))
+		m.message(4, pbExpr(s.To))
+		m.message(5, pbStmt(s.Body))
+		return pbOneof(9, m)
+	}
+	return nil
+}
+
+func pbExpr(e ast.Expr) *pbMessage {
+	switch e := e.(type) {
+	case *ast.BinaryExpr:
+		m := pbNode(e.Pos)
+		m.string(2, e.Op)
+		m.message(3, pbExpr(e.Left))
+		m.message(4, pbExpr(e.Right))
+		return pbOneof(1, m)
+	case *ast.UnaryExpr:
+		m := pbNode(e.Pos)
+		m.string(2, e.Op)
+		m.message(3, pbExpr(e.Operand))
+		return pbOneof(2, m)
+	case *ast.IntLit:
+		m := pbNode(e.Pos)
+		m.int(2, e.Value)
+		return pbOneof(3, m)
+	case *ast.StringLit:
+		m := pbNode(e.Pos)
+		m.string(2, e.Value)
+		return pbOneof(4, m)
+	case *ast.VarRef:
+		return pbOneof(5, pbVarRef(e))
+	case *ast.CallExpr:
+		return pbOneof(6, pbCallExpr(e))
+	}
+	return nil
+}
+
+// pbVarRef encodes a VarRef message, which some nodes hold directly rather
+// than as an Expr
+func pbVarRef(v *ast.VarRef) *pbMessage {
+	if v == nil {
+		return nil
+	}
+	m := pbNode(v.Pos)
+	m.string(2, v.Name)
+	m.message(3, pbExpr(v.Index))
+	return m
+}
+
+func pbCallExpr(c *ast.CallExpr) *pbMessage {
+	if c == nil {
+		return nil
+	}
+	m := pbNode(c.Pos)
+	m.string(2, c.Name)
+	m.message(3, pbExpr(c.Arg))
+	return m
+}
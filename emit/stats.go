@@ -0,0 +1,171 @@
+package emit
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"compiler/token"
+)
+
+// histogramWidth is the length of the longest bar of a histogram
+const histogramWidth = 40
+
+// TokenStats counts the tokens of a source as the lexer produces them, for
+// the figures of the lexing lab report. Lines are those of the source and of
+// the files it includes.
+type TokenStats struct {
+	Types       map[token.TokenType]int
+	Identifiers map[string]int
+	Lines       int // lines, the last one counted even without a line break
+	CodeLines   int // lines holding at least one token
+
+	pending bool // the current line has tokens
+}
+
+// NewTokenStats returns empty statistics
+func NewTokenStats() *TokenStats {
+	return &TokenStats{Types: make(map[token.TokenType]int), Identifiers: make(map[string]int)}
+}
+
+// Add counts a token. Line breaks and file markers only count lines, and
+// the end of file closes the last line.
+func (s *TokenStats) Add(tok token.Token) {
+	switch tok.Type {
+	case token.SOURCE_FILE:
+		return
+	case token.END_OF_LINE, token.END_OF_FILE:
+		if tok.Type == token.END_OF_LINE || s.pending {
+			s.Lines++
+		}
+		if s.pending {
+			s.CodeLines++
+		}
+		s.pending = false
+		return
+	case token.IDENTIFIER:
+		s.Identifiers[tok.Value]++
+	}
+	s.Types[tok.Type]++
+	s.pending = true
+}
+
+// Tokens returns the number of tokens counted, without the layout
+func (s *TokenStats) Tokens() int {
+	total := 0
+	for _, n := range s.Types {
+		total += n
+	}
+	return total
+}
+
+// IdentifiersPerLine returns the average number of identifiers on a line
+func (s *TokenStats) IdentifiersPerLine() float64 {
+	if s.Lines == 0 {
+		return 0
+	}
+	return float64(s.Types[token.IDENTIFIER]) / float64(s.Lines)
+}
+
+// statsCount is a line of a table of the statistics
+type statsCount struct {
+	name  string
+	code  int
+	count int
+}
+
+// types returns the token counts in the order of the token codes
+func (s *TokenStats) types() []statsCount {
+	var counts []statsCount
+	for _, t := range slices.Sorted(maps.Keys(s.Types)) {
+		counts = append(counts, statsCount{t.String(), int(t), s.Types[t]})
+	}
+	return counts
+}
+
+// identifiers returns the identifier counts, the most frequent first
+func (s *TokenStats) identifiers() []statsCount {
+	var counts []statsCount
+	for name, n := range s.Identifiers {
+		counts = append(counts, statsCount{name: name, count: n})
+	}
+	slices.SortFunc(counts, func(a, b statsCount) int {
+		return cmp.Or(b.count-a.count, strings.Compare(a.name, b.name))
+	})
+	return counts
+}
+
+// WriteText writes the statistics as a report with a histogram of the token
+// types and of the identifiers
+func (s *TokenStats) WriteText(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tokens: %d\n", s.Tokens())
+	writeHistogram(&sb, s.types())
+	fmt.Fprintf(&sb, "\nIdentifiers: %d (%d distinct)\n", s.Types[token.IDENTIFIER], len(s.Identifiers))
+	writeHistogram(&sb, s.identifiers())
+	fmt.Fprintf(&sb, "\nLines: %d (%d with tokens)\n", s.Lines, s.CodeLines)
+	fmt.Fprintf(&sb, "Identifiers per line: %.2f\n", s.IdentifiersPerLine())
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeHistogram writes a table of counts with a bar for each, scaled so
+// that the largest count gets histogramWidth characters
+func writeHistogram(sb *strings.Builder, counts []statsCount) {
+	width, largest := 0, 0
+	for _, c := range counts {
+		width = max(width, utf8.RuneCountInString(c.name))
+		largest = max(largest, c.count)
+	}
+	for _, c := range counts {
+		bar := max(1, c.count*histogramWidth/largest)
+		fmt.Fprintf(sb, "  %-*s %5d  %s\n", width, c.name, c.count, strings.Repeat("#", bar))
+	}
+}
+
+type jsonTokenStats struct {
+	Tokens             int              `json:"tokens"`
+	Types              []jsonTypeCount  `json:"types"`
+	Identifiers        []jsonIdentCount `json:"identifiers"`
+	Lines              int              `json:"lines"`
+	CodeLines          int              `json:"codeLines"`
+	IdentifiersPerLine float64          `json:"identifiersPerLine"`
+}
+
+type jsonTypeCount struct {
+	Type  string `json:"type"`
+	Code  int    `json:"code"`
+	Count int    `json:"count"`
+}
+
+type jsonIdentCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// WriteJSON writes the statistics as indented JSON, with the counts in the
+// order of the text report
+func (s *TokenStats) WriteJSON(w io.Writer) error {
+	out := jsonTokenStats{
+		Tokens:             s.Tokens(),
+		Types:              []jsonTypeCount{},
+		Identifiers:        []jsonIdentCount{},
+		Lines:              s.Lines,
+		CodeLines:          s.CodeLines,
+		IdentifiersPerLine: s.IdentifiersPerLine(),
+	}
+	for _, c := range s.types() {
+		out.Types = append(out.Types, jsonTypeCount{c.name, c.code, c.count})
+	}
+	for _, c := range s.identifiers() {
+		out.Identifiers = append(out.Identifiers, jsonIdentCount{c.name, c.count})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
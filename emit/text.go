@@ -0,0 +1,50 @@
+package emit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"iter"
+
+	"compiler/diag"
+	"compiler/parser"
+	"compiler/token"
+)
+
+// Text emits the fixed width formats defined by the course
+type Text struct{}
+
+func (Text) EmitTokens(w io.Writer, tokens iter.Seq[token.Token]) error {
+	out := bufio.NewWriter(w)
+	for tok := range tokens {
+		fmt.Fprintf(out, "%-16s %02d\n", tok.Value, tok.Type)
+	}
+	return out.Flush()
+}
+
+func (Text) EmitSymbols(variables, procedures io.Writer, vars []parser.Variable, procs []parser.Procedure) error {
+	var records []string
+	for _, v := range vars {
+		record := fmt.Sprintf("Var\n    Name      = %s\n    Procedure = %s\n    Kind      = %d\n    Type      = %s\n    Level     = %d\n    Offset    = %d",
+			v.Name, v.Procedure, v.Kind, v.Type, v.Level, v.Address)
+		if v.Size > 0 {
+			record += fmt.Sprintf("\n    Size      = %d", v.Size) // arrays only, so scalars keep the course format
+		}
+		records = append(records, record)
+	}
+	if _, err := io.WriteString(variables, joinRecords(records)); err != nil {
+		return err
+	}
+
+	records = nil
+	for _, p := range procs {
+		records = append(records, fmt.Sprintf("Proc\n    Name      = %s\n    Type      = %s\n    Level     = %d\n    FirstVar  = %d\n    LastVar   = %d",
+			p.Name, p.Type, p.Level, p.FirstVariableAddress, p.LastVariableAddress))
+	}
+	_, err := io.WriteString(procedures, joinRecords(records))
+	return err
+}
+
+func (Text) EmitErrors(w io.Writer, diagnostics []diag.Diagnostic) error {
+	return diag.TextSink{}.Write(w, diagnostics)
+}
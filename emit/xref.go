@@ -0,0 +1,53 @@
+package emit
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"compiler/parser"
+)
+
+// CrossReference writes every variable, parameter and procedure with its
+// declaration line and the lines that reference it, sorted by name
+func CrossReference(w io.Writer, vars []parser.Variable, procs []parser.Procedure) error {
+	type entry struct {
+		name, kind, scope string
+		line              int
+		references        []int
+	}
+
+	var entries []entry
+	for _, v := range vars {
+		if v.Kind != 0 {
+			continue // the parameter itself; its uses resolve to the body declaration
+		}
+		kind := "variable"
+		if param := parser.ParameterOf(vars, v); param != nil {
+			kind = "parameter"
+			if param.Kind == 2 {
+				kind = "var param"
+			}
+		}
+		entries = append(entries, entry{v.Name, kind, v.Procedure, v.Line, v.References})
+	}
+	for _, proc := range procs {
+		kind := "function"
+		if proc.Type == "void" {
+			kind = "procedure"
+		}
+		entries = append(entries, entry{proc.Name, kind, proc.Parent, proc.Line, proc.References})
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return strings.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+	})
+
+	lines := []string{fmt.Sprintf("%-16s %-9s %-16s %8s  %s", "Name", "Kind", "Scope", "Declared", "References")}
+	for _, e := range entries {
+		lines = append(lines, fmt.Sprintf("%-16s %-9s %-16s %8d  %s",
+			e.name, e.kind, e.scope, e.line, joinLines(slices.Compact(slices.Clone(e.references)))))
+	}
+	_, err := io.WriteString(w, joinRecords(lines))
+	return err
+}
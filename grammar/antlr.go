@@ -0,0 +1,108 @@
+package grammar
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+
+	"compiler/token"
+)
+
+// antlrTokens are the lexer rules of the token classes in an ANTLR grammar,
+// matching the letters and digits of any script as the lexer does
+var antlrTokens = map[token.TokenType]string{
+	token.IDENTIFIER: `[\p{L}] [\p{L}\p{Nd}]*`,
+	token.CONSTANT:   `[\p{Nd}]+`,
+	token.STRING:     `'\'' ( ~['\r\n] | '\'\'' )* '\''`,
+}
+
+// WriteANTLR writes the grammar as a combined ANTLR 4 grammar named Course,
+// to be saved as Course.g4. Keywords are lexer rules matching in any case,
+// declared before IDENTIFIER so that they take precedence over it, and
+// symbols are written as literals in the parser rules.
+func (g *Grammar) WriteANTLR(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("// Generated by the grammar command of the compiler; regenerate it rather than\n")
+	sb.WriteString("// editing it, so that it stays in step with the parser.\n")
+	sb.WriteString("grammar Course;\n\n")
+
+	var keywords []token.TokenType
+	used := make(map[token.TokenType]bool)
+	for _, rule := range g.Rules {
+		fmt.Fprintf(&sb, "%s\n    : %s\n    ;\n\n", rule.Name, antlrExpr(rule.Body, used, false))
+	}
+	for tokenType := range used {
+		if tokenType.IsKeyword() {
+			keywords = append(keywords, tokenType)
+		}
+	}
+	slices.Sort(keywords)
+	for _, keyword := range keywords {
+		var pattern []string
+		for _, r := range keyword.String() {
+			pattern = append(pattern, fmt.Sprintf("[%c%c]", r, unicode.ToUpper(r)))
+		}
+		fmt.Fprintf(&sb, "%s : %s ;\n", strings.ToUpper(keyword.String()), strings.Join(pattern, ""))
+	}
+	sb.WriteString("\n")
+	for _, tokenType := range []token.TokenType{token.IDENTIFIER, token.CONSTANT, token.STRING} {
+		if used[tokenType] {
+			fmt.Fprintf(&sb, "%s : %s ;\n", strings.ToUpper(tokenType.String()), antlrTokens[tokenType])
+		}
+	}
+	if g.Directives {
+		sb.WriteString(`DIRECTIVE : '{$' [iI][nN][cC][lL][uU][dD][eE] ~[}\r\n]* '}' -> skip ;` + "\n")
+	}
+	sb.WriteString("WHITESPACE : [ \\t\\r\\n]+ -> skip ;\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// antlrExpr writes an expression in the notation of ANTLR, recording the
+// tokens it uses. nested tells whether a choice has to be parenthesized.
+func antlrExpr(e Expr, used map[token.TokenType]bool, nested bool) string {
+	switch e.Kind {
+	case Terminal:
+		used[e.Token] = true
+		switch {
+		case e.Token == token.END_OF_FILE:
+			return "EOF"
+		case e.Token.IsKeyword(), antlrTokens[e.Token] != "":
+			return strings.ToUpper(e.Token.String())
+		}
+		return "'" + e.Token.String() + "'"
+	case Nonterminal:
+		return e.Name
+	case Sequence:
+		parts := make([]string, len(e.Items))
+		for i, item := range e.Items {
+			parts[i] = antlrExpr(item, used, true)
+		}
+		return strings.Join(parts, " ")
+	case Choice:
+		parts := make([]string, len(e.Items))
+		for i, item := range e.Items {
+			parts[i] = antlrExpr(item, used, false)
+		}
+		if !nested {
+			return strings.Join(parts, "\n    | ")
+		}
+		return "( " + strings.Join(parts, " | ") + " )"
+	case Optional:
+		return antlrGroup(e.Items[0], used) + "?"
+	case Repetition:
+		return antlrGroup(e.Items[0], used) + "*"
+	}
+	return "?"
+}
+
+// antlrGroup writes the operand of ? or *, parenthesized if it is a
+// sequence, as a choice already is
+func antlrGroup(e Expr, used map[token.TokenType]bool) string {
+	if e.Kind == Sequence && len(e.Items) > 1 {
+		return "( " + antlrExpr(e, used, true) + " )"
+	}
+	return antlrExpr(e, used, true)
+}
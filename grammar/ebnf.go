@@ -0,0 +1,63 @@
+package grammar
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"compiler/token"
+)
+
+// WriteEBNF writes one rule per line in Wirth's notation, with keywords and
+// symbols quoted and the token classes identifier, constant, string and EOF
+// written bare
+func (g *Grammar) WriteEBNF(w io.Writer) error {
+	width := 0
+	for _, rule := range g.Rules {
+		width = max(width, len(rule.Name))
+	}
+	for _, rule := range g.Rules {
+		if _, err := fmt.Fprintf(w, "%-*s = %s .\n", width, rule.Name, rule.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e Expr) String() string {
+	switch e.Kind {
+	case Terminal:
+		return Spelling(e.Token)
+	case Nonterminal:
+		return e.Name
+	case Sequence:
+		parts := make([]string, len(e.Items))
+		for i, item := range e.Items {
+			parts[i] = item.String()
+			if item.Kind == Choice {
+				parts[i] = "( " + parts[i] + " )"
+			}
+		}
+		return strings.Join(parts, " ")
+	case Choice:
+		parts := make([]string, len(e.Items))
+		for i, item := range e.Items {
+			parts[i] = item.String()
+		}
+		return strings.Join(parts, " | ")
+	case Optional:
+		return "[ " + e.Items[0].String() + " ]"
+	case Repetition:
+		return "{ " + e.Items[0].String() + " }"
+	}
+	return "?"
+}
+
+// Spelling returns how a token type is written in the grammar
+func Spelling(tokenType token.TokenType) string {
+	switch tokenType {
+	case token.IDENTIFIER, token.CONSTANT, token.STRING, token.END_OF_FILE:
+		return tokenType.String()
+	}
+	return `"` + tokenType.String() + `"`
+}
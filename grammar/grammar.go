@@ -0,0 +1,155 @@
+// Package grammar describes the language accepted by the parser in EBNF, so
+// that the documented grammar is generated from the code instead of copied
+// by hand
+package grammar
+
+import (
+	"slices"
+
+	"compiler/config"
+	"compiler/token"
+)
+
+// Kind tells the kinds of EBNF expressions apart
+type Kind int
+
+const (
+	Terminal    Kind = iota // a token
+	Nonterminal             // a reference to a rule
+	Sequence                // the items one after another
+	Choice                  // exactly one of the items
+	Optional                // the single item or nothing
+	Repetition              // the single item any number of times
+)
+
+// Expr is the right-hand side of a rule or a part of it
+type Expr struct {
+	Kind  Kind
+	Token token.TokenType // of a Terminal
+	Name  string          // of a Nonterminal
+	Items []Expr          // of a Sequence or Choice, or the single item of an Optional or Repetition
+}
+
+// Rule defines a nonterminal
+type Rule struct {
+	Name string
+	Body Expr
+}
+
+// Grammar lists the rules in the order the parser meets them; the first
+// rule is the start symbol
+type Grammar struct {
+	Rules      []Rule
+	Directives bool // {$include} directives may appear between tokens
+}
+
+// Rule returns the rule defining name, or nil if there is none
+func (g *Grammar) Rule(name string) *Rule {
+	for i := range g.Rules {
+		if g.Rules[i].Name == name {
+			return &g.Rules[i]
+		}
+	}
+	return nil
+}
+
+func t(tokenType token.TokenType) Expr { return Expr{Kind: Terminal, Token: tokenType} }
+func n(name string) Expr               { return Expr{Kind: Nonterminal, Name: name} }
+func seq(items ...Expr) Expr           { return Expr{Kind: Sequence, Items: items} }
+func alt(items ...Expr) Expr           { return Expr{Kind: Choice, Items: items} }
+func opt(item Expr) Expr               { return Expr{Kind: Optional, Items: []Expr{item}} }
+func rep(item Expr) Expr               { return Expr{Kind: Repetition, Items: []Expr{item}} }
+
+// Active returns the grammar selected by --dialect and --unary-minus
+func Active() *Grammar {
+	return New(config.Dialect, config.UnaryMinus)
+}
+
+// New returns the grammar of a dialect, mirroring the recursive descent
+// parser. Directives such as {$include} are handled by the lexer and don't
+// appear in it.
+func New(dialect string, unaryMinus bool) *Grammar {
+	includes := func(level string) bool {
+		return slices.Index(config.Dialects, dialect) >= slices.Index(config.Dialects, level)
+	}
+	std, ext := includes("std"), includes("ext")
+
+	g := &Grammar{Directives: ext}
+	add := func(name string, body Expr) {
+		g.Rules = append(g.Rules, Rule{Name: name, Body: body})
+	}
+
+	add("program", seq(n("subprogram"), t(token.END_OF_FILE)))
+	add("subprogram", seq(t(token.BEGIN), n("declarations"), n("executions"), t(token.END)))
+	add("declarations", seq(n("declaration"), rep(n("declaration"))))
+	if ext {
+		add("declaration", seq(alt(
+			seq(t(token.INTEGER), alt(n("variableDeclaration"), n("functionDeclaration"))),
+			n("procedureDeclaration"),
+		), t(token.SEMICOLON)))
+		add("variableDeclaration", seq(t(token.IDENTIFIER), opt(seq(t(token.LEFT_BRACKET), t(token.CONSTANT), t(token.RIGHT_BRACKET)))))
+		heading := func(keyword token.TokenType) Expr {
+			return seq(t(keyword), t(token.IDENTIFIER), t(token.LEFT_PARENTHESES), n("parameter"), t(token.RIGHT_PARENTHESES),
+				t(token.SEMICOLON), alt(n("procedureBody"), t(token.FORWARD)))
+		}
+		add("functionDeclaration", heading(token.FUNCTION))
+		add("procedureDeclaration", heading(token.PROCEDURE))
+		add("parameter", seq(opt(t(token.VAR)), t(token.IDENTIFIER)))
+	} else {
+		add("declaration", seq(t(token.INTEGER), alt(n("variableDeclaration"), n("functionDeclaration")), t(token.SEMICOLON)))
+		add("variableDeclaration", t(token.IDENTIFIER))
+		add("functionDeclaration", seq(t(token.FUNCTION), t(token.IDENTIFIER), t(token.LEFT_PARENTHESES), n("parameter"), t(token.RIGHT_PARENTHESES),
+			t(token.SEMICOLON), n("procedureBody")))
+		add("parameter", t(token.IDENTIFIER))
+	}
+	add("procedureBody", seq(t(token.BEGIN), n("declarations"), n("executions"), t(token.END)))
+	add("executions", seq(n("execution"), rep(seq(t(token.SEMICOLON), n("execution")))))
+
+	executions := []Expr{n("readStatement"), n("writeStatement"), n("assignment"), n("ifStatement")}
+	if ext {
+		executions = append(executions, n("procedureCall"), n("caseStatement"), n("whileStatement"), n("repeatStatement"), n("forStatement"))
+	}
+	add("execution", alt(executions...))
+
+	if std {
+		add("readStatement", seq(t(token.READ), t(token.LEFT_PARENTHESES), n("variable"), rep(seq(t(token.COMMA), n("variable"))), t(token.RIGHT_PARENTHESES)))
+		arguments := seq(t(token.LEFT_PARENTHESES), n("writeArgument"), rep(seq(t(token.COMMA), n("writeArgument"))), t(token.RIGHT_PARENTHESES))
+		add("writeStatement", alt(seq(t(token.WRITE), arguments), seq(t(token.WRITELN), opt(arguments))))
+		add("writeArgument", alt(t(token.STRING), n("expression")))
+	} else {
+		add("readStatement", seq(t(token.READ), t(token.LEFT_PARENTHESES), n("variable"), t(token.RIGHT_PARENTHESES)))
+		add("writeStatement", seq(t(token.WRITE), t(token.LEFT_PARENTHESES), n("variable"), t(token.RIGHT_PARENTHESES)))
+	}
+	add("assignment", seq(n("variable"), t(token.ASSIGN), n("expression")))
+	add("ifStatement", seq(t(token.IF), n("condition"), t(token.THEN), n("execution"), t(token.ELSE), n("execution")))
+	if ext {
+		add("caseStatement", seq(t(token.CASE), n("expression"), t(token.OF), n("caseBranch"), rep(seq(t(token.SEMICOLON), n("caseBranch"))),
+			opt(t(token.SEMICOLON)), opt(seq(t(token.ELSE), n("execution"))), t(token.END)))
+		add("caseBranch", seq(t(token.CONSTANT), rep(seq(t(token.COMMA), t(token.CONSTANT))), t(token.COLON), n("execution")))
+		add("whileStatement", seq(t(token.WHILE), n("condition"), t(token.DO), n("execution")))
+		add("repeatStatement", seq(t(token.REPEAT), n("executions"), t(token.UNTIL), n("condition")))
+		add("forStatement", seq(t(token.FOR), n("variable"), t(token.ASSIGN), n("expression"), t(token.TO), n("expression"), t(token.DO), n("execution")))
+	}
+	add("condition", seq(n("expression"), n("relationalOperator"), n("expression")))
+	add("relationalOperator", alt(t(token.EQUAL), t(token.NOT_EQUAL), t(token.LESS_THAN_OR_EQUAL), t(token.LESS_THAN),
+		t(token.GREATER_THAN_OR_EQUAL), t(token.GREATER_THAN)))
+
+	if unaryMinus {
+		add("expression", seq(opt(t(token.SUBTRACT)), n("term"), rep(seq(t(token.SUBTRACT), n("term")))))
+	} else {
+		add("expression", seq(n("term"), rep(seq(t(token.SUBTRACT), n("term")))))
+	}
+	if std {
+		add("term", seq(n("factor"), rep(seq(alt(t(token.MULTIPLY), t(token.MOD), t(token.DIV)), n("factor")))))
+	} else {
+		add("term", seq(n("factor"), rep(seq(t(token.MULTIPLY), n("factor")))))
+	}
+	add("factor", alt(n("variable"), t(token.CONSTANT), n("procedureCall")))
+	add("procedureCall", seq(t(token.IDENTIFIER), t(token.LEFT_PARENTHESES), n("expression"), t(token.RIGHT_PARENTHESES)))
+	if ext {
+		add("variable", seq(t(token.IDENTIFIER), opt(seq(t(token.LEFT_BRACKET), n("expression"), t(token.RIGHT_BRACKET)))))
+	} else {
+		add("variable", t(token.IDENTIFIER))
+	}
+	return g
+}
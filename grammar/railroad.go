@@ -0,0 +1,151 @@
+package grammar
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Sizes of the railroad diagrams in pixels
+const (
+	charWidth  = 8  // of a monospace character
+	boxHeight  = 22 // of a terminal or nonterminal
+	boxPadding = 10 // between the text and the sides of a box
+	gap        = 10 // between the parts of a sequence or the branches of a choice
+	curve      = 10 // horizontal room for a branch to leave or join the line
+	margin     = 10 // around the whole diagram
+)
+
+// diagram is the laid out railroad diagram of an expression. The track
+// enters on the left and leaves on the right at height baseline.
+type diagram struct {
+	width, height, baseline int
+	draw                    func(sb *strings.Builder, x, y int)
+}
+
+// WriteRailroad writes the railroad diagram of a rule as a standalone SVG
+func WriteRailroad(w io.Writer, rule Rule) error {
+	d := layout(rule.Body)
+	title := boxHeight
+	width := d.width + 2*(margin+curve)
+	height := title + d.height + 2*margin
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	sb.WriteString(`<style>path { fill: none; stroke: #333; stroke-width: 1.5; } rect { fill: #f6f8fa; stroke: #333; stroke-width: 1.5; } text { font: 13px monospace; fill: #222; } .title { font-weight: bold; }</style>` + "\n")
+	fmt.Fprintf(&sb, `<text class="title" x="%d" y="%d">%s</text>`+"\n", margin, margin+14, html.EscapeString(rule.Name))
+
+	y := margin + title
+	line := y + d.baseline
+	fmt.Fprintf(&sb, `<path d="M%d %dv%d M%d %dh%d"/>`+"\n", margin, line-6, 12, margin, line, curve)
+	d.draw(&sb, margin+curve, y)
+	end := margin + curve + d.width
+	fmt.Fprintf(&sb, `<path d="M%d %dh%d M%d %dv%d"/>`+"\n", end, line, curve, end+curve, line-6, 12)
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func layout(e Expr) diagram {
+	switch e.Kind {
+	case Terminal:
+		return box(e.Token.String(), 11)
+	case Nonterminal:
+		return box(e.Name, 0)
+	case Sequence:
+		return sequence(e.Items)
+	case Choice:
+		branches := make([]diagram, len(e.Items))
+		for i, item := range e.Items {
+			branches[i] = layout(item)
+		}
+		return choice(branches)
+	case Optional:
+		return choice([]diagram{{}, layout(e.Items[0])})
+	case Repetition:
+		return choice([]diagram{{}, loop(layout(e.Items[0]))})
+	}
+	panic(fmt.Sprintf("unknown expression kind %d", e.Kind))
+}
+
+// box is a terminal with rounded corners or a nonterminal with square ones
+func box(text string, radius int) diagram {
+	width := len(text)*charWidth + 2*boxPadding
+	return diagram{width: width, height: boxHeight, baseline: boxHeight / 2, draw: func(sb *strings.Builder, x, y int) {
+		fmt.Fprintf(sb, `<rect x="%d" y="%d" width="%d" height="%d" rx="%d"/>`+"\n", x, y, width, boxHeight, radius)
+		fmt.Fprintf(sb, `<text x="%d" y="%d">%s</text>`+"\n", x+boxPadding, y+boxHeight/2+5, html.EscapeString(text))
+	}}
+}
+
+func sequence(items []Expr) diagram {
+	parts := make([]diagram, len(items))
+	var d diagram
+	below := 0
+	for i, item := range items {
+		parts[i] = layout(item)
+		d.width += parts[i].width
+		d.baseline = max(d.baseline, parts[i].baseline)
+		below = max(below, parts[i].height-parts[i].baseline)
+	}
+	d.width += gap * max(len(parts)-1, 0)
+	d.height = d.baseline + below
+	d.draw = func(sb *strings.Builder, x, y int) {
+		line := y + d.baseline
+		for i, part := range parts {
+			if i > 0 {
+				fmt.Fprintf(sb, `<path d="M%d %dh%d"/>`+"\n", x, line, gap)
+				x += gap
+			}
+			part.draw(sb, x, line-part.baseline)
+			x += part.width
+		}
+	}
+	return d
+}
+
+// choice stacks the branches below each other; the track runs straight
+// through the first one
+func choice(branches []diagram) diagram {
+	inner := 0
+	for _, branch := range branches {
+		inner = max(inner, branch.width)
+	}
+	d := diagram{width: inner + 4*curve, baseline: branches[0].baseline}
+	tops := make([]int, len(branches))
+	for i, branch := range branches {
+		if i > 0 {
+			d.height += gap
+		}
+		tops[i] = d.height
+		d.height += max(branch.height, 1)
+	}
+	d.draw = func(sb *strings.Builder, x, y int) {
+		line := y + d.baseline
+		for i, branch := range branches {
+			track := y + tops[i] + branch.baseline
+			fmt.Fprintf(sb, `<path d="M%d %dh%dV%dh%d"/>`+"\n", x, line, curve, track, curve)
+			if branch.draw != nil {
+				branch.draw(sb, x+2*curve, y+tops[i])
+			}
+			fmt.Fprintf(sb, `<path d="M%d %dH%dV%dh%d"/>`+"\n", x+2*curve+branch.width, track, x+d.width-curve, line, curve)
+		}
+	}
+	return d
+}
+
+// loop runs the track through body and back below it, so that body is
+// passed at least once
+func loop(body diagram) diagram {
+	d := diagram{width: body.width + 4*curve, height: body.height + gap, baseline: body.baseline}
+	d.draw = func(sb *strings.Builder, x, y int) {
+		line := y + d.baseline
+		back := y + d.height
+		fmt.Fprintf(sb, `<path d="M%d %dh%d"/>`+"\n", x, line, 2*curve)
+		body.draw(sb, x+2*curve, y)
+		fmt.Fprintf(sb, `<path d="M%d %dh%d"/>`+"\n", x+2*curve+body.width, line, 2*curve)
+		fmt.Fprintf(sb, `<path d="M%d %dV%dH%dV%d"/>`+"\n", x+d.width-curve, line, back, x+curve, line)
+	}
+	return d
+}
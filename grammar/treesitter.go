@@ -0,0 +1,118 @@
+package grammar
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+
+	"compiler/token"
+)
+
+// treeSitterTokens are the regular expressions of the token classes in a
+// tree-sitter grammar, matching the letters and digits of any script as the
+// lexer does
+var treeSitterTokens = map[token.TokenType]string{
+	token.IDENTIFIER: `/\p{L}[\p{L}\p{Nd}]*/`,
+	token.CONSTANT:   `/\p{Nd}+/`,
+	token.STRING:     `/'([^'\r\n]|'')*'/`,
+}
+
+// WriteTreeSitter writes the grammar as the grammar.js of a tree-sitter
+// parser, for incremental highlighting and folding in editors. Rules are
+// named in snake case as tree-sitter expects, keywords match in any case
+// and end of file is implied. {$include} directives may appear anywhere, as
+// extras, in the grammars that have them.
+func (g *Grammar) WriteTreeSitter(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(`// Generated by the grammar command of the compiler; regenerate it rather than
+// editing it, so that it stays in step with the parser.
+
+// keyword matches a reserved word in any case, named by its lower case
+const keyword = word =>
+  alias(new RegExp(word.replace(/./g, c => "[" + c + c.toUpperCase() + "]")), word);
+
+module.exports = grammar({
+  name: "course",
+
+  word: $ => $.identifier,
+
+`)
+	if g.Directives {
+		sb.WriteString("  extras: $ => [/\\s/, $.directive],\n\n")
+	} else {
+		sb.WriteString("  extras: $ => [/\\s/],\n\n")
+	}
+	sb.WriteString("  rules: {\n")
+
+	used := make(map[token.TokenType]bool)
+	for _, rule := range g.Rules {
+		fmt.Fprintf(&sb, "    %s: $ => %s,\n\n", snakeCase(rule.Name), treeSitterExpr(rule.Body, used))
+	}
+	for _, tokenType := range []token.TokenType{token.IDENTIFIER, token.CONSTANT, token.STRING} {
+		if used[tokenType] || tokenType == token.IDENTIFIER { // the word rule
+			fmt.Fprintf(&sb, "    %s: $ => %s,\n", tokenType, treeSitterTokens[tokenType])
+		}
+	}
+	if g.Directives {
+		sb.WriteString(`    directive: $ => /\{\$[iI][nN][cC][lL][uU][dD][eE][^}\r\n]*\}/,` + "\n")
+	}
+	sb.WriteString("  },\n});\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// treeSitterExpr writes an expression with the rule functions of
+// tree-sitter, recording the token classes it uses
+func treeSitterExpr(e Expr, used map[token.TokenType]bool) string {
+	join := func(items []Expr) string {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = treeSitterExpr(item, used)
+		}
+		return strings.Join(parts, ", ")
+	}
+	switch e.Kind {
+	case Terminal:
+		switch {
+		case e.Token.IsKeyword():
+			return fmt.Sprintf("keyword(%q)", e.Token.String())
+		case treeSitterTokens[e.Token] != "":
+			used[e.Token] = true
+			return "$." + e.Token.String()
+		}
+		return fmt.Sprintf("%q", e.Token.String())
+	case Nonterminal:
+		return "$." + snakeCase(e.Name)
+	case Sequence:
+		items := slices.DeleteFunc(slices.Clone(e.Items), func(item Expr) bool {
+			return item.Kind == Terminal && item.Token == token.END_OF_FILE
+		})
+		if len(items) == 1 {
+			return treeSitterExpr(items[0], used)
+		}
+		return "seq(" + join(items) + ")"
+	case Choice:
+		return "choice(" + join(e.Items) + ")"
+	case Optional:
+		return "optional(" + treeSitterExpr(e.Items[0], used) + ")"
+	case Repetition:
+		return "repeat(" + treeSitterExpr(e.Items[0], used) + ")"
+	}
+	return "?"
+}
+
+// snakeCase returns a rule name such as variableDeclaration in the snake
+// case of tree-sitter, variable_declaration
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			sb.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
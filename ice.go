@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"compiler/config"
+	"compiler/diag"
+)
+
+// contextLines is how many source lines around the failure the report quotes
+const contextLines = 3
+
+// reportInternalError prints the internal compiler error banner and writes
+// a reproducer bundle to output/ice
+func reportInternalError(ice *diag.InternalError) int {
+	phase := string(ice.Phase)
+	if phase == "" {
+		phase = "unknown"
+	}
+
+	fmt.Fprintf(os.Stderr, "internal compiler error: %v\n", ice.Value)
+	fmt.Fprintf(os.Stderr, "  phase:    %s\n", phase)
+	if ice.Pos.Line > 0 {
+		fmt.Fprintf(os.Stderr, "  position: %s\n", icePosition(ice))
+	}
+	fmt.Fprintln(os.Stderr, "This is a bug in the compiler, not in your program. Please file a bug report")
+
+	if err := writeICEBundle(ice, phase); err != nil {
+		fmt.Fprintf(os.Stderr, "and attach the source file (the reproducer bundle could not be written: %v).\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "and attach the files in %s.\n", config.ICE_DIR)
+	}
+	return EXIT_INTERNAL
+}
+
+// icePosition describes where in the source the compiler failed
+func icePosition(ice *diag.InternalError) string {
+	if ice.Pos.Line == 0 {
+		return "unknown"
+	}
+	position := fmt.Sprintf("line %d", ice.Pos.Line)
+	if ice.Pos.File != "" {
+		position += " of " + ice.Pos.File
+	}
+	if ice.Pos.Column > 0 {
+		position += fmt.Sprintf(", column %d", ice.Pos.Column)
+	}
+	if ice.Token != "" {
+		position += fmt.Sprintf(", token '%s'", ice.Token)
+	}
+	return position
+}
+
+// writeICEBundle saves the source, the token file and a report with the
+// stack trace and the source lines around the failure
+func writeICEBundle(ice *diag.InternalError, phase string) error {
+	if err := os.MkdirAll(config.ICE_DIR, 0755); err != nil {
+		return err
+	}
+
+	source, err := os.ReadFile(config.SOURCE_PATH)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(config.ICE_DIR, filepath.Base(config.SOURCE_PATH)), source, 0644); err != nil {
+		return err
+	}
+	if tokens, err := os.ReadFile(config.TokenPath()); err == nil {
+		if err := os.WriteFile(filepath.Join(config.ICE_DIR, filepath.Base(config.TokenPath())), tokens, 0644); err != nil {
+			return err
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("panic:    %v\nphase:    %s\nposition: %s\n", ice.Value, phase, icePosition(ice)))
+	sb.WriteString(fmt.Sprintf("go:       %s %s/%s\nargs:     %s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH, strings.Join(os.Args[1:], " ")))
+	if ice.Pos.Line > 0 && ice.Pos.File == "" {
+		sb.WriteString("\nsource:\n")
+		lines := strings.Split(string(source), "\n")
+		for i := max(ice.Pos.Line-contextLines, 1); i <= min(ice.Pos.Line+contextLines, len(lines)); i++ {
+			marker := "   "
+			if i == ice.Pos.Line {
+				marker = ">> "
+			}
+			sb.WriteString(fmt.Sprintf("%s%5d  %s\n", marker, i, lines[i-1]))
+		}
+	}
+	sb.WriteString("\nstack:\n")
+	sb.Write(ice.Stack)
+	return os.WriteFile(filepath.Join(config.ICE_DIR, "report.txt"), []byte(sb.String()), 0644)
+}
@@ -1,95 +1,258 @@
 package lexer
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"compiler/config"
+	"compiler/diag"
 	"compiler/pointer"
+	"compiler/sourcefile"
 	"compiler/token"
 )
 
-const MAX_IDENTIFIER_LENGTH = 16
-
 // Lexer represents a lexical analyzer
 type Lexer struct {
+	source
+	including    []source // the files whose scanning an include interrupted
+	pendingLines int
+	errors       int
+	err          error    // error of the context that canceled scanning
+	included     []string // paths of the included files, also those that failed to open
+	reporter     *diag.Reporter
+
+	text      []byte            // spelling of the word or number being scanned
+	spellings map[string]string // spellings scanned so far, shared by their tokens
+}
+
+// source is the state of scanning one file
+type source struct {
+	path   string // empty when reading from an arbitrary reader
 	line   int
-	cursor *pointer.Cursor[rune]
+	column int
+	cursor *pointer.RuneStream
+	closer io.Closer
+}
+
+// New creates a new Lexer instance reading from the configured source file
+// and reporting problems to reporter, failing if the file cannot be opened
+func New(reporter *diag.Reporter) (*Lexer, error) {
+	if config.Mmap {
+		file, err := sourcefile.Open(config.SOURCE_PATH)
+		if err != nil {
+			return nil, err
+		}
+		l := NewFromReaderAt(bytes.NewReader(file.Bytes()), config.SOURCE_PATH, 1, reporter)
+		l.closer = file
+		return l, nil
+	}
+
+	file, err := os.Open(config.SOURCE_PATH)
+	if err != nil {
+		return nil, err
+	}
+	l := NewFromReader(file, reporter)
+	l.path = config.SOURCE_PATH
+	l.closer = file
+	return l, nil
 }
 
-// New creates a new Lexer instance
-func New() *Lexer {
+// NewFromReader creates a new Lexer instance that reads r incrementally
+func NewFromReader(r io.Reader, reporter *diag.Reporter) *Lexer {
 	return &Lexer{
-		line:   1,
-		cursor: pointer.NewCursor([]rune(readSource())),
+		source:   newSource("", r),
+		reporter: reporter,
 	}
 }
 
-// Tokenize processes the source file and generates tokens
-func (l *Lexer) Tokenize() bool {
-	tokens := []token.Token{}
-	errors := []string{}
+// NewFromReaderAt creates a new Lexer instance that reads r as the text of
+// the file at path from the given line on, so that positions and included
+// files resolve as when the whole file is scanned
+func NewFromReaderAt(r io.Reader, path string, line int, reporter *diag.Reporter) *Lexer {
+	l := NewFromReader(r, reporter)
+	l.path, l.line = path, line
+	return l
+}
 
-	for l.cursor.IsOpen() {
-		tok, err := l.getNextToken()
-		if err != nil {
-			errors = append(errors, err.Error())
-			continue
+func newSource(path string, r io.Reader) source {
+	cursor := pointer.NewRuneStream(r)
+	if cursor.IsOpen() && cursor.Current() == '\uFEFF' {
+		cursor.Consume() // UTF-8 byte order mark written by some Windows editors
+	}
+	return source{path: path, line: 1, column: 1, cursor: cursor}
+}
+
+// Tokens scans the whole input, reporting problems to the reporter. The
+// sequence always ends with END_OF_FILE, also when scanning stops early at
+// the error limit.
+func (l *Lexer) Tokens() iter.Seq[token.Token] {
+	return l.TokensContext(context.Background())
+}
+
+// TokensContext scans like Tokens, but stops early once ctx is done, after
+// which Err returns the error of ctx
+func (l *Lexer) TokensContext(ctx context.Context) iter.Seq[token.Token] {
+	return func(yield func(token.Token) bool) {
+		l.reporter.StartPhase(diag.LexerPhase)
+		defer l.close()
+
+		done := ctx.Done()
+		for {
+			select {
+			case <-done:
+				l.err = ctx.Err()
+				yield(token.Token{Type: token.END_OF_FILE, Value: "EOF"})
+				return
+			default:
+			}
+
+			tok, err := l.next()
+			if err != nil {
+				var d diag.Diagnostic
+				if !errors.As(err, &d) {
+					d = diag.Diagnostic{Pos: l.pos(l.column), Severity: diag.Error, Msg: err.Error()}
+				}
+				l.reporter.Report(d)
+				l.errors++
+			}
+			if tok.Type == token.END_OF_FILE || l.reporter.Full() {
+				yield(token.Token{Type: token.END_OF_FILE, Value: "EOF"})
+				return
+			}
+			// Some errors still produce a usable token, which keeps the
+			// parser from reporting follow-up errors
+			if (err == nil || tok.Type != 0) && !yield(tok) {
+				return
+			}
 		}
-		tokens = append(tokens, tok)
 	}
+}
+
+// ErrorCount returns the number of errors found by Tokens so far
+func (l *Lexer) ErrorCount() int {
+	return l.errors
+}
 
-	tokens = append(tokens, token.Token{
-		Type:  token.END_OF_FILE,
-		Value: "EOF",
-	})
+// Included returns the paths of the files included so far, in the order
+// of their directives, also of those that could not be opened
+func (l *Lexer) Included() []string {
+	return l.included
+}
 
-	writeTokens(tokens)
-	writeErrors(errors)
+// Err returns the error of the context that stopped TokensContext, or nil
+// if scanning was not canceled
+func (l *Lexer) Err() error {
+	return l.err
+}
 
-	return len(errors) == 0
+// next calls Next, turning a panic into an internal error that records the
+// position of the lexer
+func (l *Lexer) next() (tok token.Token, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(diag.NewInternalError(diag.LexerPhase, l.pos(l.column), "", r))
+		}
+	}()
+	return l.Next()
 }
 
-func (l *Lexer) getNextToken() (token.Token, error) {
-	// Skip whitespace
-	for l.cursor.IsOpen() && l.cursor.Current() == ' ' {
-		l.cursor.Consume()
+// Next scans and returns the next token, yielding END_OF_FILE once the
+// input is exhausted. Along with an error it returns the zero token, or a
+// placeholder the parser can continue with.
+func (l *Lexer) Next() (tok token.Token, err error) {
+	if l.pendingLines > 0 {
+		l.pendingLines--
+		return token.Token{Type: token.END_OF_LINE, Value: "EOLN"}, nil
 	}
 
+	l.skipSpaces()
+
 	if !l.cursor.IsOpen() {
-		return token.Token{}, fmt.Errorf("unexpected end of input")
+		var err error
+		if readErr := l.cursor.Err(); readErr != nil {
+			err = l.errorAt(l.column, diag.L004, readErr)
+		}
+		if len(l.including) > 0 {
+			l.leaveInclude()
+			return token.SourceMarker(l.path, l.line), err
+		}
+		return token.Token{Type: token.END_OF_FILE, Value: "EOF"}, err
 	}
 
-	initial := l.cursor.Consume()
+	column := l.column
+	defer func() {
+		if tok.Type != 0 {
+			tok.Column = column
+		}
+	}()
+	initial := l.advance()
 
 	if isLetter(initial) {
-		value := string(initial)
+		l.text = utf8.AppendRune(l.text[:0], initial)
 		for l.cursor.IsOpen() && (isLetter(l.cursor.Current()) || isDigit(l.cursor.Current())) {
-			value += string(l.cursor.Consume())
+			l.text = utf8.AppendRune(l.text, l.advance())
 		}
+		value := l.spelling()
 
 		if keywordType := getKeywordType(value); keywordType != 0 {
 			return token.Token{Type: keywordType, Value: value}, nil
 		}
 
-		if len(value) <= MAX_IDENTIFIER_LENGTH {
+		runes := []rune(value)
+		if len(runes) <= config.MaxIdentLength {
 			return token.Token{Type: token.IDENTIFIER, Value: value}, nil
 		}
 
-		return token.Token{}, fmt.Errorf("line %d: Identifier name '%s' exceeds %d characters",
-			l.line, value, MAX_IDENTIFIER_LENGTH)
+		if config.TruncateIdents {
+			truncated := string(runes[:config.MaxIdentLength])
+			l.warnAt(column, diag.W006, value, config.MaxIdentLength, truncated)
+			return token.Token{Type: token.IDENTIFIER, Value: truncated}, nil
+		}
+		return token.Token{}, l.errorAt(column, diag.L003, value, config.MaxIdentLength)
 	}
 
 	if isDigit(initial) {
-		value := string(initial)
+		l.text = utf8.AppendRune(l.text[:0], initial)
 		for l.cursor.IsOpen() && isDigit(l.cursor.Current()) {
-			value += string(l.cursor.Consume())
+			l.text = utf8.AppendRune(l.text, l.advance())
+		}
+		value := l.spelling()
+
+		// Invalid numbers are still passed on as a constant
+		constant := token.Token{Type: token.CONSTANT, Value: "0"}
+		if l.cursor.IsOpen() && isLetter(l.cursor.Current()) {
+			for l.cursor.IsOpen() && (isLetter(l.cursor.Current()) || isDigit(l.cursor.Current())) {
+				value += string(l.advance())
+			}
+			return constant, l.errorAt(column, diag.L005, value)
+		}
+
+		if _, err := strconv.ParseInt(value, 10, 32); err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return constant, l.errorAt(column, diag.L006, value, math.MaxInt32)
+			}
+			return constant, l.errorAt(column, diag.L005, value) // digits outside 0-9
 		}
 		return token.Token{Type: token.CONSTANT, Value: value}, nil
 	}
 
+	// Symbols of extensions are invalid characters in smaller dialects
+	if tokenType, ok := extensionSymbols[initial]; ok && !enabled(tokenType) {
+		return token.Token{}, l.errorAt(column, diag.L002, initial)
+	}
+
 	// Handle special characters
 	switch initial {
 	case '=':
@@ -102,38 +265,227 @@ func (l *Lexer) getNextToken() (token.Token, error) {
 		return token.Token{Type: token.LEFT_PARENTHESES, Value: "("}, nil
 	case ')':
 		return token.Token{Type: token.RIGHT_PARENTHESES, Value: ")"}, nil
+	case ',':
+		return token.Token{Type: token.COMMA, Value: ","}, nil
+	case '[':
+		return token.Token{Type: token.LEFT_BRACKET, Value: "["}, nil
+	case ']':
+		return token.Token{Type: token.RIGHT_BRACKET, Value: "]"}, nil
 	case '<':
 		if l.cursor.IsOpen() {
 			switch l.cursor.Current() {
 			case '=':
-				l.cursor.Consume()
+				l.advance()
 				return token.Token{Type: token.LESS_THAN_OR_EQUAL, Value: "<="}, nil
 			case '>':
-				l.cursor.Consume()
+				l.advance()
 				return token.Token{Type: token.NOT_EQUAL, Value: "<>"}, nil
 			}
 		}
 		return token.Token{Type: token.LESS_THAN, Value: "<"}, nil
 	case '>':
 		if l.cursor.IsOpen() && l.cursor.Current() == '=' {
-			l.cursor.Consume()
+			l.advance()
 			return token.Token{Type: token.GREATER_THAN_OR_EQUAL, Value: ">="}, nil
 		}
 		return token.Token{Type: token.GREATER_THAN, Value: ">"}, nil
 	case ':':
 		if l.cursor.IsOpen() && l.cursor.Current() == '=' {
-			l.cursor.Consume()
+			l.advance()
 			return token.Token{Type: token.ASSIGN, Value: ":="}, nil
 		}
-		return token.Token{}, fmt.Errorf("line %d: Misused colon", l.line)
+		if !enabled(token.COLON) {
+			return token.Token{}, l.errorAt(column, diag.L001)
+		}
+		return token.Token{Type: token.COLON, Value: ":"}, nil
 	case ';':
 		return token.Token{Type: token.SEMICOLON, Value: ";"}, nil
-	case '\n':
-		l.line++
+	case '\'':
+		return l.scanString(column)
+	case '{':
+		return l.scanDirective(column)
+	case '\n', '\r':
+		l.endLine(initial)
+		// Collapse trailing line breaks so the token file ends at the last token
+		for l.skipSpaces(); l.atLineBreak(); l.skipSpaces() {
+			l.endLine(l.advance())
+			l.pendingLines++
+		}
+		if !l.cursor.IsOpen() {
+			l.pendingLines = 0
+			return l.Next()
+		}
 		return token.Token{Type: token.END_OF_LINE, Value: "EOLN"}, nil
 	}
 
-	return token.Token{}, fmt.Errorf("line %d: Invalid character '%c'", l.line, initial)
+	return token.Token{}, l.errorAt(column, diag.L002, initial)
+}
+
+// spelling returns the spelling in l.text, allocating each distinct one
+// once since names repeat throughout a program
+func (l *Lexer) spelling() string {
+	if s, ok := l.spellings[string(l.text)]; ok {
+		return s
+	}
+	if l.spellings == nil {
+		l.spellings = make(map[string]string)
+	}
+	s := string(l.text)
+	l.spellings[s] = s
+	return s
+}
+
+// scanString scans a string literal after its opening quote, in which a
+// doubled quote stands for a single one. The token keeps the literal as
+// written, quotes included.
+func (l *Lexer) scanString(column int) (token.Token, error) {
+	value := "'"
+	for {
+		if !l.cursor.IsOpen() || l.atLineBreak() {
+			// Close the literal so the parser can continue with the statement
+			return token.Token{Type: token.STRING, Value: value + "'"}, l.errorAt(column, diag.L007)
+		}
+		ch := l.advance()
+		value += string(ch)
+		if ch != '\'' {
+			continue
+		}
+		if !l.cursor.IsOpen() || l.cursor.Current() != '\'' {
+			return token.Token{Type: token.STRING, Value: value}, nil
+		}
+		value += string(l.advance())
+	}
+}
+
+// scanDirective scans a {$include 'file'} directive after its opening brace
+// and continues with the tokens of the included file
+func (l *Lexer) scanDirective(column int) (token.Token, error) {
+	text := "{"
+	for l.cursor.IsOpen() && !l.atLineBreak() && l.cursor.Current() != '}' {
+		text += string(l.advance())
+	}
+	if !l.cursor.IsOpen() || l.cursor.Current() != '}' {
+		return token.Token{}, l.errorAt(column, diag.L008, text)
+	}
+	text += string(l.advance())
+
+	// Only {$include 'file'} is known, with the name written as a string
+	const directive = "$include"
+	inner := text[1 : len(text)-1]
+	if len(inner) < len(directive) || !strings.EqualFold(inner[:len(directive)], directive) {
+		return token.Token{}, l.errorAt(column, diag.L008, text)
+	}
+	name := strings.TrimSpace(inner[len(directive):])
+	if len(name) < 2 || !strings.HasPrefix(name, "'") || !strings.HasSuffix(name, "'") {
+		return token.Token{}, l.errorAt(column, diag.L008, text)
+	}
+	return l.include(column, name[1:len(name)-1])
+}
+
+// include continues scanning in the named file, which is relative to the
+// directory of the current one, and returns the marker of its first line
+func (l *Lexer) include(column int, name string) (token.Token, error) {
+	if config.NoIncludes {
+		return token.Token{}, l.errorAt(column, diag.L009, name, diag.IncludeForbidden)
+	}
+	path := filepath.Join(filepath.Dir(l.path), name)
+	if path == l.path || slices.ContainsFunc(l.including, func(s source) bool { return s.path == path }) {
+		return token.Token{}, l.errorAt(column, diag.L009, name, diag.IncludeItself)
+	}
+	l.included = append(l.included, path)
+	file, err := os.Open(path)
+	if err != nil {
+		failure := diag.IncludeUnreadable
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			failure = diag.IncludeMissing
+		case errors.Is(err, fs.ErrPermission):
+			failure = diag.IncludeDenied
+		}
+		return token.Token{}, l.errorAt(column, diag.L009, name, failure)
+	}
+
+	l.including = append(l.including, l.source)
+	l.source = newSource(path, file)
+	l.closer = file
+	return token.SourceMarker(path, 1), nil
+}
+
+// leaveInclude returns to the file that included the current one
+func (l *Lexer) leaveInclude() {
+	l.closer.Close()
+	l.source = l.including[len(l.including)-1]
+	l.including = l.including[:len(l.including)-1]
+}
+
+// close closes the files still open when scanning ends
+func (l *Lexer) close() {
+	for _, s := range append(l.including, l.source) {
+		if s.closer != nil {
+			s.closer.Close()
+		}
+	}
+}
+
+// advance consumes the current rune, keeping the column up to date
+func (l *Lexer) advance() rune {
+	ch := l.cursor.Consume()
+	switch ch {
+	case '\n':
+		l.column = 1
+	case '\t':
+		// Columns are display columns, so a tab moves to the next tab stop
+		l.column = (l.column-1)/config.TabWidth*config.TabWidth + config.TabWidth + 1
+	default:
+		l.column++
+	}
+	return ch
+}
+
+// atLineBreak reports whether the current rune starts a line break
+func (l *Lexer) atLineBreak() bool {
+	return l.cursor.IsOpen() && (l.cursor.Current() == '\n' || l.cursor.Current() == '\r')
+}
+
+// endLine finishes the line break started by ch, so that "\r\n" counts as
+// a single end of line
+func (l *Lexer) endLine(ch rune) {
+	if ch == '\r' && l.cursor.IsOpen() && l.cursor.Current() == '\n' {
+		l.cursor.Consume()
+	}
+	l.line++
+	l.column = 1
+}
+
+func (l *Lexer) errorAt(column int, code diag.Code, args ...any) diag.Diagnostic {
+	d := diag.New(l.line, code, args...)
+	d.Pos = l.pos(column)
+	return d
+}
+
+// pos returns the position of column in the current line, naming the file
+// only inside an included one
+func (l *Lexer) pos(column int) diag.Pos {
+	pos := diag.Pos{Line: l.line, Column: column}
+	if len(l.including) > 0 {
+		pos.File = l.path
+	}
+	return pos
+}
+
+// warnAt reports a warning directly, since it doesn't stop the token from
+// being produced
+func (l *Lexer) warnAt(column int, code diag.Code, args ...any) {
+	if d, ok := diag.NewWarning(l.line, code, args...); ok {
+		d.Pos = l.pos(column)
+		l.reporter.Report(d)
+	}
+}
+
+func (l *Lexer) skipSpaces() {
+	for l.cursor.IsOpen() && (l.cursor.Current() == ' ' || l.cursor.Current() == '\t') {
+		l.advance()
+	}
 }
 
 // Helper functions
@@ -145,46 +497,81 @@ func isDigit(ch rune) bool {
 	return unicode.IsDigit(ch)
 }
 
+// IsKeyword reports whether value is a reserved word, ignoring case
+func IsKeyword(value string) bool {
+	return getKeywordType(value) != 0
+}
+
+// keywords maps the reserved words, in lower case, to their token types
+var keywords = map[string]token.TokenType{
+	"begin":     token.BEGIN,
+	"end":       token.END,
+	"integer":   token.INTEGER,
+	"if":        token.IF,
+	"then":      token.THEN,
+	"else":      token.ELSE,
+	"function":  token.FUNCTION,
+	"read":      token.READ,
+	"write":     token.WRITE,
+	"mod":       token.MOD,
+	"div":       token.DIV,
+	"writeln":   token.WRITELN,
+	"var":       token.VAR,
+	"procedure": token.PROCEDURE,
+	"repeat":    token.REPEAT,
+	"until":     token.UNTIL,
+	"for":       token.FOR,
+	"to":        token.TO,
+	"do":        token.DO,
+	"while":     token.WHILE,
+	"case":      token.CASE,
+	"of":        token.OF,
+	"forward":   token.FORWARD,
+}
+
 func getKeywordType(value string) token.TokenType {
-	keywordMap := map[string]token.TokenType{
-		"begin":    token.BEGIN,
-		"end":      token.END,
-		"integer":  token.INTEGER,
-		"if":       token.IF,
-		"then":     token.THEN,
-		"else":     token.ELSE,
-		"function": token.FUNCTION,
-		"read":     token.READ,
-		"write":    token.WRITE,
-	}
-
-	if tokType, ok := keywordMap[strings.ToLower(value)]; ok {
+	if tokType, ok := keywords[strings.ToLower(value)]; ok && enabled(tokType) {
 		return tokType
 	}
 	return 0
 }
 
-// File operations
-func readSource() string {
-	data, err := os.ReadFile(config.SOURCE_PATH)
-	if err != nil {
-		panic(err)
-	}
-	return strings.TrimSpace(string(data))
+// extensions gives the smallest --dialect that has each token type beyond
+// the course grammar
+var extensions = map[token.TokenType]string{
+	token.MOD:           "std",
+	token.DIV:           "std",
+	token.COMMA:         "std",
+	token.WRITELN:       "std",
+	token.STRING:        "std",
+	token.VAR:           "ext",
+	token.PROCEDURE:     "ext",
+	token.LEFT_BRACKET:  "ext",
+	token.RIGHT_BRACKET: "ext",
+	token.REPEAT:        "ext",
+	token.UNTIL:         "ext",
+	token.FOR:           "ext",
+	token.TO:            "ext",
+	token.DO:            "ext",
+	token.WHILE:         "ext",
+	token.CASE:          "ext",
+	token.OF:            "ext",
+	token.COLON:         "ext",
+	token.FORWARD:       "ext",
+	token.SOURCE_FILE:   "ext",
 }
 
-func writeTokens(tokens []token.Token) error {
-	var sb strings.Builder
-	for _, tok := range tokens {
-		padding := strings.Repeat(" ", 16-len(tok.Value))
-		sb.WriteString(fmt.Sprintf("%s%s %02d\n", tok.Value, padding, tok.Type))
-	}
-	return os.WriteFile(config.DYD_PATH, []byte(sb.String()), 0644)
+// extensionSymbols maps the characters that start extension tokens to them
+var extensionSymbols = map[rune]token.TokenType{
+	',':  token.COMMA,
+	'\'': token.STRING,
+	'[':  token.LEFT_BRACKET,
+	']':  token.RIGHT_BRACKET,
+	'{':  token.SOURCE_FILE,
 }
 
-func writeErrors(errors []string) error {
-	if len(errors) == 0 {
-		return nil
-	}
-	return os.WriteFile(config.ERR_PATH, []byte(strings.Join(errors, "\n")), 0644)
+// enabled reports whether the selected dialect has the token type
+func enabled(tokenType token.TokenType) bool {
+	dialect, ok := extensions[tokenType]
+	return !ok || config.DialectAtLeast(dialect)
 }
@@ -0,0 +1,276 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Config selects the rules of the lint command and their limits. Each rule
+// is a table of compiler.toml named after it:
+//
+//	[lint.naming]
+//	variables = "^[a-z][a-zA-Z0-9]*$"
+//	procedures = "^[A-Z][a-zA-Z0-9]*$"
+//
+//	[lint.nesting]
+//	max = 3
+//
+//	[lint.magic-number]
+//	enabled = true
+//	allowed = [0, 1, 10]
+type Config struct {
+	Naming         bool
+	Variables      *regexp.Regexp // names of variables and parameters
+	Procedures     *regexp.Regexp // names of procedures and functions
+	Shadowing      bool
+	Nesting        bool
+	MaxNesting     int // if, case and loop statements inside each other
+	FunctionLength bool
+	MaxStatements  int // statements of a body, nested ones included
+	MagicNumber    bool
+	Allowed        []int // numbers that may appear in expressions
+}
+
+// DefaultConfig returns the rules used without a compiler.toml. The magic
+// number rule is off, since the language has no constants to name numbers.
+func DefaultConfig() Config {
+	return Config{
+		Naming:         true,
+		Variables:      regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+		Procedures:     regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+		Shadowing:      true,
+		Nesting:        true,
+		MaxNesting:     3,
+		FunctionLength: true,
+		MaxStatements:  30,
+		Allowed:        []int{0, 1},
+	}
+}
+
+// Rules lists the names of the rules, as in the tables of compiler.toml
+var Rules = []string{"naming", "shadowing", "nesting", "function-length", "magic-number"}
+
+// LoadConfig reads the lint tables of the TOML file at path over the
+// defaults. A missing file leaves the defaults; tables outside [lint.*]
+// are left to other tools.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	tables, err := parseTOML(string(data))
+	if err != nil {
+		return cfg, fmt.Errorf("%s:%w", path, err)
+	}
+	for _, table := range tables {
+		rule, ok := strings.CutPrefix(table.name, "lint.")
+		if !ok {
+			continue
+		}
+		if !slices.Contains(Rules, rule) {
+			return cfg, fmt.Errorf("%s:%d: unknown lint rule '%s', expected %s", path, table.line, rule, strings.Join(Rules, ", "))
+		}
+		for _, kv := range table.values {
+			if err := cfg.set(rule, kv); err != nil {
+				return cfg, fmt.Errorf("%s:%d: %w", path, kv.line, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// set applies an option of a rule
+func (c *Config) set(rule string, kv keyValue) error {
+	if kv.key == "enabled" {
+		on, ok := kv.value.(bool)
+		if !ok {
+			return fmt.Errorf("enabled must be true or false")
+		}
+		*c.enabled(rule) = on
+		return nil
+	}
+
+	switch rule + "." + kv.key {
+	case "naming.variables", "naming.procedures":
+		pattern, ok := kv.value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", kv.key)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: %w", kv.key, err)
+		}
+		if kv.key == "variables" {
+			c.Variables = re
+		} else {
+			c.Procedures = re
+		}
+	case "nesting.max", "function-length.max":
+		n, ok := kv.value.(int)
+		if !ok || n < 1 {
+			return fmt.Errorf("max must be a positive integer")
+		}
+		if rule == "nesting" {
+			c.MaxNesting = n
+		} else {
+			c.MaxStatements = n
+		}
+	case "magic-number.allowed":
+		numbers, ok := kv.value.([]any)
+		c.Allowed = nil
+		for _, v := range numbers {
+			n, isInt := v.(int)
+			if !isInt {
+				ok = false
+			}
+			c.Allowed = append(c.Allowed, n)
+		}
+		if !ok {
+			return fmt.Errorf("allowed must be an array of integers")
+		}
+	default:
+		return fmt.Errorf("unknown option '%s' of rule %s", kv.key, rule)
+	}
+	return nil
+}
+
+// enabled returns the switch of a rule
+func (c *Config) enabled(rule string) *bool {
+	switch rule {
+	case "naming":
+		return &c.Naming
+	case "shadowing":
+		return &c.Shadowing
+	case "nesting":
+		return &c.Nesting
+	case "function-length":
+		return &c.FunctionLength
+	}
+	return &c.MagicNumber
+}
+
+// table is a [name] table of a TOML file with its key/value pairs in order
+type table struct {
+	name   string
+	line   int
+	values []keyValue
+}
+
+type keyValue struct {
+	key   string
+	value any // bool, int, string or []any of those
+	line  int
+}
+
+// parseTOML parses the part of TOML that compiler.toml needs: tables, and
+// keys with booleans, integers, basic strings or arrays of them as values.
+// Keys before the first table belong to a table with an empty name.
+func parseTOML(text string) ([]table, error) {
+	tables := []table{{}}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name, ok := strings.CutSuffix(strings.TrimPrefix(line, "["), "]")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" || strings.HasPrefix(name, "[") {
+				return nil, fmt.Errorf("%d: malformed table header %s", i+1, line)
+			}
+			tables = append(tables, table{name: name, line: i + 1})
+			continue
+		}
+		key, text, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%d: expected key = value", i+1)
+		}
+		value, rest, err := parseValue(strings.TrimSpace(text))
+		if err == nil && strings.TrimSpace(rest) != "" {
+			err = fmt.Errorf("unexpected %s after the value", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", i+1, err)
+		}
+		current := &tables[len(tables)-1]
+		current.values = append(current.values, keyValue{strings.Trim(strings.TrimSpace(key), `"`), value, i + 1})
+	}
+	return tables, nil
+}
+
+// stripComment removes a comment that starts with '#' outside a string
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && inString:
+			i++
+		case line[i] == '"':
+			inString = !inString
+		case line[i] == '#' && !inString:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseValue parses the value at the start of text and returns the rest
+func parseValue(text string) (any, string, error) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+				continue
+			}
+			if text[i] == '"' {
+				s, err := strconv.Unquote(text[:i+1])
+				return s, text[i+1:], err
+			}
+		}
+		return nil, "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(text, "["):
+		var values []any
+		rest := strings.TrimSpace(text[1:])
+		for !strings.HasPrefix(rest, "]") {
+			value, after, err := parseValue(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			values = append(values, value)
+			rest = strings.TrimSpace(after)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+		return values, rest[1:], nil
+	}
+
+	end := strings.IndexAny(text, ",] \t")
+	if end < 0 {
+		end = len(text)
+	}
+	word, rest := text[:end], text[end:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(word, "_", ""))
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported value %s", word)
+	}
+	return n, rest, nil
+}
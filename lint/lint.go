@@ -0,0 +1,173 @@
+// Package lint checks a program against rules of style rather than
+// correctness, such as naming conventions and the length of procedures.
+// The rules report warnings to the same diagnostics as the compiler.
+package lint
+
+import (
+	"maps"
+	"slices"
+
+	"compiler/ast"
+	"compiler/diag"
+	"compiler/parser"
+)
+
+// checker runs the enabled rules on one program
+type checker struct {
+	cfg      Config
+	reporter *diag.Reporter
+	columns  map[declaration]int // of the names of the declarations
+}
+
+// declaration identifies where a name is declared
+type declaration struct {
+	file string
+	line int
+	name string
+}
+
+// Run checks prog, the tree of the program pars has parsed, against the
+// rules cfg enables. A program with syntax errors has no tree and is not
+// checked.
+func Run(reporter *diag.Reporter, pars *parser.Parser, prog *ast.Program, cfg Config) {
+	if prog == nil {
+		return
+	}
+	c := &checker{cfg: cfg, reporter: reporter, columns: make(map[declaration]int)}
+	for _, u := range pars.Uses() {
+		if u.Declaration {
+			c.columns[declaration{u.File, u.Line, u.Name}] = u.Column
+		}
+	}
+	c.block("main", prog.Body, nil)
+}
+
+// scope holds the variables visible in a block by name
+type scope map[string]visible
+
+// visible is where a variable in scope was declared
+type visible struct {
+	procedure string
+	line      int
+}
+
+// block checks the body of the program or a procedure named name, with
+// outer holding the variables of the enclosing blocks
+func (c *checker) block(name string, body *ast.Block, outer scope) {
+	// A variable of an enclosing block declared after the procedure is not
+	// visible in it, so the scope grows with the declarations
+	inner := maps.Clone(outer)
+	if inner == nil {
+		inner = make(scope)
+	}
+	for _, decl := range body.Declarations {
+		switch decl := decl.(type) {
+		case *ast.VarDecl:
+			c.variable(name, decl.Pos, decl.Name, outer)
+			inner[decl.Name] = visible{name, decl.Line}
+		case *ast.ProcDecl:
+			c.procedure(decl, inner)
+		}
+	}
+
+	if c.cfg.FunctionLength {
+		if n := countStatements(body.Statements); n > c.cfg.MaxStatements {
+			c.warn(body.Pos, 0, diag.W011, name, n, c.cfg.MaxStatements)
+		}
+	}
+	for _, stmt := range body.Statements {
+		c.statement(stmt, 1, false)
+		if c.cfg.MagicNumber {
+			ast.Inspect(stmt, func(n *ast.IntLit) bool {
+				if !slices.Contains(c.cfg.Allowed, n.Value) {
+					c.warn(n.Pos, 0, diag.W012, n.Value)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// procedure checks the declaration of a procedure and its body
+func (c *checker) procedure(decl *ast.ProcDecl, outer scope) {
+	if decl.Body == nil {
+		return // a forward declaration, checked with its definition
+	}
+	if c.cfg.Naming && !c.cfg.Procedures.MatchString(decl.Name) {
+		c.warn(decl.Pos, c.column(decl.Pos, decl.Name), diag.W008, decl.Name, c.cfg.Procedures, "procedures")
+	}
+	// The parameter is declared again in the body, where it is checked
+	c.block(decl.Name, decl.Body, outer)
+}
+
+// variable checks the declaration of a variable of the block of procedure
+func (c *checker) variable(procedure string, pos ast.Pos, name string, outer scope) {
+	column := c.column(pos, name)
+	if c.cfg.Naming && !c.cfg.Variables.MatchString(name) {
+		c.warn(pos, column, diag.W008, name, c.cfg.Variables, "variables")
+	}
+	if shadowed, ok := outer[name]; ok && c.cfg.Shadowing {
+		c.warn(pos, column, diag.W009, name, procedure, shadowed.procedure, shadowed.line)
+	}
+}
+
+// statement checks the nesting of a statement inside depth - 1 if, case
+// and loop statements. Once a statement is reported as nested too deeply,
+// the ones inside it are not.
+func (c *checker) statement(stmt ast.Stmt, depth int, reported bool) {
+	var bodies []ast.Stmt
+	switch s := stmt.(type) {
+	case *ast.IfStmt:
+		bodies = []ast.Stmt{s.Then, s.Else}
+	case *ast.CaseStmt:
+		for _, branch := range s.Branches {
+			bodies = append(bodies, branch.Body)
+		}
+		bodies = append(bodies, s.Else)
+	case *ast.WhileStmt:
+		bodies = []ast.Stmt{s.Body}
+	case *ast.RepeatStmt:
+		bodies = s.Body
+	case *ast.ForStmt:
+		bodies = []ast.Stmt{s.Body}
+	default:
+		return
+	}
+
+	if c.cfg.Nesting && depth > c.cfg.MaxNesting && !reported {
+		c.warn(stmt.Position(), 0, diag.W010, depth, c.cfg.MaxNesting)
+		reported = true
+	}
+	for _, body := range bodies {
+		if body != nil {
+			c.statement(body, depth+1, reported)
+		}
+	}
+}
+
+// countStatements counts stmts and the statements inside them
+func countStatements(stmts []ast.Stmt) int {
+	n := 0
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(ast.Stmt) bool {
+			n++
+			return true
+		})
+	}
+	return n
+}
+
+// column returns the column of the declaration of name at pos, or 0
+func (c *checker) column(pos ast.Pos, name string) int {
+	return c.columns[declaration{pos.File, pos.Line, name}]
+}
+
+// warn reports a warning at pos
+func (c *checker) warn(pos ast.Pos, column int, code diag.Code, args ...any) {
+	d, ok := diag.NewWarning(pos.Line, code, args...)
+	if !ok {
+		return
+	}
+	d.Pos = diag.Pos{File: pos.File, Line: pos.Line, Column: column}
+	c.reporter.Report(d)
+}
@@ -0,0 +1,121 @@
+// Package lr builds the SLR(1) parsing tables of the language from its
+// grammar, for the table driven parser selected by --parser=lr
+package lr
+
+import (
+	"fmt"
+	"strings"
+
+	"compiler/grammar"
+	"compiler/token"
+)
+
+// EndMarker is the lookahead after the EOF token, on which the parser accepts
+const EndMarker token.TokenType = 0
+
+// Symbol is a terminal or a nonterminal in a production
+type Symbol struct {
+	Terminal bool
+	Token    token.TokenType // of a terminal
+	Name     string          // of a nonterminal
+}
+
+func (s Symbol) String() string {
+	if s.Terminal {
+		return spelling(s.Token)
+	}
+	return s.Name
+}
+
+func spelling(t token.TokenType) string {
+	if t == EndMarker {
+		return "$"
+	}
+	return grammar.Spelling(t)
+}
+
+// Production is a rule of the grammar in BNF
+type Production struct {
+	Head string
+	Body []Symbol
+}
+
+func (p Production) String() string {
+	if len(p.Body) == 0 {
+		return p.Head + " -> ε"
+	}
+	parts := make([]string, len(p.Body))
+	for i, symbol := range p.Body {
+		parts[i] = symbol.String()
+	}
+	return p.Head + " -> " + strings.Join(parts, " ")
+}
+
+// converter rewrites EBNF rules into productions, introducing a helper
+// nonterminal named after the rule for each nested choice, option and
+// repetition
+type converter struct {
+	productions []Production
+	helpers     map[string]int
+}
+
+// productions returns the BNF of g. The first production is the augmented
+// start rule, whose reduction accepts the program.
+func productions(g *grammar.Grammar) []Production {
+	start := g.Rules[0].Name
+	c := &converter{helpers: make(map[string]int)}
+	c.add(start+"'", []Symbol{{Name: start}})
+	for _, rule := range g.Rules {
+		alternatives := []grammar.Expr{rule.Body}
+		if rule.Body.Kind == grammar.Choice {
+			alternatives = rule.Body.Items
+		}
+		for _, alternative := range alternatives {
+			c.add(rule.Name, c.symbols(rule.Name, alternative))
+		}
+	}
+	return c.productions
+}
+
+func (c *converter) add(head string, body []Symbol) {
+	c.productions = append(c.productions, Production{Head: head, Body: body})
+}
+
+func (c *converter) helper(rule string) string {
+	c.helpers[rule]++
+	return fmt.Sprintf("%s.%d", rule, c.helpers[rule])
+}
+
+func (c *converter) symbols(rule string, e grammar.Expr) []Symbol {
+	switch e.Kind {
+	case grammar.Terminal:
+		return []Symbol{{Terminal: true, Token: e.Token}}
+	case grammar.Nonterminal:
+		return []Symbol{{Name: e.Name}}
+	case grammar.Sequence:
+		var symbols []Symbol
+		for _, item := range e.Items {
+			symbols = append(symbols, c.symbols(rule, item)...)
+		}
+		return symbols
+	case grammar.Choice:
+		name := c.helper(rule)
+		for _, item := range e.Items {
+			c.add(name, c.symbols(rule, item))
+		}
+		return []Symbol{{Name: name}}
+	case grammar.Optional:
+		name := c.helper(rule)
+		c.add(name, nil)
+		c.add(name, c.symbols(rule, e.Items[0]))
+		return []Symbol{{Name: name}}
+	case grammar.Repetition:
+		// Left recursion keeps the stack flat and avoids the conflicts a
+		// right recursive list has with an optional item after it
+		name := c.helper(rule)
+		c.add(name, nil)
+		c.add(name, append([]Symbol{{Name: name}}, c.symbols(rule, e.Items[0])...))
+		return []Symbol{{Name: name}}
+	}
+	panic(fmt.Sprintf("unknown expression kind %d", e.Kind))
+}
@@ -0,0 +1,76 @@
+package lr
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Write dumps the productions, the item set of each state with its ACTION
+// and GOTO entries, and the conflicts, for output.lr
+func (t *Table) Write(w io.Writer) error {
+	var lines []string
+	lines = append(lines, "Productions")
+	for i, production := range t.Productions {
+		lines = append(lines, fmt.Sprintf("%4d  %s", i, production))
+	}
+
+	for state, items := range t.States {
+		lines = append(lines, "", fmt.Sprintf("State %d", state))
+		for _, item := range items {
+			lines = append(lines, "      "+t.itemString(item))
+		}
+
+		var actions []string
+		for _, lookahead := range t.Expected(state) {
+			actions = append(actions, spelling(lookahead)+" "+t.Action[state][lookahead].String())
+		}
+		if len(actions) > 0 {
+			lines = append(lines, "  ACTION  "+strings.Join(actions, ", "))
+		}
+
+		var gotos []string
+		for _, name := range t.gotoOrder(state) {
+			gotos = append(gotos, fmt.Sprintf("%s %d", name, t.Goto[state][name]))
+		}
+		if len(gotos) > 0 {
+			lines = append(lines, "  GOTO    "+strings.Join(gotos, ", "))
+		}
+	}
+
+	lines = append(lines, "", fmt.Sprintf("Conflicts: %d", len(t.Conflicts)))
+	for _, conflict := range t.Conflicts {
+		lines = append(lines, "  "+conflict)
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func (t *Table) itemString(item Item) string {
+	production := t.Productions[item.Production]
+	parts := []string{production.Head, "->"}
+	for i, symbol := range production.Body {
+		if i == item.Dot {
+			parts = append(parts, ".")
+		}
+		parts = append(parts, symbol.String())
+	}
+	if item.Dot == len(production.Body) {
+		parts = append(parts, ".")
+	}
+	return strings.Join(parts, " ")
+}
+
+// gotoOrder returns the nonterminals with a GOTO entry in state, in the
+// order the productions define them
+func (t *Table) gotoOrder(state int) []string {
+	var names []string
+	for _, production := range t.Productions {
+		if _, ok := t.Goto[state][production.Head]; ok && !slices.Contains(names, production.Head) {
+			names = append(names, production.Head)
+		}
+	}
+	return names
+}
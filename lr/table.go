@@ -0,0 +1,239 @@
+package lr
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+
+	"compiler/grammar"
+	"compiler/token"
+)
+
+// ActionKind tells the entries of the ACTION table apart
+type ActionKind int
+
+const (
+	Error  ActionKind = iota // no entry: the lookahead is a syntax error
+	Shift                    // push the token and go to Target
+	Reduce                   // replace the body of production Target by its head
+	Accept                   // the program is complete
+)
+
+// Action is an entry of the ACTION table
+type Action struct {
+	Kind   ActionKind
+	Target int
+}
+
+func (a Action) String() string {
+	switch a.Kind {
+	case Shift:
+		return fmt.Sprintf("s%d", a.Target)
+	case Reduce:
+		return fmt.Sprintf("r%d", a.Target)
+	case Accept:
+		return "acc"
+	}
+	return ""
+}
+
+// Item is a production with a dot before its Dot'th symbol
+type Item struct {
+	Production int
+	Dot        int
+}
+
+// Table holds the LR(0) automaton of a grammar with its SLR(1) ACTION and
+// GOTO tables
+type Table struct {
+	Productions []Production
+	States      [][]Item // closed item set of each state
+	Action      []map[token.TokenType]Action
+	Goto        []map[string]int
+	Conflicts   []string // resolved in favor of shifting, or of the earlier production
+}
+
+// Build constructs the tables of g
+func Build(g *grammar.Grammar) *Table {
+	t := &Table{Productions: productions(g)}
+	first, nullable := t.first()
+	follow := t.follow(first, nullable)
+
+	index := map[string]int{}
+	addState := func(items []Item) int {
+		key := fmt.Sprint(items)
+		if i, ok := index[key]; ok {
+			return i
+		}
+		index[key] = len(t.States)
+		t.States = append(t.States, items)
+		t.Action = append(t.Action, make(map[token.TokenType]Action))
+		t.Goto = append(t.Goto, make(map[string]int))
+		return len(t.States) - 1
+	}
+	addState(t.closure([]Item{{}}))
+
+	// States are numbered in the order they are discovered, following the
+	// symbols after the dots in item order, so that the tables are stable
+	for state := 0; state < len(t.States); state++ {
+		var symbols []Symbol
+		for _, item := range t.States[state] {
+			if symbol, ok := t.next(item); ok && !slices.Contains(symbols, symbol) {
+				symbols = append(symbols, symbol)
+			}
+		}
+		for _, symbol := range symbols {
+			target := addState(t.advance(t.States[state], symbol))
+			if symbol.Terminal {
+				t.setAction(state, symbol.Token, Action{Kind: Shift, Target: target})
+			} else {
+				t.Goto[state][symbol.Name] = target
+			}
+		}
+
+		for _, item := range t.States[state] {
+			if _, ok := t.next(item); ok {
+				continue
+			}
+			if item.Production == 0 {
+				t.setAction(state, EndMarker, Action{Kind: Accept})
+				continue
+			}
+			head := t.Productions[item.Production].Head
+			for _, lookahead := range slices.Sorted(maps.Keys(follow[head])) {
+				t.setAction(state, lookahead, Action{Kind: Reduce, Target: item.Production})
+			}
+		}
+	}
+	return t
+}
+
+// Expected returns the terminals on which state has an action, in token order
+func (t *Table) Expected(state int) []token.TokenType {
+	return slices.Sorted(maps.Keys(t.Action[state]))
+}
+
+// setAction enters an action, recording a conflict with an existing one
+func (t *Table) setAction(state int, lookahead token.TokenType, action Action) {
+	existing, ok := t.Action[state][lookahead]
+	if !ok || existing == action {
+		t.Action[state][lookahead] = action
+		return
+	}
+
+	kept := existing
+	if action.Kind == Shift || (existing.Kind == Reduce && action.Kind == Reduce && action.Target < existing.Target) {
+		kept = action
+	}
+	t.Conflicts = append(t.Conflicts, fmt.Sprintf("state %d on %s: %s or %s, chose %s",
+		state, spelling(lookahead), existing, action, kept))
+	t.Action[state][lookahead] = kept
+}
+
+// next returns the symbol after the dot of item, if any
+func (t *Table) next(item Item) (Symbol, bool) {
+	body := t.Productions[item.Production].Body
+	if item.Dot >= len(body) {
+		return Symbol{}, false
+	}
+	return body[item.Dot], true
+}
+
+// closure adds the items of every nonterminal that appears after a dot
+func (t *Table) closure(items []Item) []Item {
+	for i := 0; i < len(items); i++ {
+		symbol, ok := t.next(items[i])
+		if !ok || symbol.Terminal {
+			continue
+		}
+		for p, production := range t.Productions {
+			item := Item{Production: p}
+			if production.Head == symbol.Name && !slices.Contains(items, item) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// advance moves the dot over symbol in the items of a state
+func (t *Table) advance(items []Item, symbol Symbol) []Item {
+	var kernel []Item
+	for _, item := range items {
+		if next, ok := t.next(item); ok && next == symbol {
+			kernel = append(kernel, Item{Production: item.Production, Dot: item.Dot + 1})
+		}
+	}
+	// Equal kernels must give equal keys in Build
+	slices.SortFunc(kernel, func(a, b Item) int {
+		return cmp.Or(cmp.Compare(a.Production, b.Production), cmp.Compare(a.Dot, b.Dot))
+	})
+	return t.closure(kernel)
+}
+
+// first returns the FIRST set and the nullability of each nonterminal
+func (t *Table) first() (map[string]map[token.TokenType]bool, map[string]bool) {
+	first := map[string]map[token.TokenType]bool{}
+	nullable := map[string]bool{}
+	for _, production := range t.Productions {
+		first[production.Head] = map[token.TokenType]bool{}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, production := range t.Productions {
+			set := first[production.Head]
+			size := len(set)
+			if t.addFirst(set, production.Body, first, nullable) && !nullable[production.Head] {
+				nullable[production.Head] = true
+				changed = true
+			}
+			changed = changed || len(set) != size
+		}
+	}
+	return first, nullable
+}
+
+// addFirst adds the terminals that can start symbols to set and reports
+// whether symbols can derive the empty string
+func (t *Table) addFirst(set map[token.TokenType]bool, symbols []Symbol, first map[string]map[token.TokenType]bool, nullable map[string]bool) bool {
+	for _, symbol := range symbols {
+		if symbol.Terminal {
+			set[symbol.Token] = true
+			return false
+		}
+		maps.Copy(set, first[symbol.Name])
+		if !nullable[symbol.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// follow returns the FOLLOW set of each nonterminal
+func (t *Table) follow(first map[string]map[token.TokenType]bool, nullable map[string]bool) map[string]map[token.TokenType]bool {
+	follow := map[string]map[token.TokenType]bool{}
+	for _, production := range t.Productions {
+		follow[production.Head] = map[token.TokenType]bool{}
+	}
+	follow[t.Productions[0].Head][EndMarker] = true
+
+	for changed := true; changed; {
+		changed = false
+		for _, production := range t.Productions {
+			for i, symbol := range production.Body {
+				if symbol.Terminal {
+					continue
+				}
+				set := follow[symbol.Name]
+				size := len(set)
+				if t.addFirst(set, production.Body[i+1:], first, nullable) {
+					maps.Copy(set, follow[production.Head])
+				}
+				changed = changed || len(set) != size
+			}
+		}
+	}
+	return follow
+}
@@ -0,0 +1,107 @@
+package lr
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"compiler/grammar"
+	"compiler/token"
+)
+
+// yaccNames names the tokens of more than one character that are not
+// keywords, which yacc can't write as character literals
+var yaccNames = map[token.TokenType]string{
+	token.IDENTIFIER:            "IDENTIFIER",
+	token.CONSTANT:              "CONSTANT",
+	token.STRING:                "STRING",
+	token.ASSIGN:                "ASSIGN",
+	token.NOT_EQUAL:             "NOT_EQUAL",
+	token.LESS_THAN_OR_EQUAL:    "LESS_EQUAL",
+	token.GREATER_THAN_OR_EQUAL: "GREATER_EQUAL",
+}
+
+// WriteYacc writes the grammar in the BNF the LR tables are built from, as
+// the rules section of a yacc file without actions, so that a generated
+// parser can be checked against the hand-written one. The helper
+// nonterminals keep their names, which yacc accepts. Keywords are named
+// KW_ and their upper case, keeping clear of the BEGIN macro of lex, and
+// end of file is the end of input of yacc.
+func WriteYacc(w io.Writer, g *grammar.Grammar) error {
+	var sb strings.Builder
+	sb.WriteString("/* Generated by the grammar command of the compiler; regenerate it rather than\n")
+	sb.WriteString("   editing it, so that it stays in step with the parser. */\n\n")
+
+	// The productions of a rule are written together, with those of its
+	// helpers after them
+	var heads []string
+	bodies := make(map[string][][]string)
+	var named []string
+	for _, p := range productions(g)[1:] { // yacc augments the grammar itself
+		if _, ok := bodies[p.Head]; !ok {
+			heads = append(heads, p.Head)
+		}
+		var body []string
+		for _, symbol := range p.Body {
+			name := yaccSymbol(symbol)
+			if name == "" {
+				continue
+			}
+			if symbol.Terminal && name[0] != '\'' && !slices.Contains(named, name) {
+				named = append(named, name)
+			}
+			body = append(body, name)
+		}
+		bodies[p.Head] = append(bodies[p.Head], body)
+	}
+
+	order := func(head string) (int, int) {
+		rule, helper, _ := strings.Cut(head, ".")
+		n, _ := strconv.Atoi(helper)
+		return slices.IndexFunc(g.Rules, func(r grammar.Rule) bool { return r.Name == rule }), n
+	}
+	slices.SortStableFunc(heads, func(a, b string) int {
+		ruleA, helperA := order(a)
+		ruleB, helperB := order(b)
+		return cmp.Or(cmp.Compare(ruleA, ruleB), cmp.Compare(helperA, helperB))
+	})
+
+	for _, name := range named {
+		fmt.Fprintf(&sb, "%%token %s\n", name)
+	}
+	fmt.Fprintf(&sb, "\n%%start %s\n\n%%%%\n", g.Rules[0].Name)
+	for _, head := range heads {
+		fmt.Fprintf(&sb, "\n%s\n", head)
+		for i, body := range bodies[head] {
+			separator := "    |"
+			if i == 0 {
+				separator = "    :"
+			}
+			if len(body) == 0 {
+				body = []string{"/* empty */"}
+			}
+			fmt.Fprintf(&sb, "%s %s\n", separator, strings.Join(body, " "))
+		}
+		sb.WriteString("    ;\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// yaccSymbol returns how a symbol is written in yacc, "" for the end of file
+func yaccSymbol(s Symbol) string {
+	switch {
+	case !s.Terminal:
+		return s.Name
+	case s.Token == token.END_OF_FILE:
+		return ""
+	case s.Token.IsKeyword():
+		return "KW_" + strings.ToUpper(s.Token.String())
+	case yaccNames[s.Token] != "":
+		return yaccNames[s.Token]
+	}
+	return "'" + s.Token.String() + "'"
+}
@@ -1,36 +1,342 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"compiler/config"
-	"compiler/lexer"
-	"compiler/parser"
+	"compiler/diag"
+	"compiler/emit"
+	"compiler/grammar"
+	"compiler/lr"
+	"compiler/pipeline"
+	"compiler/sourcefile"
 )
 
 func main() {
-	config.Init()
-	// Initialize and run the lexer
-	lex := lexer.New()
-	lexerSuccess := lex.Tokenize()
-
-	if !lexerSuccess {
-		fmt.Fprintln(os.Stderr,
-			"Compilation aborted due to lexer error. A complete log of this run can be found in: output.err")
-		os.Exit(1)
-	}
-
-	// Initialize and run the parser
-	pars := parser.New()
-	parserSuccess := pars.Parse()
-
-	if !parserSuccess {
-		pars.ListErrors()
-		fmt.Fprintln(os.Stderr,
-			"Compilation aborted due to parser error. A complete log of this run can be found in: output.err")
-		os.Exit(1)
-	} else {
+	if playground != nil {
+		playground()
+		return
+	}
+	os.Exit(run())
+}
+
+// playground, set in a build for the browser, answers the calls of a web
+// page instead of compiling input/test.pas, see wasm.go
+var playground func()
+
+// run compiles the source and returns the exit status
+func run() (status int) {
+	if err := config.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	if err := diag.SetLanguage(diag.Language(config.Lang)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	if err := diag.EnableWarnings(config.Warnings...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	diag.SetWarningsAsErrors(config.WarningsAsErrors)
+	setupLogging()
+
+	if flag.Arg(0) == "explain" {
+		return explain(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "grammar" {
+		return printGrammar(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "outline" {
+		return outline(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "defs" {
+		return defs(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "refs" {
+		return refs(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "rename" {
+		return rename(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "lint" {
+		return lintFile(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "obfuscate" {
+		return obfuscate(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "minify" {
+		return minify(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "astdiff" {
+		return astdiff(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "similarity" {
+		return similarity(flag.Args()[1:])
+	}
+	if flag.Arg(0) == "serve" {
+		return serve(flag.Args()[1:])
+	}
+
+	sink, err := diag.NewSink(config.Format, config.SOURCE_PATH, colorEnabled())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	reporter := diag.NewReporter(config.MaxErrors)
+	defer func() {
+		if r := recover(); r != nil {
+			status = reportInternalError(diag.NewInternalError("", diag.Pos{}, "", r))
+		}
+	}()
+
+	started := time.Now()
+	emit.SetCRLF(config.CRLF)
+	if config.InputHash {
+		file, err := sourcefile.Open(config.SOURCE_PATH)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_IO
+		}
+		emit.SetInputHash(fmt.Sprintf("%x", sha256.Sum256(file.Bytes())))
+		file.Close()
+	}
+
+	// With --cache, an unchanged input restores the artifacts and the
+	// diagnostics of its last compilation instead of compiling again
+	var key string
+	if config.Cache {
+		if key, err = cacheKey(); err != nil {
+			slog.Warn("cache disabled", "error", err)
+		} else if entry, ok := loadCache(key); ok {
+			status = finish(sink, entry.Diagnostics, entry.Truncated, false)
+			slog.Info("compilation restored from cache", "status", status, "errors", entry.Errors, "elapsed", time.Since(started))
+			return status
+		}
+	}
+
+	// Ctrl-C stops the lexer or the parser at the next token, so that the
+	// compilation ends with a message rather than being killed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ioFailed := false
+	compilation := &pipeline.Compilation{Reporter: reporter}
+	if err := pipeline.Run(ctx, compilation); err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, abortMessages[EXIT_CANCELED])
+			return EXIT_CANCELED
+		}
+		if compilation.Lexer == nil {
+			// Without the source there is nothing to report on
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_IO
+		}
+		reporter.StartPhase(diag.OutputPhase)
+		reporter.Report(diag.NewFatal(err))
+		ioFailed = true
+	}
+
+	diagnostics, truncated := reporter.Diagnostics()
+	if err := writeReports(diagnostics, compilation.Parser); err != nil {
+		// The reports are incomplete, so the failure can only be shown on the console
+		diagnostics = append(diagnostics, diag.NewFatal(err))
+		ioFailed = true
+	}
+	status = finish(sink, diagnostics, truncated, ioFailed)
+	slog.Info("compilation finished", "status", status, "errors", reporter.ErrorCount(), "elapsed", time.Since(started))
+
+	// Only a verdict on the program is worth reusing, not a failure to write it
+	if key != "" && status != EXIT_IO {
+		entry := cacheEntry{Artifacts: emit.Written(), Diagnostics: diagnostics, Truncated: truncated, Errors: reporter.ErrorCount()}
+		if err := saveCache(key, compilation.Lexer.Included(), entry); err != nil {
+			slog.Warn("compilation not cached", "error", err)
+		}
+	}
+	return status
+}
+
+// finish prints the diagnostics and the verdict of a compilation and
+// returns its exit status
+func finish(sink diag.Sink, diagnostics []diag.Diagnostic, truncated, ioFailed bool) int {
+	sink.Write(os.Stdout, diagnostics)
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Too many errors, stopped after %d.\n", config.MaxErrors)
+	}
+
+	status := exitStatus(diagnostics, ioFailed)
+	if status != EXIT_SUCCESS {
+		fmt.Fprintln(os.Stderr, abortMessages[status])
+		return status
+	}
+	if config.Format == "text" && !config.Quiet {
 		fmt.Println("Compilation successful.")
 	}
+	return EXIT_SUCCESS
+}
+
+// setupLogging sends progress messages to stderr when --verbose is given
+func setupLogging() {
+	level := slog.LevelWarn
+	if config.Verbose {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{} // the elapsed times are more useful than timestamps
+			}
+			return a
+		},
+	})))
+}
+
+// Exit statuses, so that scripts can tell a bad program from a failing compiler
+const (
+	EXIT_SUCCESS  = 0
+	EXIT_LEXER    = 1   // the source contains lexical errors
+	EXIT_PARSER   = 2   // the source contains syntax errors
+	EXIT_SEMANTIC = 3   // the source contains semantic errors, or warnings under -Werror
+	EXIT_INTERNAL = 4   // the compiler itself failed
+	EXIT_IO       = 5   // a file could not be read or written, or the command line is invalid
+	EXIT_CANCELED = 130 // the compilation was interrupted, like a shell reports SIGINT
+)
+
+var abortMessages = map[int]string{
+	EXIT_LEXER:    "Compilation aborted due to lexer error. A complete log of this run can be found in: output.err",
+	EXIT_PARSER:   "Compilation aborted due to parser error. A complete log of this run can be found in: output.err",
+	EXIT_SEMANTIC: "Compilation aborted due to semantic error. A complete log of this run can be found in: output.err",
+	EXIT_INTERNAL: "Compilation aborted due to internal compiler error.",
+	EXIT_IO:       "Compilation aborted due to I/O error.",
+	EXIT_CANCELED: "Compilation canceled.",
+}
+
+// exitStatus picks the exit status for the reported errors. A failing
+// compiler takes precedence over a bad program, whose status is that of the
+// earliest phase with errors.
+func exitStatus(diagnostics []diag.Diagnostic, ioFailed bool) int {
+	if ioFailed {
+		return EXIT_IO
+	}
+	found := map[int]bool{}
+	for _, d := range diagnostics {
+		if d.Severity != diag.Error {
+			continue
+		}
+		switch {
+		case d.Code == "":
+			found[EXIT_INTERNAL] = true
+		case d.Phase == diag.LexerPhase:
+			found[EXIT_LEXER] = true
+		case strings.HasPrefix(string(d.Code), "P"):
+			found[EXIT_PARSER] = true
+		default:
+			found[EXIT_SEMANTIC] = true
+		}
+	}
+	for _, status := range []int{EXIT_INTERNAL, EXIT_LEXER, EXIT_PARSER, EXIT_SEMANTIC} {
+		if found[status] {
+			return status
+		}
+	}
+	return EXIT_SUCCESS
+}
+
+// colorEnabled reports whether stdout is a terminal that should get colored
+// diagnostics; setting NO_COLOR turns colors off
+func colorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// explain prints the long description of each given diagnostic code,
+// or lists all codes when none is given
+func explain(codes []string) int {
+	if len(codes) == 0 {
+		for _, code := range diag.Codes() {
+			fmt.Printf("%s  %s\n", code, code.Title())
+		}
+		return 0
+	}
+
+	status := 0
+	for _, code := range codes {
+		text, err := diag.Explain(code)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = EXIT_IO
+			continue
+		}
+		fmt.Println(text)
+	}
+	return status
+}
+
+// printGrammar prints the grammar of the selected dialect as EBNF, or in the
+// notation of a parser generator with --format, and with --railroad writes a
+// railroad diagram of each rule
+func printGrammar(args []string) int {
+	flags := flag.NewFlagSet("grammar", flag.ContinueOnError)
+	format := flags.String("format", "ebnf", "notation of the grammar: ebnf, tree-sitter, antlr or yacc")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: grammar [--format ebnf|tree-sitter|antlr|yacc]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return EXIT_IO
+	}
+	writers := map[string]func(*grammar.Grammar, io.Writer) error{
+		"ebnf":        (*grammar.Grammar).WriteEBNF,
+		"tree-sitter": (*grammar.Grammar).WriteTreeSitter,
+		"antlr":       (*grammar.Grammar).WriteANTLR,
+		"yacc": func(g *grammar.Grammar, w io.Writer) error {
+			return lr.WriteYacc(w, g)
+		},
+	}
+	write, ok := writers[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown grammar format '%s', expected ebnf, tree-sitter, antlr or yacc\n", *format)
+		return EXIT_IO
+	}
+
+	g := grammar.Active()
+	if err := write(g, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	if config.Railroad == "" {
+		return 0
+	}
+
+	if err := os.MkdirAll(config.Railroad, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	for _, rule := range g.Rules {
+		err := emit.File(filepath.Join(config.Railroad, rule.Name+".svg"), func(w io.Writer) error {
+			return grammar.WriteRailroad(w, rule)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_IO
+		}
+	}
+	return 0
 }
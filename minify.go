@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"compiler/config"
+	"compiler/diag"
+	"compiler/lexer"
+	"compiler/token"
+)
+
+// minify prints a program on a single line with a space only between
+// tokens that would otherwise run together. It checks the result by
+// scanning it again, which also exercises the columns of a very long line.
+func minify(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: minify [file.pas]")
+		return EXIT_IO
+	}
+	text, err := minifyFile(sourceArg(args))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	fmt.Println(text)
+	return EXIT_SUCCESS
+}
+
+func minifyFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	tokens, err := scan(file, path)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	// The tokens of an included file are written as the directive that
+	// includes it
+	var sb strings.Builder
+	last := ""
+	inSource := true
+	for _, tok := range tokens {
+		value := tok.Value
+		switch file, _, ok := token.ParseSourceMarker(tok); {
+		case ok:
+			entering := inSource
+			inSource = file == path
+			if !entering {
+				continue
+			}
+			name, err := filepath.Rel(filepath.Dir(path), file)
+			if err != nil {
+				return "", err
+			}
+			value = fmt.Sprintf("{$include '%s'}", filepath.ToSlash(name))
+		case !inSource, tok.Type == token.END_OF_LINE, tok.Type == token.END_OF_FILE:
+			continue
+		}
+		if runTogether(last, value) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(value)
+		last = value
+	}
+	minified := sb.String()
+
+	again, err := scan(strings.NewReader(minified), path)
+	if err != nil {
+		return "", err
+	}
+	if !slices.EqualFunc(significant(tokens), significant(again), func(a, b token.Token) bool {
+		return a.Type == b.Type && a.Value == b.Value
+	}) {
+		return "", fmt.Errorf("cannot minify %s: the minified program scans to other tokens", path)
+	}
+	return minified, nil
+}
+
+// scan returns the tokens of r, the text of the file at path, with those of
+// the files it includes between markers, and fails on a lexical error
+func scan(r io.Reader, path string) ([]token.Token, error) {
+	reporter := diag.NewReporter(config.MaxErrors)
+	l := lexer.NewFromReaderAt(r, path, 1, reporter)
+	tokens := slices.Collect(l.Tokens())
+	if l.ErrorCount() > 0 {
+		diagnostics, _ := reporter.Diagnostics()
+		return nil, fmt.Errorf("cannot minify %s while it has lexical errors:\n%s", path, diagnostics[0].Error())
+	}
+	return tokens, nil
+}
+
+// significant returns the tokens without line breaks and the lines of the
+// markers, which minifying changes
+func significant(tokens []token.Token) []token.Token {
+	var kept []token.Token
+	for _, tok := range tokens {
+		if file, _, ok := token.ParseSourceMarker(tok); ok {
+			tok.Value = file
+		}
+		if tok.Type != token.END_OF_LINE {
+			kept = append(kept, tok)
+		}
+	}
+	return kept
+}
+
+// runTogether reports whether two tokens written without a space between
+// them would be scanned differently: two names or numbers, two strings, or
+// the parts of <=, <>, >= and :=
+func runTogether(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	x, _ := utf8.DecodeLastRuneInString(a)
+	y, _ := utf8.DecodeRuneInString(b)
+	word := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	switch {
+	case word(x) && word(y), x == '\'' && y == '\'':
+		return true
+	case x == '<':
+		return y == '=' || y == '>'
+	case x == '>' || x == ':':
+		return y == '='
+	}
+	return false
+}
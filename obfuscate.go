@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"compiler/diag"
+	"compiler/lexer"
+	"compiler/parser"
+	"compiler/sourcefile"
+)
+
+// obfuscate prints a program with every variable, parameter and procedure
+// renamed to a short opaque name, in the order they are declared. The
+// language has no comments to strip, so the rest of the source is kept as
+// it is. Like rename, it checks the result by parsing it again.
+func obfuscate(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: obfuscate [file.pas]")
+		return EXIT_IO
+	}
+	text, err := obfuscateFile(sourceArg(args))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	fmt.Print(text)
+	return EXIT_SUCCESS
+}
+
+func obfuscateFile(path string) (string, error) {
+	source, err := sourcefile.Text(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	raw, bom := strings.CutPrefix(string(data), "\uFEFF")
+	doc := parser.NewDocumentAt(path, source)
+	// An undeclared name could end up referring to a renamed symbol
+	for _, d := range doc.Diagnostics() {
+		if d.Severity == diag.Error {
+			return "", fmt.Errorf("cannot obfuscate %s while it has errors:\n%s", path, d.Error())
+		}
+	}
+
+	pars := doc.Parser()
+	names := obfuscatedNames(pars)
+	obfuscated := replaceNames(raw, names)
+
+	check := parser.NewDocumentAt(path, obfuscated)
+	if u, ok := changedUse(pars.Uses(), check.Parser().Uses()); ok {
+		return "", fmt.Errorf("cannot obfuscate %s: the name at %s would no longer refer to the same symbol",
+			path, position(path, u.Line, u.Column))
+	}
+	if !sameDiagnostics(doc, check) {
+		return "", fmt.Errorf("cannot obfuscate %s: the obfuscated program has other diagnostics", path)
+	}
+	if bom {
+		obfuscated = "\uFEFF" + obfuscated
+	}
+	return obfuscated, nil
+}
+
+// obfuscatedNames returns the new name of each use in the compiled source.
+// Symbols used in an included file keep their names, as only the source
+// itself is rewritten.
+func obfuscatedNames(pars *parser.Parser) map[parser.Use]string {
+	kept := make(map[string]bool)
+	var symbols [][]parser.Use
+	seen := make(map[parser.Use]bool)
+	for _, u := range pars.Uses() {
+		if seen[u] {
+			continue
+		}
+		refs := pars.References(u)
+		included := false
+		for _, r := range refs {
+			seen[r] = true
+			included = included || r.File != ""
+		}
+		if included {
+			kept[u.Name] = true
+		} else {
+			symbols = append(symbols, refs)
+		}
+	}
+
+	names := make(map[parser.Use]string)
+	n := 0
+	for _, refs := range symbols {
+		name := shortName(n)
+		for n++; lexer.IsKeyword(name) || kept[name]; n++ {
+			name = shortName(n)
+		}
+		for _, r := range refs {
+			names[r] = name
+		}
+	}
+	return names
+}
+
+// shortName returns the n-th of a, b, ..., z, aa, ab, ...
+func shortName(n int) string {
+	name := ""
+	for n++; n > 0; n = (n - 1) / 26 {
+		name = string(rune('a'+(n-1)%26)) + name
+	}
+	return name
+}
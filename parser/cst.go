@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"compiler/arena"
+	"compiler/token"
+)
+
+// Node is a node of the concrete syntax tree. An inner node is named after
+// the rule of the grammar it was parsed by and holds the subtrees and tokens
+// of that rule in source order; a leaf holds a token.
+type Node struct {
+	Rule     string // rule of an inner node, empty for a leaf
+	Children []*Node
+	Token    token.Token // token of a leaf
+	File     string      // included file of a leaf, empty for the compiled source
+	Line     int         // line of a leaf
+}
+
+// IsLeaf returns true if the node holds a token
+func (n *Node) IsLeaf() bool {
+	return n.Rule == ""
+}
+
+// treeArena stores the nodes of concrete syntax trees
+type treeArena struct {
+	nodes    arena.Slab[Node]
+	children arena.Slices[*Node]
+}
+
+// reset makes the storage available for another tree
+func (a *treeArena) reset() {
+	a.nodes.Reset()
+	a.children.Reset()
+}
+
+// Tree returns the concrete syntax tree built by Parse, or nil if the
+// program has syntax errors, which leave the tree incomplete
+func (p *Parser) Tree() *Node {
+	if p.syntaxErrors > 0 || p.aborted {
+		return nil
+	}
+	return p.tree
+}
+
+// HasSemanticErrors reports whether the program has errors that leave its
+// tree complete, such as a name that is not declared
+func (p *Parser) HasSemanticErrors() bool {
+	return p.errors > p.syntaxErrors
+}
+
+// openNode is a node being parsed, whose children are collected in
+// Parser.pending from start on until it is closed
+type openNode struct {
+	node  *Node
+	start int
+}
+
+// enter opens a node for rule below the current one and returns its depth,
+// which leave takes to close it, meant to be deferred as
+// defer p.leave(p.enter(rule))
+func (p *Parser) enter(rule string) int {
+	node := p.arena.nodes.New(Node{Rule: rule})
+	if len(p.open) == 0 {
+		p.tree = node
+	} else {
+		p.pending = append(p.pending, node)
+	}
+	p.open = append(p.open, openNode{node, len(p.pending)})
+	return len(p.open) - 1
+}
+
+// leave closes the open nodes from depth on, innermost first, giving each
+// the children collected for it
+func (p *Parser) leave(depth int) {
+	for len(p.open) > depth {
+		open := p.open[len(p.open)-1]
+		open.node.Children = p.arena.children.Copy(p.pending[open.start:])
+		p.pending = p.pending[:open.start]
+		p.open = p.open[:len(p.open)-1]
+	}
+}
+
+// addLeaf adds a consumed token to the current node
+func (p *Parser) addLeaf(tok token.Token) {
+	if len(p.open) == 0 {
+		return
+	}
+	p.pending = append(p.pending, p.arena.nodes.New(Node{Token: tok, File: p.file, Line: p.line}))
+}
+
+// precedenceRules names the rule of the grammar that parses the operands
+// joined by the binary operators of each precedence
+var precedenceRules = []string{1: "expression", 2: "term"}
+
+// enterExpression opens an expression node, which is closed by
+// leaveExpression. Precedence climbing adds the factors and operators to it
+// one after another, so closing the node regroups them into the terms of the
+// grammar.
+func (p *Parser) enterExpression() int {
+	return p.enter("expression")
+}
+
+func (p *Parser) leaveExpression(depth int) {
+	p.leave(depth + 1)
+	open := p.open[depth]
+	items, prefix := p.pending[open.start:], 0
+	if len(items) > 0 && items[0].IsLeaf() {
+		if _, ok := prefixPrecedence()[items[0].Token.Type]; ok {
+			prefix = 1
+		}
+	}
+	children := p.groupOperands(items[prefix:], 1)
+	if prefix > 0 {
+		children = append(items[:1:1], children...) // the prefix operator stays in front
+	}
+	open.node.Children = p.arena.children.Copy(children)
+	p.pending = p.pending[:open.start]
+	p.open = p.open[:depth]
+}
+
+// groupOperands splits items at the operators of precedence and wraps each
+// operand in a node of the rule for the next precedence, if there is one
+func (p *Parser) groupOperands(items []*Node, precedence int) []*Node {
+	if precedence >= len(precedenceRules) {
+		return items // a single factor
+	}
+
+	var grouped []*Node
+	operand := func(part []*Node) {
+		children := p.groupOperands(part, precedence+1)
+		if precedence+1 < len(precedenceRules) {
+			grouped = append(grouped, p.arena.nodes.New(Node{Rule: precedenceRules[precedence+1], Children: p.arena.children.Copy(children)}))
+		} else {
+			grouped = append(grouped, children...)
+		}
+	}
+	start := 0
+	for i, item := range items {
+		if item.IsLeaf() && binaryPrecedence[item.Token.Type] == precedence {
+			operand(items[start:i])
+			grouped = append(grouped, item)
+			start = i + 1
+		}
+	}
+	operand(items[start:])
+	return grouped
+}
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"strings"
+
+	"compiler/diag"
+	"compiler/token"
+)
+
+// SetColumns gives the tokens read from the token file, which has no
+// columns, the ones the lexer scanned them at, indexed like the tokens.
+// Uses then have columns and diagnostics can carry fixes.
+func (p *Parser) SetColumns(columns []int) {
+	p.columns = columns
+}
+
+// column returns the display column of the current token, or 0 if it is
+// unknown
+func (p *Parser) column() int {
+	return p.columnAt(p.cursor.Mark())
+}
+
+// position returns where the current token starts
+func (p *Parser) position() diag.Pos {
+	return diag.Pos{File: p.file, Line: p.line, Column: p.column()}
+}
+
+// insertAfter returns the fix that inserts text right after the last token
+// consumed, or none if its position is unknown
+func (p *Parser) insertAfter(text string) []diag.Fix {
+	if p.end.Column == 0 {
+		return nil
+	}
+	return []diag.Fix{{Range: diag.Range{Start: p.end, End: p.end}, NewText: text}}
+}
+
+// moveDeclaration returns the fixes that move the declaration of a variable
+// found among the executions above them. The cursor is on the name after
+// 'integer', which started at start. Only a declaration on a single line of
+// the file the executions start in is moved, as it is rewritten from its
+// tokens.
+func (p *Parser) moveDeclaration(start diag.Pos) []diag.Fix {
+	if len(p.executions) == 0 || p.line != start.Line || start.Column == 0 {
+		return nil
+	}
+	// The declaration goes after the last one, on a line of its own indented
+	// like the first execution with spaces if that is on another line
+	to := p.executions[len(p.executions)-1]
+	if to.Start.Column == 0 || to.End.Column == 0 || to.Start.File != start.File || to.End.File != start.File {
+		return nil
+	}
+
+	// name [ '[' constant ']' ] ';'
+	types := []token.TokenType{token.IDENTIFIER, token.SEMICOLON}
+	if tok, _ := p.cursor.Peek(); tok.Type == token.LEFT_BRACKET {
+		types = []token.TokenType{token.IDENTIFIER, token.LEFT_BRACKET, token.CONSTANT, token.RIGHT_BRACKET, token.SEMICOLON}
+	}
+	declaration := "integer "
+	for i, want := range types {
+		tok, ok := p.cursor.PeekN(i)
+		if !ok || tok.Type != want {
+			return nil
+		}
+		declaration += tok.Value
+	}
+	semicolon := p.columnAt(p.cursor.Mark() + len(types) - 1)
+	if semicolon == 0 {
+		return nil
+	}
+
+	// A declaration on a line of its own is removed with the line
+	remove := diag.Range{Start: start, End: diag.Pos{File: start.File, Line: start.Line, Column: semicolon + 1}}
+	before := len(p.correctTokens) - 2 // the token before 'integer'
+	next, _ := p.cursor.PeekN(len(types))
+	if (before < 0 || p.correctTokens[before].Type.IsLayout()) && next.Type == token.END_OF_LINE {
+		remove = diag.Range{Start: diag.Pos{File: start.File, Line: start.Line, Column: 1}, End: diag.Pos{File: start.File, Line: start.Line + 1, Column: 1}}
+	}
+	insert := "\n" + strings.Repeat(" ", to.End.Column-1) + declaration
+	if to.Start.Line == to.End.Line {
+		insert = " " + declaration
+	}
+	return []diag.Fix{
+		{Range: diag.Range{Start: to.Start, End: to.Start}, NewText: insert},
+		{Range: remove},
+	}
+}
+
+// columnAt returns the display column of the token at mark, or 0 if it is
+// unknown
+func (p *Parser) columnAt(mark int) int {
+	if tok, ok := p.cursor.PeekN(mark - p.cursor.Mark()); ok && tok.Column > 0 {
+		return tok.Column
+	}
+	if mark < len(p.columns) {
+		return p.columns[mark]
+	}
+	return 0
+}
@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"testing"
+
+	"compiler/diag"
+)
+
+// TestMissingEndFixAfterError checks that the fix inserting the 'end'
+// missing at the end of the file is kept when another error on the last
+// line leaves its diagnostic out
+func TestMissingEndFixAfterError(t *testing.T) {
+	reporter := diag.NewReporter(0)
+	newParser(scanTokens("begin\n  integer k;\n  k := zz\n"), reporter).Parse()
+	diagnostics, _ := reporter.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].Code != diag.S003 {
+		t.Fatalf("diagnostics = %v, want only S003", diagnostics)
+	}
+	want := diag.Fix{Range: diag.Range{Start: diag.Pos{Line: 3, Column: 10}, End: diag.Pos{Line: 3, Column: 10}}, NewText: "\nend"}
+	if fixes := diagnostics[0].Fixes; len(fixes) != 1 || fixes[0] != want {
+		t.Errorf("fixes = %+v, want %+v", fixes, want)
+	}
+}
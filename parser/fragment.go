@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"compiler/ast"
+	"compiler/diag"
+	"compiler/token"
+)
+
+// ParseExpression parses tokens holding a single arithmetic expression, as
+// produced by lexer.NewFromReader, and returns its abstract syntax tree. The
+// tree is nil if the tokens have syntax errors, which are returned.
+func ParseExpression(tokens []token.Token) (ast.Expr, []diag.Diagnostic) {
+	tree, diagnostics := parseFragment(tokens, (*Parser).parseArithmeticExpression)
+	if tree == nil {
+		return nil, diagnostics
+	}
+	return new(lowering).lowerExpression(tree), diagnostics
+}
+
+// ParseStatement parses tokens holding a single statement like
+// ParseExpression does
+func ParseStatement(tokens []token.Token) (ast.Stmt, []diag.Diagnostic) {
+	tree, diagnostics := parseFragment(tokens, (*Parser).parseExecution)
+	if tree == nil {
+		return nil, diagnostics
+	}
+	return new(lowering).lowerExecution(tree), diagnostics
+}
+
+// parseFragment parses tokens with parse, which must consume all of them,
+// and returns the tree of the rule it parses.
+// A fragment has no declarations, so names are told apart by their syntax
+// and only the syntax errors are returned.
+func parseFragment(tokens []token.Token, parse func(p *Parser)) (*Node, []diag.Diagnostic) {
+	p := newParser(tokens, diag.NewReporter(0))
+	p.fragment = true
+	p.callStack = []string{"main"}
+	p.run(func() {
+		parse(p)
+		p.match(token.END_OF_FILE)
+	})
+
+	var syntaxErrors []diag.Diagnostic
+	diagnostics, _ := p.reporter.Diagnostics()
+	for _, d := range diagnostics {
+		if isSyntaxError(d) {
+			syntaxErrors = append(syntaxErrors, d)
+		}
+	}
+	return p.Tree(), syntaxErrors
+}
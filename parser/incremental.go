@@ -0,0 +1,380 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"compiler/ast"
+	"compiler/config"
+	"compiler/diag"
+	"compiler/lexer"
+	"compiler/token"
+)
+
+// Document is a program that stays parsed while its text is edited, as in
+// an editor. An edit scans only the lines it touches again and resumes
+// parsing at the top-level declaration it starts in, since everything before
+// is parsed the same way.
+type Document struct {
+	path     string
+	lines    []sourceLine
+	parser   *Parser
+	reporter *diag.Reporter // diagnostics of parser, which it rewinds to a checkpoint
+	arenas   []*treeArena   // storage of the nodes of the tree, reused by a full parse
+}
+
+// sourceLine is a line of a Document with the tokens scanned from it
+type sourceLine struct {
+	text        string
+	number      int // line the tokens were scanned at
+	tokens      []token.Token
+	diagnostics []diag.Diagnostic
+}
+
+// Edit replaces the text between two positions of a Document, given as
+// 1-based lines and columns counted in characters, with Text. The end is
+// exclusive, so an insertion has the same start and end.
+type Edit struct {
+	StartLine, StartColumn int
+	EndLine, EndColumn     int
+	Text                   string
+}
+
+// checkpoint is the state of the parser before a declaration of the program
+// or its executions, from which parsing can resume with other tokens after
+// the current one
+type checkpoint struct {
+	token                  int // position of the cursor
+	file                   string
+	line                   int
+	end                    diag.Pos
+	callStack              []string
+	currentVariableAddress int
+	shouldAddError         bool
+	lineError, lineFixes   int
+	variables              []Variable
+	procedures             []Procedure
+	assigned, reported     map[int]bool
+	correctTokens          int
+	uses                   int
+	open                   []openNode
+	pending                []*Node
+	errors, syntaxErrors   int
+	diagnostics            int
+}
+
+// NewDocument parses text as the configured source file
+func NewDocument(text string) *Document {
+	return NewDocumentAt(config.SOURCE_PATH, text)
+}
+
+// NewDocumentAt parses text as the file at path, which the files it
+// includes are relative to
+func NewDocumentAt(path, text string) *Document {
+	d, _ := newDocument(context.Background(), path, text)
+	return d
+}
+
+// NewDocumentContext parses text like NewDocument, but gives up once ctx is
+// done and returns the error of ctx
+func NewDocumentContext(ctx context.Context, text string) (*Document, error) {
+	return newDocument(ctx, config.SOURCE_PATH, text)
+}
+
+func newDocument(ctx context.Context, path, text string) (*Document, error) {
+	d := &Document{path: path, reporter: diag.NewReporter(0)}
+	for i, line := range lineBreak.Split(text, -1) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		d.lines = append(d.lines, d.scan(line, i+1))
+	}
+	if err := d.parse(ctx, d.tokens(), -1); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// lineBreak matches the line breaks the lexer accepts
+var lineBreak = regexp.MustCompile(`\r\n|\r|\n`)
+
+// Edit applies e and parses the document again
+func (d *Document) Edit(e Edit) error {
+	if e.StartLine < 1 || e.EndLine > len(d.lines) || e.StartLine > e.EndLine ||
+		(e.StartLine == e.EndLine && e.StartColumn > e.EndColumn) {
+		return fmt.Errorf("invalid edit from %d:%d to %d:%d", e.StartLine, e.StartColumn, e.EndLine, e.EndColumn)
+	}
+	first, last := []rune(d.lines[e.StartLine-1].text), []rune(d.lines[e.EndLine-1].text)
+	if e.StartColumn < 1 || e.StartColumn > len(first)+1 || e.EndColumn < 1 || e.EndColumn > len(last)+1 {
+		return fmt.Errorf("invalid edit from %d:%d to %d:%d", e.StartLine, e.StartColumn, e.EndLine, e.EndColumn)
+	}
+
+	text := string(first[:e.StartColumn-1]) + e.Text + string(last[e.EndColumn-1:])
+	var edited []sourceLine
+	for i, line := range lineBreak.Split(text, -1) {
+		edited = append(edited, d.scan(line, e.StartLine+i))
+	}
+	d.lines = slices.Replace(d.lines, e.StartLine-1, e.EndLine, edited...)
+	for i := e.StartLine - 1 + len(edited); i < len(d.lines); i++ {
+		d.renumber(i)
+	}
+
+	tokens := d.tokens()
+	d.parse(context.Background(), tokens, d.checkpointBefore(tokens, e.StartLine))
+	return nil
+}
+
+// Text returns the text of the document with its lines ended by "\n"
+func (d *Document) Text() string {
+	texts := make([]string, len(d.lines))
+	for i, line := range d.lines {
+		texts[i] = line.text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Parser returns the parser of the current text, which knows its symbols
+func (d *Document) Parser() *Parser {
+	return d.parser
+}
+
+// Tree returns the concrete syntax tree of the current text, or nil if it
+// has syntax errors. The tree is valid until the next edit, which may reuse
+// its memory.
+func (d *Document) Tree() *Node {
+	return d.parser.Tree()
+}
+
+// AST returns the abstract syntax tree of the current text, or nil if it
+// has syntax errors
+func (d *Document) AST() *ast.Program {
+	return d.parser.AST()
+}
+
+// Diagnostics returns the problems found in the current text by the lexer
+// and the parser, ordered by position
+func (d *Document) Diagnostics() []diag.Diagnostic {
+	var diagnostics []diag.Diagnostic
+	for _, line := range d.lines {
+		diagnostics = append(diagnostics, line.diagnostics...)
+	}
+	parsed, _ := d.reporter.Diagnostics()
+	diagnostics = append(diagnostics, parsed...)
+	diag.Sort(diagnostics)
+	return diagnostics
+}
+
+// bytesPerToken estimates the length of a token with the spaces around it,
+// to allocate the tokens of a line at once
+const bytesPerToken = 3
+
+// scan scans the text of the line numbered number
+func (d *Document) scan(text string, number int) sourceLine {
+	reporter := diag.NewReporter(0)
+	line := sourceLine{text: text, number: number, tokens: make([]token.Token, 0, len(text)/bytesPerToken)}
+	for tok := range lexer.NewFromReaderAt(strings.NewReader(text), d.path, number, reporter).Tokens() {
+		if tok.Type != token.END_OF_FILE {
+			line.tokens = append(line.tokens, tok)
+		}
+	}
+	line.diagnostics, _ = reporter.Diagnostics()
+	return line
+}
+
+// renumber updates the i-th line after the lines before it changed. Only
+// the markers of included files hold the line they were scanned at.
+func (d *Document) renumber(i int) {
+	line := &d.lines[i]
+	if line.number == i+1 {
+		return
+	}
+	if slices.ContainsFunc(line.tokens, func(tok token.Token) bool { return tok.Type == token.SOURCE_FILE }) {
+		*line = d.scan(line.text, i+1)
+		return
+	}
+	for j := range line.diagnostics {
+		if line.diagnostics[j].Pos.File == "" {
+			line.diagnostics[j].Pos.Line = i + 1
+		}
+	}
+	line.number = i + 1
+}
+
+// tokens joins the tokens of the lines as the lexer yields them for the
+// whole text, which drops the line breaks after the last token
+func (d *Document) tokens() []token.Token {
+	last := len(d.lines) - 1
+	for last >= 0 && strings.Trim(d.lines[last].text, " \t") == "" {
+		last--
+	}
+	count := len(d.lines)
+	for _, line := range d.lines {
+		count += len(line.tokens)
+	}
+	tokens := make([]token.Token, 0, count)
+	for i, line := range d.lines {
+		if i > 0 && i <= last {
+			tokens = append(tokens, token.Token{Type: token.END_OF_LINE, Value: "EOLN"})
+		}
+		tokens = append(tokens, line.tokens...)
+	}
+	return append(tokens, token.Token{Type: token.END_OF_FILE, Value: "EOF"})
+}
+
+// checkpointBefore returns the index of the last checkpoint of the current
+// parse whose tokens all come before line, or -1 to parse from the start.
+// The diagnostics saved with a checkpoint may name the token after it, such
+// as the one a missing ';' is reported at, so that token has to come before
+// line as well. The tokens before line are the same in the edited text, but
+// the end of file of the previous text may not be.
+func (d *Document) checkpointBefore(tokens []token.Token, line int) int {
+	endOfFile := d.parser.cursor.Mark() + d.parser.cursor.Len() - 1
+	for i := len(d.parser.checkpoints) - 1; i >= 0; i-- {
+		c := d.parser.checkpoints[i]
+		if c.file != "" || c.line >= line || c.token >= endOfFile {
+			continue
+		}
+		lookahead := c.line
+		for j := c.token; j < len(tokens) && tokens[j].Type.IsLayout(); j++ {
+			if tokens[j].Type == token.SOURCE_FILE {
+				lookahead = line // the line of an included file is not in the text
+				break
+			}
+			lookahead++
+		}
+		if lookahead < line {
+			return i
+		}
+	}
+	return -1
+}
+
+// maxArenas bounds the storage kept for the trees of resumed parses, which
+// share nodes with the trees before them. Once it is reached, the document
+// is parsed in full and the storage reused.
+const maxArenas = 8
+
+// parse parses the tokens of the current text, resuming at the given
+// checkpoint of the previous parse unless it is -1, and returns the error
+// of ctx if it is done first
+func (d *Document) parse(ctx context.Context, tokens []token.Token, checkpoint int) error {
+	p := newParser(tokens, d.reporter)
+	p.source = d.path
+	p.incremental = true
+	p.ctx = ctx
+	if checkpoint < 0 || len(d.arenas) == maxArenas {
+		if len(d.arenas) > 0 {
+			p.arena = d.arenas[0]
+			p.arena.reset()
+		}
+		d.arenas = []*treeArena{p.arena}
+		d.reporter.Rewind(0)
+		p.ParseContext(ctx)
+	} else {
+		d.arenas = append(d.arenas, p.arena)
+		p.restore(d.parser, checkpoint)
+		p.run(p.resume)
+	}
+	d.parser = p
+	return p.canceled
+}
+
+// saveCheckpoint saves the state before a declaration of the program or its
+// executions
+func (p *Parser) saveCheckpoint() {
+	c := checkpoint{
+		token:                  p.cursor.Mark(),
+		file:                   p.file,
+		line:                   p.line,
+		end:                    p.end,
+		callStack:              slices.Clone(p.callStack),
+		currentVariableAddress: p.currentVariableAddress,
+		shouldAddError:         p.shouldAddError,
+		lineError:              p.lineError,
+		lineFixes:              p.lineFixes,
+		variables:              cloneVariables(p.variables),
+		procedures:             cloneProcedures(p.procedures),
+		assigned:               maps.Clone(p.assigned),
+		reported:               maps.Clone(p.reported),
+		correctTokens:          len(p.correctTokens),
+		uses:                   len(p.uses),
+		open:                   slices.Clone(p.open),
+		pending:                slices.Clone(p.pending),
+		errors:                 p.errors,
+		syntaxErrors:           p.syntaxErrors,
+		diagnostics:            p.reporter.Len(),
+	}
+	p.checkpoints = append(p.checkpoints, c)
+}
+
+// restore continues the parse of old at its i-th checkpoint. The open nodes
+// are copied, so that the tree of old stays as it was.
+func (p *Parser) restore(old *Parser, i int) {
+	c := old.checkpoints[i]
+	p.cursor.Reset(c.token)
+	p.file, p.line, p.end = c.file, c.line, c.end
+	p.callStack = slices.Clone(c.callStack)
+	p.currentVariableAddress = c.currentVariableAddress
+	p.shouldAddError = c.shouldAddError
+	p.variables = cloneVariables(c.variables)
+	p.procedures = cloneProcedures(c.procedures)
+	p.assigned, p.reported = maps.Clone(c.assigned), maps.Clone(c.reported)
+	p.correctTokens = append(p.correctTokens, old.correctTokens[:c.correctTokens]...)
+	p.uses = append(p.uses, old.uses[:c.uses]...)
+	p.errors, p.syntaxErrors = c.errors, c.syntaxErrors
+	p.reporter.Rewind(c.diagnostics)
+	if c.lineError >= 0 {
+		p.reporter.TrimFixes(c.lineError, c.lineFixes) // it may have got fixes after the checkpoint
+	}
+	p.lineError, p.lineFixes = c.lineError, c.lineFixes
+
+	p.pending = slices.Clone(c.pending)
+	for j, open := range c.open {
+		node := p.arena.nodes.New(Node{Rule: open.node.Rule})
+		if j == 0 {
+			p.tree = node
+		} else {
+			p.pending[open.start-1] = node // entered right after its siblings
+		}
+		p.open = append(p.open, openNode{node, open.start})
+	}
+	// Parsing saves the i-th checkpoint again right away
+	p.checkpoints = slices.Clone(old.checkpoints[:i])
+}
+
+// resume parses the rest of the program from a checkpoint, closing the
+// nodes of the declarations, subprogram and program rules that were open
+func (p *Parser) resume() {
+	p.parseDeclarations_()
+	p.leave(2)
+	p.parseBlockExecutions()
+	p.match(token.END)
+	p.callStack = p.callStack[1:]
+	p.leave(1)
+	p.match(token.END_OF_FILE)
+	p.leave(0)
+
+	p.reportUndefinedForwards()
+	p.reportUnusedSymbols()
+}
+
+func cloneVariables(variables []Variable) []Variable {
+	cloned := slices.Clone(variables)
+	for i := range cloned {
+		cloned[i].References = slices.Clone(cloned[i].References)
+	}
+	return cloned
+}
+
+func cloneProcedures(procedures []Procedure) []Procedure {
+	cloned := slices.Clone(procedures)
+	for i := range cloned {
+		cloned[i].References = slices.Clone(cloned[i].References)
+		cloned[i].Callers = slices.Clone(cloned[i].Callers)
+	}
+	return cloned
+}
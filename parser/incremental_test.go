@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const documentSource = `begin
+  integer k;
+  integer m;
+  integer function F(n);
+  begin
+    integer n;
+    if n <= 0 then F:= 1
+    else F:= n * F(n-1)
+  end;
+  procedure P(var x);
+  begin
+    integer x;
+    x := x - 1;
+    writeln
+  end;
+  integer a[10];
+  read(m);
+  k:= F(m);
+  while k > 0 do P(k);
+  write(k)
+end`
+
+// documentEdits are the texts the random edits insert
+var documentEdits = []string{"", "", ";", "\n", "end", "begin", "integer x;", "k := 1", "(", ")", "x", "procedure Q(y);", " ", "\n  integer z;\n"}
+
+// TestDocumentEditsMatchFullParse applies random edits to a document and
+// checks that each reparse agrees with parsing the edited text from scratch
+func TestDocumentEditsMatchFullParse(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for session := 0; session < 100; session++ {
+		d := NewDocument(documentSource)
+		for i := 0; i < 30; i++ {
+			e := randomEdit(r, d.Text())
+			before := d.Text()
+			if err := d.Edit(e); err != nil {
+				t.Fatalf("edit %+v: %v", e, err)
+			}
+			full := NewDocument(d.Text())
+			if !reflect.DeepEqual(d.Diagnostics(), full.Diagnostics()) {
+				t.Fatalf("edit %+v of\n%s\ngives diagnostics %v, a full parse %v", e, before, d.Diagnostics(), full.Diagnostics())
+			}
+			if !reflect.DeepEqual(d.Tree(), full.Tree()) {
+				t.Fatalf("edit %+v of\n%s\ngives a tree that differs from a full parse", e, before)
+			}
+		}
+	}
+}
+
+// TestDocumentContextCanceled checks that a document is not parsed once its
+// context is done
+func TestDocumentContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if d, err := NewDocumentContext(ctx, documentSource); d != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("NewDocumentContext with a canceled context = %v, %v", d, err)
+	}
+	d, err := NewDocumentContext(context.Background(), documentSource)
+	if err != nil || !reflect.DeepEqual(d.Diagnostics(), NewDocument(documentSource).Diagnostics()) {
+		t.Errorf("NewDocumentContext = %v, %v, want the document NewDocument parses", d, err)
+	}
+}
+
+// randomEdit returns an edit of text replacing up to a few characters at a
+// random position, possibly across a line break, with one of documentEdits.
+// Some edits cut the rest of the text, leaving the program unfinished.
+func randomEdit(r *rand.Rand, text string) Edit {
+	lines := strings.Split(text, "\n")
+	startLine := r.Intn(len(lines)) + 1
+	startColumn := r.Intn(len([]rune(lines[startLine-1]))+1) + 1
+	if r.Intn(20) == 0 {
+		return Edit{startLine, startColumn, len(lines), len([]rune(lines[len(lines)-1])) + 1, ""}
+	}
+	endLine, endColumn := startLine, startColumn
+	for n := r.Intn(6); n > 0; n-- {
+		if endColumn <= len([]rune(lines[endLine-1])) {
+			endColumn++
+		} else if endLine < len(lines) {
+			endLine, endColumn = endLine+1, 1
+		}
+	}
+	return Edit{startLine, startColumn, endLine, endColumn, documentEdits[r.Intn(len(documentEdits))]}
+}
@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"compiler/arena"
+	"compiler/ast"
+	"compiler/token"
+)
+
+// AST returns the abstract syntax tree of the program, or nil if it has
+// syntax errors
+func (p *Parser) AST() *ast.Program {
+	return ToAST(p.Tree())
+}
+
+// LoadAST reads an abstract syntax tree written with --emit=ast-json
+func LoadAST(r io.Reader) (*ast.Program, error) {
+	return ast.ReadJSON(r)
+}
+
+// ToAST lowers a concrete syntax tree to an abstract one, dropping the
+// punctuation and the chains of single rules
+func ToAST(tree *Node) *ast.Program {
+	if tree == nil {
+		return nil
+	}
+	var l lowering
+	return &ast.Program{Pos: tree.pos(), Body: l.lowerBlock(tree.child("subprogram"))}
+}
+
+// lowering builds one abstract syntax tree, taking the most frequent nodes
+// from slabs
+type lowering struct {
+	binaries  arena.Slab[ast.BinaryExpr]
+	constants arena.Slab[ast.IntLit]
+	variables arena.Slab[ast.VarRef]
+}
+
+// pos returns the position of the first token of the node
+func (n *Node) pos() ast.Pos {
+	for n != nil && !n.IsLeaf() {
+		if len(n.Children) == 0 {
+			return ast.Pos{}
+		}
+		n = n.Children[0]
+	}
+	if n == nil {
+		return ast.Pos{}
+	}
+	return ast.Pos{File: n.File, Line: n.Line}
+}
+
+// child returns the first child parsed by rule, or nil
+func (n *Node) child(rule string) *Node {
+	for _, c := range n.Children {
+		if c.Rule == rule {
+			return c
+		}
+	}
+	return nil
+}
+
+// childrenOf returns the children parsed by rule
+func (n *Node) childrenOf(rule string) []*Node {
+	var nodes []*Node
+	for _, c := range n.Children {
+		if c.Rule == rule {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// leaf returns the first token of type t among the children, if any
+func (n *Node) leaf(t token.TokenType) (token.Token, bool) {
+	for _, c := range n.Children {
+		if c.IsLeaf() && c.Token.Type == t {
+			return c.Token, true
+		}
+	}
+	return token.Token{}, false
+}
+
+func (l *lowering) lowerBlock(n *Node) *ast.Block {
+	block := &ast.Block{Pos: n.pos()}
+	for _, d := range n.child("declarations").childrenOf("declaration") {
+		block.Declarations = append(block.Declarations, l.lowerDeclaration(d))
+	}
+	block.Statements = l.lowerExecutions(n.child("executions"))
+	return block
+}
+
+func (l *lowering) lowerDeclaration(n *Node) ast.Decl {
+	if v := n.child("variableDeclaration"); v != nil {
+		name, _ := v.leaf(token.IDENTIFIER)
+		size := 0
+		if c, ok := v.leaf(token.CONSTANT); ok {
+			size, _ = strconv.Atoi(c.Value)
+		}
+		return &ast.VarDecl{Pos: v.pos(), Name: name.Value, Size: size}
+	}
+
+	proc, result := n.child("functionDeclaration"), "integer"
+	if proc == nil {
+		proc, result = n.child("procedureDeclaration"), "void"
+	}
+	name, _ := proc.leaf(token.IDENTIFIER)
+	param := proc.child("parameter")
+	paramName, _ := param.leaf(token.IDENTIFIER)
+	_, byReference := param.leaf(token.VAR)
+	decl := &ast.ProcDecl{Pos: proc.pos(), Name: name.Value, Result: result, Param: paramName.Value, ByReference: byReference}
+	if body := proc.child("procedureBody"); body != nil {
+		decl.Body = l.lowerBlock(body)
+	}
+	return decl
+}
+
+func (l *lowering) lowerExecutions(n *Node) []ast.Stmt {
+	var statements []ast.Stmt
+	for _, e := range n.childrenOf("execution") {
+		statements = append(statements, l.lowerExecution(e))
+	}
+	return statements
+}
+
+func (l *lowering) lowerExecution(n *Node) ast.Stmt {
+	s := n.Children[0]
+	pos := s.pos()
+	switch s.Rule {
+	case "readStatement":
+		read := &ast.ReadStmt{Pos: pos}
+		for _, v := range s.childrenOf("variable") {
+			read.Targets = append(read.Targets, l.lowerVariable(v))
+		}
+		return read
+	case "writeStatement":
+		_, newline := s.leaf(token.WRITELN)
+		write := &ast.WriteStmt{Pos: pos, Newline: newline}
+		for _, c := range s.Children {
+			switch c.Rule {
+			case "writeArgument":
+				write.Args = append(write.Args, l.lowerWriteArgument(c))
+			case "variable": // the course grammar writes variables only
+				write.Args = append(write.Args, l.lowerVariable(c))
+			}
+		}
+		return write
+	case "assignment":
+		return &ast.AssignStmt{Pos: pos, Target: l.lowerVariable(s.child("variable")), Value: l.lowerExpression(s.child("expression"))}
+	case "procedureCall":
+		return &ast.CallStmt{Pos: pos, Call: l.lowerCall(s)}
+	case "ifStatement":
+		branches := s.childrenOf("execution")
+		return &ast.IfStmt{Pos: pos, Cond: l.lowerCondition(s.child("condition")), Then: l.lowerExecution(branches[0]), Else: l.lowerExecution(branches[1])}
+	case "caseStatement":
+		c := &ast.CaseStmt{Pos: pos, Subject: l.lowerExpression(s.child("expression"))}
+		for _, b := range s.childrenOf("caseBranch") {
+			branch := &ast.CaseBranch{Pos: b.pos(), Body: l.lowerExecution(b.child("execution"))}
+			for _, label := range b.Children {
+				if label.IsLeaf() && label.Token.Type == token.CONSTANT {
+					value, _ := strconv.Atoi(label.Token.Value)
+					branch.Labels = append(branch.Labels, value)
+				}
+			}
+			c.Branches = append(c.Branches, branch)
+		}
+		if e := s.child("execution"); e != nil {
+			c.Else = l.lowerExecution(e)
+		}
+		return c
+	case "whileStatement":
+		return &ast.WhileStmt{Pos: pos, Cond: l.lowerCondition(s.child("condition")), Body: l.lowerExecution(s.child("execution"))}
+	case "repeatStatement":
+		return &ast.RepeatStmt{Pos: pos, Body: l.lowerExecutions(s.child("executions")), Cond: l.lowerCondition(s.child("condition"))}
+	case "forStatement":
+		bounds := s.childrenOf("expression")
+		return &ast.ForStmt{Pos: pos, Counter: l.lowerVariable(s.child("variable")), From: l.lowerExpression(bounds[0]), To: l.lowerExpression(bounds[1]),
+			Body: l.lowerExecution(s.child("execution"))}
+	}
+	panic("unknown statement rule " + s.Rule)
+}
+
+func (l *lowering) lowerWriteArgument(n *Node) ast.Expr {
+	if s, ok := n.leaf(token.STRING); ok {
+		value := strings.TrimSuffix(strings.TrimPrefix(s.Value, "'"), "'")
+		return &ast.StringLit{Pos: n.pos(), Value: strings.ReplaceAll(value, "''", "'")}
+	}
+	return l.lowerExpression(n.child("expression"))
+}
+
+func (l *lowering) lowerCondition(n *Node) ast.Expr {
+	operands := n.childrenOf("expression")
+	op := n.child("relationalOperator").Children[0].Token.Type.String()
+	return l.binaries.New(ast.BinaryExpr{Pos: n.pos(), Op: op, Left: l.lowerExpression(operands[0]), Right: l.lowerExpression(operands[1])})
+}
+
+// lowerExpression lowers an expression or a term, whose operands are joined
+// by left associative operators
+func (l *lowering) lowerExpression(n *Node) ast.Expr {
+	children := n.Children
+	var prefix *Node
+	if children[0].IsLeaf() {
+		prefix, children = children[0], children[1:]
+	}
+
+	expr := l.lowerOperand(children[0])
+	if prefix != nil {
+		expr = &ast.UnaryExpr{Pos: prefix.pos(), Op: prefix.Token.Type.String(), Operand: expr}
+	}
+	for i := 1; i+1 < len(children); i += 2 {
+		op := children[i]
+		expr = l.binaries.New(ast.BinaryExpr{Pos: op.pos(), Op: op.Token.Type.String(), Left: expr, Right: l.lowerOperand(children[i+1])})
+	}
+	return expr
+}
+
+func (l *lowering) lowerOperand(n *Node) ast.Expr {
+	if n.Rule != "factor" {
+		return l.lowerExpression(n) // a term
+	}
+	c := n.Children[0]
+	switch {
+	case c.IsLeaf():
+		value, _ := strconv.Atoi(c.Token.Value)
+		return l.constants.New(ast.IntLit{Pos: c.pos(), Value: value})
+	case c.Rule == "variable":
+		return l.lowerVariable(c)
+	}
+	return l.lowerCall(c)
+}
+
+func (l *lowering) lowerVariable(n *Node) *ast.VarRef {
+	name, _ := n.leaf(token.IDENTIFIER)
+	v := l.variables.New(ast.VarRef{Pos: n.pos(), Name: name.Value})
+	if index := n.child("expression"); index != nil {
+		v.Index = l.lowerExpression(index)
+	}
+	return v
+}
+
+func (l *lowering) lowerCall(n *Node) *ast.CallExpr {
+	name, _ := n.leaf(token.IDENTIFIER)
+	return &ast.CallExpr{Pos: n.pos(), Name: name.Value, Arg: l.lowerExpression(n.child("expression"))}
+}
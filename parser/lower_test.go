@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"compiler/ast"
+	"compiler/diag"
+)
+
+// TestASTJSONRoundTrip checks that the tree read back from --emit=ast-json
+// equals the lowered one
+func TestASTJSONRoundTrip(t *testing.T) {
+	sources := []string{
+		documentSource,
+		"begin\n  integer k;\n  writeln;\n  writeln('k', k)\nend\n",
+		"begin\n  integer k;\n  case k of\n    1, 2: write(k);\n    3: k := 0\n  else writeln\n  end\nend\n",
+		"begin\n  integer k;\n  repeat k := k - 1 until k <= 0;\n  for k := 1 to 10 do writeln\nend\n",
+	}
+	for _, source := range sources {
+		reporter := diag.NewReporter(0)
+		p := newParser(scanTokens(source), reporter)
+		if !p.Parse() {
+			diagnostics, _ := reporter.Diagnostics()
+			t.Fatalf("parsing\n%s\nreports %v", source, diagnostics)
+		}
+		prog := p.AST()
+		var buf bytes.Buffer
+		if err := ast.WriteJSON(&buf, prog); err != nil {
+			t.Fatal(err)
+		}
+		read, err := LoadAST(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(read, prog) {
+			t.Errorf("the tree of\n%s\nread back from JSON differs from the lowered one", source)
+		}
+	}
+}
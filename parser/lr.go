@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"context"
+	"strings"
+
+	"compiler/diag"
+	"compiler/lr"
+	"compiler/token"
+)
+
+// ParseLR parses the tokens with the tables of an SLR(1) parser instead of
+// by recursive descent. It only checks the syntax, so the symbol tables stay
+// empty and no semantic diagnostics are reported.
+func (p *Parser) ParseLR(table *lr.Table) bool {
+	ok, _ := p.ParseLRContext(context.Background(), table)
+	return ok
+}
+
+// ParseLRContext parses like ParseLR, but gives up once ctx is done like
+// ParseContext
+func (p *Parser) ParseLRContext(ctx context.Context, table *lr.Table) (ok bool, err error) {
+	p.ctx = ctx
+	p.reporter.StartPhase(diag.ParserPhase)
+	defer func() {
+		r := recover()
+		if r == nil || r == errTooManyErrors {
+			return
+		}
+		if r == errCanceled {
+			ok, err = false, ctx.Err()
+			return
+		}
+		panic(diag.NewInternalError(diag.ParserPhase, diag.Pos{File: p.file, Line: p.line}, p.cursor.Current().Value, r))
+	}()
+
+	p.goToNextLine()
+	stack := []int{0}
+	ended := false // the EOF token has been shifted
+	lastError := -1
+	for {
+		lookahead := p.cursor.Current().Type
+		if ended && p.cursor.AtEnd() {
+			lookahead = lr.EndMarker
+		}
+
+		state := stack[len(stack)-1]
+		action := table.Action[state][lookahead]
+		switch action.Kind {
+		case lr.Shift:
+			ended = ended || lookahead == token.END_OF_FILE
+			p.consumeToken()
+			stack = append(stack, action.Target)
+		case lr.Reduce:
+			production := table.Productions[action.Target]
+			stack = stack[:len(stack)-len(production.Body)]
+			stack = append(stack, table.Goto[stack[len(stack)-1]][production.Head])
+		case lr.Accept:
+			return p.errors == 0, nil
+		default:
+			p.addError(diag.P018, p.lookaheadText(lookahead), expectedText(table.Expected(state)))
+
+			// Skip the offending token if the last recovery made no progress,
+			// then pop the stack back to a state that can continue, skipping
+			// tokens until there is one
+			if p.cursor.Mark() == lastError {
+				if !p.skipToken() {
+					return false, nil
+				}
+			}
+			lastError = p.cursor.Mark()
+			for {
+				if depth := recoveryDepth(table, stack, p.cursor.Current().Type); depth >= 0 {
+					stack = stack[:depth+1]
+					break
+				}
+				if !p.skipToken() {
+					return false, nil
+				}
+			}
+		}
+	}
+}
+
+// recoveryDepth returns the index of the topmost state on the stack that has
+// an action on lookahead, or -1 if there is none
+func recoveryDepth(table *lr.Table, stack []int, lookahead token.TokenType) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if _, ok := table.Action[stack[i]][lookahead]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipToken discards the current token during error recovery and reports
+// whether there was one before the end of the program
+func (p *Parser) skipToken() bool {
+	if p.hasType(token.END_OF_FILE) {
+		return false
+	}
+	p.consumeToken()
+	return true
+}
+
+func (p *Parser) lookaheadText(lookahead token.TokenType) string {
+	if lookahead == lr.EndMarker {
+		return diag.Term("EOF")
+	}
+	return p.cursor.Current().Value
+}
+
+// expectedText lists the expected tokens as in "'begin', 'integer' or identifier"
+func expectedText(expected []token.TokenType) string {
+	names := make([]string, 0, len(expected))
+	for _, t := range expected {
+		if t == lr.EndMarker {
+			names = append(names, diag.Term("EOF"))
+		} else {
+			names = append(names, translateToken(t))
+		}
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " " + diag.Term("or") + " " + names[len(names)-1]
+}
@@ -0,0 +1,91 @@
+package parser
+
+// Symbol is an entry of the outline of a program: the program itself, a
+// procedure or function, or one of their variables and parameters
+type Symbol struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`           // program, procedure, function, parameter, variable or array
+	File     string   `json:"file,omitempty"` // included file, empty for the compiled source
+	Line     int      `json:"line"`           // of the declaration, 0 for the program
+	Column   int      `json:"column"`         // display column of the declared name, 0 if unknown
+	Children []Symbol `json:"children,omitempty"`
+}
+
+// Outline returns the declarations of the program as a tree: the program
+// holds its variables and then its procedures, and each procedure its
+// parameter, variables and nested procedures, each in the order they were
+// declared. The declaration of a parameter in the body is not listed again.
+func (p *Parser) Outline() Symbol {
+	columns := make(map[int]int) // of the declarations of variables
+	procedureColumns := make(map[int]int)
+	for _, u := range p.uses {
+		switch {
+		case !u.Declaration:
+		case u.Variable >= 0:
+			columns[u.Variable] = u.Column
+		default:
+			procedureColumns[u.Procedure] = u.Column
+		}
+	}
+
+	// The variables of a procedure are its slots from the first to the last
+	owner := func(v Variable) int {
+		for i, proc := range p.procedures {
+			if proc.Name == v.Procedure && proc.Level == v.Level &&
+				v.Address >= proc.FirstVariableAddress && v.Address <= proc.LastVariableAddress {
+				return i
+			}
+		}
+		return -1
+	}
+
+	var children func(procedure int, name string, level int) []Symbol
+	children = func(procedure int, name string, level int) []Symbol {
+		var symbols []Symbol
+		for i, v := range p.variables {
+			if v.Procedure != name || v.Level != level || owner(v) != procedure || IsParameterDeclaration(p.variables, v) {
+				continue
+			}
+			symbol := Symbol{Name: v.Name, Kind: "variable", File: v.File, Line: v.Line, Column: columns[i]}
+			switch {
+			case v.Kind != 0:
+				symbol.Name, symbol.Kind = v.Name[1:], "parameter" // without the '_' that sets it apart
+			case v.Size > 0:
+				symbol.Kind = "array"
+			}
+			symbols = append(symbols, symbol)
+		}
+		for i, proc := range p.procedures {
+			if proc.Parent != name || proc.Level != level+1 || !p.encloses(procedure, i) {
+				continue
+			}
+			symbol := Symbol{Name: proc.Name, Kind: "function", File: proc.File, Line: proc.Line, Column: procedureColumns[i]}
+			if proc.Type == "void" {
+				symbol.Kind = "procedure"
+			}
+			symbol.Children = children(i, proc.Name, proc.Level)
+			symbols = append(symbols, symbol)
+		}
+		return symbols
+	}
+	return Symbol{Name: "main", Kind: "program", Children: children(-1, "main", 1)}
+}
+
+// encloses reports whether the j-th procedure is declared inside the one
+// with the given index, or in the program for -1. Procedures are listed in
+// the order they are declared, so the ones nested in a procedure follow it
+// up to the next one at its level or above.
+func (p *Parser) encloses(procedure, j int) bool {
+	if procedure < 0 {
+		return true
+	}
+	if j <= procedure {
+		return false
+	}
+	for i := procedure + 1; i <= j; i++ {
+		if p.procedures[i].Level <= p.procedures[procedure].Level {
+			return false
+		}
+	}
+	return true
+}
@@ -1,11 +1,20 @@
 package parser
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"compiler/config"
+	"compiler/diag"
 	"compiler/pointer"
 	"compiler/token"
 )
@@ -14,11 +23,15 @@ import (
 type Variable struct {
 	Name       string
 	Procedure  string
-	Kind       int // 0 or 1
+	Kind       int // 0 for a variable, 1 for a parameter, 2 for a var parameter
 	Type       string
+	Size       int // number of elements of an array, 0 for a scalar
 	Level      int
 	Address    int
 	IsDeclared bool
+	File       string // included file of the declaration, empty for the compiled source
+	Line       int    // line of the declaration
+	References []int  // lines where the variable is used
 }
 
 // Procedure represents a procedure in the program
@@ -28,89 +41,193 @@ type Procedure struct {
 	Level                int
 	FirstVariableAddress int
 	LastVariableAddress  int
+	Parent               string   // enclosing procedure
+	Forward              bool     // declared forward and not yet defined
+	File                 string   // included file of the declaration, empty for the compiled source
+	Line                 int      // line of the declaration
+	References           []int    // lines of the calls
+	Callers              []string // procedures the calls are made from, "main" for the program
 }
 
 // Parser represents the syntax analyzer
 type Parser struct {
+	source                 string // path of the compiled source
+	file                   string // included file being parsed, empty for the compiled source
 	line                   int
 	callStack              []string
 	currentVariableAddress int
 	shouldAddError         bool
+	lineError              int  // index among the reported diagnostics of the error of the current line, -1 before any
+	lineFixes              int  // fixes it carries
+	fragment               bool // parsing a fragment without declarations, so any name may be used
+
+	assigned map[int]bool // addresses of local variables, and resultSlot, definitely assigned so far
+	reported map[int]bool // addresses already warned about use before assignment
 
 	correctTokens []token.Token
 	variables     []Variable
 	procedures    []Procedure
-	errors        []string
+	uses          []Use
+	consumed      Use          // position of the last name consumed
+	end           diag.Pos     // position right after the last token consumed, column 0 if unknown
+	executions    []diag.Range // from the end of the declarations of each open body to its first execution
+	columns       []int        // columns of the tokens read from the token file, see SetColumns
+	reporter      *diag.Reporter
+	errors        int
 
 	cursor *pointer.Cursor[token.Token]
+
+	tree         *Node
+	open         []openNode // nodes of the rules being parsed, innermost last
+	pending      []*Node    // children of the open nodes, collected until they are closed
+	arena        *treeArena
+	syntaxErrors int
+	aborted      bool // a fatal error stopped Parse
+	ctx          context.Context
+	canceled     error // error of ctx, which stopped Parse
+
+	incremental bool         // save checkpoints to resume parsing at after an edit
+	checkpoints []checkpoint // states before the declarations of the program and its executions
+}
+
+// endOfFile is yielded by the cursor once the token stream is exhausted
+var endOfFile = token.Token{Type: token.END_OF_FILE, Value: "EOF"}
+
+// New creates a new Parser instance reporting problems to reporter, failing
+// if the token file is missing or malformed
+func New(reporter *diag.Reporter) (*Parser, error) {
+	tokens, err := readTokens()
+	if err != nil {
+		return nil, err
+	}
+	return newParser(tokens, reporter), nil
 }
 
-// New creates a new Parser instance
-func New() *Parser {
+func newParser(tokens []token.Token, reporter *diag.Reporter) *Parser {
 	return &Parser{
+		source:                 config.SOURCE_PATH,
 		line:                   1,
 		callStack:              make([]string, 0),
 		currentVariableAddress: -1,
 		shouldAddError:         true,
-		correctTokens:          make([]token.Token, 0),
+		lineError:              -1,
+		correctTokens:          make([]token.Token, 0, len(tokens)), // most programs are accepted whole
 		variables:              make([]Variable, 0),
 		procedures:             make([]Procedure, 0),
-		errors:                 make([]string, 0),
-		cursor:                 pointer.NewCursor(readTokens()),
+		reporter:               reporter,
+		assigned:               make(map[int]bool),
+		reported:               make(map[int]bool),
+		cursor:                 pointer.NewSentinelCursor(tokens, endOfFile),
+		arena:                  new(treeArena),
+		ctx:                    context.Background(),
 	}
 }
 
 // Parse starts the parsing process
 func (p *Parser) Parse() bool {
+	ok, _ := p.ParseContext(context.Background())
+	return ok
+}
+
+// ParseContext parses like Parse, but gives up once ctx is done and returns
+// the error of ctx. The parser then holds the part of the program parsed so
+// far, like after a fatal error.
+func (p *Parser) ParseContext(ctx context.Context) (bool, error) {
+	p.ctx = ctx
+	p.run(func() {
+		p.parseProgram()
+		p.reportUndefinedForwards()
+		p.reportUnusedSymbols()
+	})
+	return p.errors == 0, p.canceled
+}
+
+// run calls parse, recording the error that stops it if one is fatal
+func (p *Parser) run(parse func()) {
+	p.reporter.StartPhase(diag.ParserPhase)
 	defer func() {
-		if r := recover(); r != nil {
-			if err, ok := r.(error); ok {
-				p.errors = append(p.errors, fmt.Sprintf("%v [FATAL]", err))
-			}
+		r := recover()
+		if r == nil {
+			return
+		}
+		p.aborted = true
+		if r == errTooManyErrors {
+			return
+		}
+		if r == errCanceled {
+			p.canceled = p.ctx.Err()
+			return
 		}
-		writeCorrectTokens(p.correctTokens)
-		writeVariables(p.variables)
-		writeProcedures(p.procedures)
-		writeErrors(p.errors)
+		d, ok := r.(diag.Diagnostic)
+		if !ok {
+			panic(diag.NewInternalError(diag.ParserPhase, diag.Pos{File: p.file, Line: p.line}, p.cursor.Current().Value, r))
+		}
+		d.Fatal = true
+		p.record(d)
 	}()
 
-	p.parseProgram()
-	return len(p.errors) == 0
+	parse()
+}
+
+// CorrectTokens returns the tokens accepted by Parse, in order
+func (p *Parser) CorrectTokens() []token.Token {
+	return p.correctTokens
+}
+
+// Variables returns the symbol table of variables and parameters built by Parse
+func (p *Parser) Variables() []Variable {
+	return p.variables
+}
+
+// Procedures returns the symbol table of procedures built by Parse
+func (p *Parser) Procedures() []Procedure {
+	return p.procedures
 }
 
 // Main parsing methods
 func (p *Parser) parseProgram() {
+	defer p.leave(p.enter("program"))
 	p.parseSubprogram()
 	p.match(token.END_OF_FILE)
 }
 
 func (p *Parser) parseSubprogram() {
+	defer p.leave(p.enter("subprogram"))
 	p.callStack = append([]string{"main"}, p.callStack...)
 
 	p.match(token.BEGIN)
 	p.parseDeclarations()
-	p.parseExecutions()
+	p.parseBlockExecutions()
 	p.match(token.END)
 
 	p.callStack = p.callStack[1:]
 }
 
 func (p *Parser) parseDeclarations() {
+	defer p.leave(p.enter("declarations"))
 	p.parseDeclaration()
 	p.parseDeclarations_()
 }
 
 func (p *Parser) parseDeclarations_() {
-	if p.hasType(token.INTEGER) {
+	if p.incremental && len(p.callStack) == 1 {
+		p.saveCheckpoint()
+	}
+	if p.hasType(token.INTEGER) || p.hasType(token.PROCEDURE) || p.isMisspelledKeyword(token.INTEGER) {
 		p.parseDeclaration()
 		p.parseDeclarations_()
 	}
 }
 
 func (p *Parser) parseDeclaration() {
-	p.match(token.INTEGER, "Every program or procedure should have at least one declaration")
-	p.parseDeclaration_()
-	p.match(token.SEMICOLON)
+	defer p.leave(p.enter("declaration"))
+	if p.hasType(token.PROCEDURE) {
+		p.parseProcedureDeclaration()
+	} else {
+		p.match(token.INTEGER, diag.P002)
+		p.parseDeclaration_()
+	}
+	p.matchSemicolon()
 }
 
 func (p *Parser) parseDeclaration_() {
@@ -124,81 +241,249 @@ func (p *Parser) parseDeclaration_() {
 		return
 	}
 
+	if p.cursor.Current().IsKeyword() {
+		p.parseVariableDeclaration()
+		return
+	}
+
 	tok := p.consumeToken()
-	p.throwError(fmt.Sprintf("'%s' is not a valid variable name", tok.Value))
+	p.throwError(diag.P003, tok.Value)
 }
 
 func (p *Parser) parseVariableDeclaration() {
-	tok := p.match(token.IDENTIFIER)
-	p.registerVariable(tok.Value)
+	defer p.leave(p.enter("variableDeclaration"))
+	tok, ok := p.matchDeclaredName()
+	at := p.consumed
+	size := p.parseArraySize(tok.Value)
+	if ok && p.registerVariable(tok.Value, size) {
+		p.useVariable(at, len(p.variables)-1, true)
+	}
 }
 
-func (p *Parser) parseVariable() {
+// parseArraySize parses the optional '[n]' of an array declaration and
+// returns n, or 0 for a scalar
+func (p *Parser) parseArraySize(name string) int {
+	if !p.hasType(token.LEFT_BRACKET) {
+		return 0
+	}
+	p.match(token.LEFT_BRACKET)
+	tok := p.match(token.CONSTANT)
+	p.match(token.RIGHT_BRACKET)
+
+	size, _ := strconv.Atoi(tok.Value)
+	if tok.Type == token.CONSTANT && size <= 0 {
+		p.addError(diag.S014, name)
+	}
+	return max(size, 1)
+}
+
+func (p *Parser) parseVariable() *Variable {
+	defer p.leave(p.enter("variable"))
 	tok := p.match(token.IDENTIFIER)
-	if !p.findVariable(tok.Value) {
-		p.addError(fmt.Sprintf("Undefined variable '%s'", tok.Value))
+	v := p.lookupVariable(tok.Value)
+	if v == nil {
+		p.addError(diag.S003, tok.Value)
+	} else {
+		v.References = append(v.References, p.line)
+		p.useVariable(p.consumed, p.variableIndex(v), false)
 	}
+	p.parseIndex(v)
+	return v
 }
 
+// parseIndex parses the index that follows the name of an array, checking
+// constant indices against its size
+func (p *Parser) parseIndex(v *Variable) {
+	if !p.hasType(token.LEFT_BRACKET) {
+		if v != nil && v.Size > 0 {
+			p.addError(diag.S012, v.Name)
+		}
+		return
+	}
+	if v != nil && v.Size == 0 {
+		p.addError(diag.S011, v.Name)
+	}
+
+	p.match(token.LEFT_BRACKET)
+	if p.hasType(token.CONSTANT) && p.peekType() == token.RIGHT_BRACKET && v != nil && v.Size > 0 {
+		if index, err := strconv.Atoi(p.cursor.Current().Value); err == nil && index >= v.Size {
+			p.addError(diag.S013, index, v.Name, v.Size)
+		}
+	}
+	p.parseArithmeticExpression()
+	p.match(token.RIGHT_BRACKET)
+}
+
+// parseProcedureDeclaration parses a function, or a procedure without a
+// result, which is recorded with the type "void"
 func (p *Parser) parseProcedureDeclaration() {
-	p.match(token.FUNCTION)
-	p.parseProcedureNameDeclaration()
+	resultType := "integer"
+	if p.hasType(token.PROCEDURE) {
+		defer p.leave(p.enter("procedureDeclaration"))
+		p.match(token.PROCEDURE)
+		resultType = "void"
+	} else {
+		defer p.leave(p.enter("functionDeclaration"))
+		p.match(token.FUNCTION)
+	}
+
+	// The definition of a forward declared function reuses its entry
+	tok, ok := p.matchDeclaredName()
+	at := p.consumed
+	forward := p.findForwardDeclaration(tok.Value)
+	var proc *Procedure
+	if forward != nil {
+		forward.Forward = false
+		p.callStack = append([]string{tok.Value}, p.callStack...)
+	} else {
+		proc = p.registerProcedure(tok.Value, resultType)
+	}
+	if ok {
+		p.useProcedure(at, cmp.Or(forward, proc), true)
+	}
+
 	p.match(token.LEFT_PARENTHESES)
-	p.parseParameterDeclaration()
-	p.match(token.RIGHT_PARENTHESES, "Unmatched '('")
-	p.match(token.SEMICOLON)
+	param, byReference, paramAt := p.parseParameterDeclaration()
+	p.match(token.RIGHT_PARENTHESES, diag.P004)
+	if forward != nil {
+		p.checkForwardDeclaration(forward, resultType, param, byReference)
+		i := slices.IndexFunc(p.variables, func(v Variable) bool {
+			return v.Kind != 0 && v.Name == "_"+param && v.Procedure == forward.Name
+		})
+		if paramAt.Name != "" && i >= 0 {
+			p.useVariable(paramAt, i, true)
+		}
+	} else if p.registerParameter(param, byReference) && paramAt.Name != "" {
+		p.useVariable(paramAt, len(p.variables)-1, true)
+	}
+
+	p.matchSemicolon(token.BEGIN, token.FORWARD)
+	if p.hasType(token.FORWARD) {
+		p.match(token.FORWARD)
+		if forward != nil {
+			forward.Forward = true
+			p.addDiagnostic(diag.New(p.line, diag.S006, forward.Name).WithNote("previously declared on line %d", forward.Line))
+		} else if proc != nil {
+			proc.Forward = true
+		}
+		p.callStack = p.callStack[1:]
+		return
+	}
 	p.parseProcedureBody()
 }
 
-func (p *Parser) parseProcedureNameDeclaration() {
-	tok := p.match(token.IDENTIFIER)
-	p.registerProcedure(tok.Value)
+// findForwardDeclaration returns the function of the current block that is
+// declared forward and not yet defined
+func (p *Parser) findForwardDeclaration(name string) *Procedure {
+	for i, proc := range p.procedures {
+		if proc.Name == name && proc.Forward && proc.Level == len(p.callStack)+1 {
+			return &p.procedures[i]
+		}
+	}
+	return nil
 }
 
-func (p *Parser) parseProcedureName() {
-	tok := p.match(token.IDENTIFIER)
-	if !p.findProcedure(tok.Value) {
-		p.addError(fmt.Sprintf("Undefined procedure '%s'", tok.Value))
+// checkForwardDeclaration reports a definition whose kind or parameter
+// differs from the forward declaration of the function
+func (p *Parser) checkForwardDeclaration(forward *Procedure, resultType, param string, byReference bool) {
+	kind := 1
+	if byReference {
+		kind = 2
+	}
+	matches := forward.Type == resultType && slices.ContainsFunc(p.variables, func(v Variable) bool {
+		return v.Procedure == forward.Name && v.Name == "_"+param && v.Kind == kind
+	})
+	if !matches {
+		p.addDiagnostic(diag.New(p.line, diag.S016, forward.Name).WithNote("forward declared on line %d", forward.Line))
 	}
 }
 
-func (p *Parser) parseParameterDeclaration() {
+func (p *Parser) parseProcedureName() *Procedure {
 	tok := p.match(token.IDENTIFIER)
-	p.registerParameter(tok.Value)
+	proc := p.lookupProcedure(tok.Value)
+	if proc == nil {
+		p.addError(diag.S005, tok.Value)
+	} else {
+		p.useProcedure(p.consumed, proc, false)
+	}
+	return proc
+}
+
+// parseParameterDeclaration returns the name of the parameter, whether it
+// is passed by reference and where the name is, which is the zero Use if
+// the name is not a valid identifier
+func (p *Parser) parseParameterDeclaration() (string, bool, Use) {
+	defer p.leave(p.enter("parameter"))
+	byReference := p.hasType(token.VAR)
+	if byReference {
+		p.match(token.VAR)
+	}
+	tok, ok := p.matchDeclaredName()
+	if !ok {
+		return tok.Value, byReference, Use{}
+	}
+	return tok.Value, byReference, p.consumed
 }
 
+// resultSlot stands for the result of the current function in Parser.assigned
+const resultSlot = -1
+
 func (p *Parser) parseProcedureBody() {
+	defer p.leave(p.enter("procedureBody"))
+	outer := p.assigned
+	p.assigned = make(map[int]bool)
+
 	p.match(token.BEGIN)
 	p.parseDeclarations()
-	p.parseExecutions()
+	p.parseBlockExecutions()
+	if proc := p.lookupProcedure(p.callStack[0]); proc != nil && proc.Type != "void" && !p.assigned[resultSlot] {
+		p.addWarning(p.line, diag.W007, proc.Name)
+	}
 	p.match(token.END)
 	p.callStack = p.callStack[1:]
+
+	p.assigned = outer
+}
+
+// parseBlockExecutions parses the executions of the program or a procedure
+// body, keeping where they start to move a declaration found among them to
+func (p *Parser) parseBlockExecutions() {
+	p.executions = append(p.executions, diag.Range{Start: p.end, End: p.position()})
+	p.parseExecutions()
+	p.executions = p.executions[:len(p.executions)-1]
 }
 
 func (p *Parser) parseExecutions() {
+	defer p.leave(p.enter("executions"))
 	p.parseExecution()
 	p.parseExecutions_()
 }
 
 func (p *Parser) parseExecutions_() {
-	if p.hasType(token.SEMICOLON) {
-		p.match(token.SEMICOLON)
+	if p.hasType(token.SEMICOLON) || p.startsExecution() {
+		p.matchSemicolon()
 		p.parseExecution()
 		p.parseExecutions_()
 	}
 }
 
 func (p *Parser) parseExecution() {
+	defer p.leave(p.enter("execution"))
 	if p.hasType(token.READ) {
 		p.parseRead()
 		return
 	}
 
-	if p.hasType(token.WRITE) {
+	if p.hasType(token.WRITE) || p.hasType(token.WRITELN) {
 		p.parseWrite()
 		return
 	}
 
+	if config.DialectAtLeast("ext") && p.atCall() {
+		p.parseProcedureCall()
+		return
+	}
+
 	if p.hasType(token.IDENTIFIER) {
 		p.parseAssignment()
 		return
@@ -209,72 +494,155 @@ func (p *Parser) parseExecution() {
 		return
 	}
 
+	if p.hasType(token.CASE) {
+		p.parseCase()
+		return
+	}
+
+	if p.hasType(token.WHILE) {
+		p.parseWhile()
+		return
+	}
+
+	if p.hasType(token.REPEAT) {
+		p.parseRepeat()
+		return
+	}
+
+	if p.hasType(token.FOR) {
+		p.parseFor()
+		return
+	}
+
 	if p.hasType(token.INTEGER) {
+		start := p.position()
 		p.consumeToken()
-		p.throwError("Please move all declarations to the beginning of the procedure")
-		return
+		panic(diag.New(p.line, diag.P005).WithFixes(p.moveDeclaration(start)...))
 	}
 
 	tok := p.consumeToken()
-	p.throwError(fmt.Sprintf("Execution cannot begin with '%s'", tok.Value))
+	p.throwError(diag.P006, tok.Value)
 }
 
 func (p *Parser) parseRead() {
+	defer p.leave(p.enter("readStatement"))
 	p.match(token.READ)
 	p.match(token.LEFT_PARENTHESES)
-	p.parseVariable()
-	p.match(token.RIGHT_PARENTHESES, "Unmatched '('")
+	p.markAssigned(p.parseVariable())
+	for p.hasType(token.COMMA) {
+		p.match(token.COMMA)
+		p.markAssigned(p.parseVariable())
+	}
+	p.match(token.RIGHT_PARENTHESES, diag.P004)
 }
 
+// parseWrite parses write and writeln; only writeln may omit its arguments
 func (p *Parser) parseWrite() {
-	p.match(token.WRITE)
+	defer p.leave(p.enter("writeStatement"))
+	if tok := p.consumeToken(); tok.Type == token.WRITELN && !p.hasType(token.LEFT_PARENTHESES) {
+		return
+	}
 	p.match(token.LEFT_PARENTHESES)
-	p.parseVariable()
-	p.match(token.RIGHT_PARENTHESES, "Unmatched '('")
+	if !config.DialectAtLeast("std") {
+		// The course grammar only writes variables
+		p.checkAssigned(p.parseVariable())
+		p.match(token.RIGHT_PARENTHESES, diag.P004)
+		return
+	}
+	p.parseWriteArgument()
+	for p.hasType(token.COMMA) {
+		p.match(token.COMMA)
+		p.parseWriteArgument()
+	}
+	p.match(token.RIGHT_PARENTHESES, diag.P004)
+}
+
+// parseWriteArgument parses an expression or a string, which may only be written
+func (p *Parser) parseWriteArgument() {
+	defer p.leave(p.enter("writeArgument"))
+	if p.hasType(token.STRING) {
+		p.match(token.STRING)
+		return
+	}
+	p.parseArithmeticExpression()
 }
 
 func (p *Parser) parseAssignment() {
+	defer p.leave(p.enter("assignment"))
+	var target *Variable
 	current := p.cursor.Current()
-	if p.findVariable(current.Value) {
-		p.parseVariable()
-	} else if p.findProcedure(current.Value) {
-		p.parseProcedureName()
+	if p.findVariable(current.Value) || p.fragment {
+		target = p.parseVariable()
 	} else {
-		tok := p.consumeToken()
-		p.addError(fmt.Sprintf("Undefined variable or procedure '%s'", tok.Value))
+		// The result of a function is assigned like a variable
+		depth := p.enter("variable")
+		if !p.findProcedure(current.Value) {
+			tok := p.consumeToken()
+			p.addError(diag.S007, tok.Value)
+		} else if proc := p.parseProcedureName(); proc != nil && proc.Type == "void" {
+			p.addError(diag.S009, proc.Name)
+		} else if current.Value != p.callStack[0] {
+			p.addError(diag.S010, current.Value)
+		} else {
+			p.assigned[resultSlot] = true
+		}
+		p.leave(depth)
 	}
 
 	p.match(token.ASSIGN)
 	p.parseArithmeticExpression()
+	p.markAssigned(target)
 }
 
-func (p *Parser) parseArithmeticExpression() {
-	p.parseTerm()
-	p.parseArithmeticExpression_()
+// binaryPrecedence gives the binding strength of each binary operator. All
+// of them are left associative, and operators binding tighter are parsed
+// first, so new operators only need an entry here.
+var binaryPrecedence = map[token.TokenType]int{
+	token.SUBTRACT: 1,
+	token.MULTIPLY: 2,
+	token.MOD:      2,
+	token.DIV:      2,
 }
 
-func (p *Parser) parseArithmeticExpression_() {
-	if p.hasType(token.SUBTRACT) {
-		p.match(token.SUBTRACT)
-		p.parseTerm()
-		p.parseArithmeticExpression_()
+// prefixPrecedence gives the binding strength of each enabled prefix
+// operator, which applies to the operand and the tighter operators after it
+func prefixPrecedence() map[token.TokenType]int {
+	if config.UnaryMinus {
+		return map[token.TokenType]int{token.SUBTRACT: 1} // sign of the first term
 	}
+	return nil
 }
 
-func (p *Parser) parseTerm() {
-	p.parseFactor()
-	p.parseTerm_()
+// parseArithmeticExpression parses an expression by precedence climbing
+func (p *Parser) parseArithmeticExpression() {
+	defer p.leaveExpression(p.enterExpression())
+	if precedence, ok := prefixPrecedence()[p.cursor.Current().Type]; ok {
+		p.consumeToken()
+		p.parseFactor()
+		p.parseOperators(precedence + 1)
+	} else {
+		p.parseFactor()
+	}
+	p.parseOperators(1)
 }
 
-func (p *Parser) parseTerm_() {
-	if p.hasType(token.MULTIPLY) {
-		p.match(token.MULTIPLY)
+// parseOperators continues an expression whose first operand has been
+// parsed with the operators binding at least as tight as minPrecedence
+func (p *Parser) parseOperators(minPrecedence int) {
+	for {
+		precedence, ok := binaryPrecedence[p.cursor.Current().Type]
+		if !ok || precedence < minPrecedence {
+			return
+		}
+		p.consumeToken()
 		p.parseFactor()
-		p.parseTerm_()
+		// Tighter operators take the right operand first
+		p.parseOperators(precedence + 1)
 	}
 }
 
 func (p *Parser) parseFactor() {
+	defer p.leave(p.enter("factor"))
 	if p.hasType(token.CONSTANT) {
 		p.match(token.CONSTANT)
 		return
@@ -282,81 +650,236 @@ func (p *Parser) parseFactor() {
 
 	if p.hasType(token.IDENTIFIER) {
 		if p.findVariable(p.cursor.Current().Value) {
-			p.parseVariable()
+			p.checkAssigned(p.parseVariable())
+			return
+		}
+		if p.atCall() || p.findProcedure(p.cursor.Current().Value) {
+			if proc := p.parseProcedureCall(); proc != nil && proc.Type == "void" {
+				p.addError(diag.S009, proc.Name)
+			}
 			return
 		}
-		if p.findProcedure(p.cursor.Current().Value) {
-			p.parseProcedureCall()
+		// An undeclared name that is not called is reported like one in
+		// read, without giving up on the rest of the program
+		if p.peekType() != token.LEFT_PARENTHESES {
+			p.parseVariable()
 			return
 		}
 		tok := p.consumeToken()
-		p.throwError(fmt.Sprintf("Undefined variable or procedure '%s'", tok.Value))
+		p.throwError(diag.S007, tok.Value)
 	}
 
 	tok := p.consumeToken()
-	p.throwError(fmt.Sprintf("Expect variable, procedure or constant, but got '%s'", tok.Value))
+	p.throwError(diag.P007, tok.Value)
 }
 
-func (p *Parser) parseProcedureCall() {
-	p.parseProcedureName()
+// parseProcedureCall parses a call and returns the callee, or nil if it is undeclared
+func (p *Parser) parseProcedureCall() *Procedure {
+	defer p.leave(p.enter("procedureCall"))
+	proc := p.parseProcedureName()
+	if proc != nil {
+		proc.References = append(proc.References, p.line)
+		proc.Callers = append(proc.Callers, p.callStack[0])
+	}
 	p.match(token.LEFT_PARENTHESES)
-	p.parseArithmeticExpression()
-	p.match(token.RIGHT_PARENTHESES, "Unmatched '('")
+	if param := p.findVarParameter(proc); param != nil {
+		p.parseReferenceArgument(proc, param)
+	} else {
+		p.parseArithmeticExpression()
+	}
+	p.match(token.RIGHT_PARENTHESES, diag.P004)
+	return proc
+}
+
+// parseReferenceArgument parses the argument of a var parameter, which must
+// be a variable. The callee may assign it, so it counts as assigned afterwards.
+func (p *Parser) parseReferenceArgument(proc *Procedure, param *Variable) {
+	if !p.hasType(token.IDENTIFIER) || !p.findVariable(p.cursor.Current().Value) {
+		p.addError(diag.S008, strings.TrimPrefix(param.Name, "_"), proc.Name)
+		p.parseArithmeticExpression()
+		return
+	}
+
+	defer p.leaveExpression(p.enterExpression())
+	depth := p.enter("factor")
+	v := p.parseVariable()
+	p.leave(depth)
+	if p.hasType(token.RIGHT_PARENTHESES) {
+		p.markAssigned(v)
+		return
+	}
+	// The variable only starts an expression, whose rest is parsed as usual
+	p.addError(diag.S008, strings.TrimPrefix(param.Name, "_"), proc.Name)
+	p.parseOperators(1)
 }
 
 func (p *Parser) parseCondition() {
+	defer p.leave(p.enter("ifStatement"))
 	p.match(token.IF)
 	p.parseConditionExpression()
 	p.match(token.THEN)
+
+	// A variable is assigned after the if statement only if both branches assign it
+	before := maps.Clone(p.assigned)
 	p.parseExecution()
+	afterThen := p.assigned
+	p.assigned = before
+
 	p.match(token.ELSE)
 	p.parseExecution()
+	maps.DeleteFunc(p.assigned, func(address int, _ bool) bool {
+		return !afterThen[address]
+	})
 }
 
-func (p *Parser) parseConditionExpression() {
-	p.parseArithmeticExpression()
-	p.parseOperator()
+// parseCase parses a case statement. Like the branches of an if statement,
+// a variable is assigned afterwards only if every branch assigns it, and
+// without an else branch possibly none of them runs.
+func (p *Parser) parseCase() {
+	defer p.leave(p.enter("caseStatement"))
+	p.match(token.CASE)
 	p.parseArithmeticExpression()
-}
+	p.match(token.OF)
 
-func (p *Parser) parseOperator() {
-	if p.hasType(token.EQUAL) {
-		p.match(token.EQUAL)
-		return
+	before := p.assigned
+	var after map[int]bool
+	parseBranch := func() {
+		p.assigned = maps.Clone(before)
+		p.parseExecution()
+		if after == nil {
+			after = p.assigned
+		} else {
+			maps.DeleteFunc(after, func(address int, _ bool) bool {
+				return !p.assigned[address]
+			})
+		}
 	}
-	if p.hasType(token.NOT_EQUAL) {
-		p.match(token.NOT_EQUAL)
-		return
+
+	labels := make(map[int]int) // line of each label
+	for {
+		depth := p.enter("caseBranch")
+		p.parseCaseLabels(labels)
+		p.match(token.COLON)
+		parseBranch()
+		p.leave(depth)
+		if !p.hasType(token.SEMICOLON) {
+			break
+		}
+		p.match(token.SEMICOLON)
+		if p.hasType(token.ELSE) || p.hasType(token.END) {
+			break // a semicolon may end the last branch
+		}
 	}
-	if p.hasType(token.LESS_THAN) {
-		p.match(token.LESS_THAN)
-		return
+
+	if p.hasType(token.ELSE) {
+		p.match(token.ELSE)
+		parseBranch()
+	} else {
+		after = before
 	}
-	if p.hasType(token.LESS_THAN_OR_EQUAL) {
-		p.match(token.LESS_THAN_OR_EQUAL)
-		return
+	p.match(token.END)
+	p.assigned = after
+}
+
+// parseCaseLabels parses the comma separated constants of a case branch
+func (p *Parser) parseCaseLabels(labels map[int]int) {
+	for {
+		tok := p.match(token.CONSTANT)
+		if value, err := strconv.Atoi(tok.Value); err == nil && tok.Type == token.CONSTANT {
+			if line, ok := labels[value]; ok {
+				p.addDiagnostic(diag.New(p.line, diag.S015, tok.Value).WithNote("previously used on line %d", line))
+			} else {
+				labels[value] = p.line
+			}
+		}
+		if !p.hasType(token.COMMA) {
+			return
+		}
+		p.match(token.COMMA)
 	}
-	if p.hasType(token.GREATER_THAN) {
-		p.match(token.GREATER_THAN)
-		return
+}
+
+func (p *Parser) parseWhile() {
+	defer p.leave(p.enter("whileStatement"))
+	p.match(token.WHILE)
+	p.parseConditionExpression()
+	p.match(token.DO)
+	p.parseLoopBody()
+}
+
+// parseRepeat parses a repeat loop, whose body runs at least once, so that
+// its assignments still hold after the loop
+func (p *Parser) parseRepeat() {
+	defer p.leave(p.enter("repeatStatement"))
+	p.match(token.REPEAT)
+	p.parseExecutions()
+	p.match(token.UNTIL)
+	p.parseConditionExpression()
+}
+
+func (p *Parser) parseFor() {
+	defer p.leave(p.enter("forStatement"))
+	p.match(token.FOR)
+	counter := p.parseVariable()
+	p.match(token.ASSIGN)
+	p.parseArithmeticExpression()
+	p.markAssigned(counter)
+	p.match(token.TO)
+	p.parseArithmeticExpression()
+	p.match(token.DO)
+	p.parseLoopBody()
+}
+
+// parseLoopBody parses the body of a loop that may run zero times, so that
+// variables it assigns count as unassigned afterwards
+func (p *Parser) parseLoopBody() {
+	before := maps.Clone(p.assigned)
+	p.parseExecution()
+	p.assigned = before
+}
+
+func (p *Parser) parseConditionExpression() {
+	defer p.leave(p.enter("condition"))
+	start := len(p.correctTokens)
+	p.parseArithmeticExpression()
+	p.parseOperator()
+	middle := len(p.correctTokens)
+	p.parseArithmeticExpression()
+
+	// Report a < b < c here instead of as a missing 'then', and skip the
+	// extra comparison so that the rest of the statement parses normally
+	for p.cursor.Current().IsRelational() {
+		left := p.sourceText(start)
+		right := p.sourceText(middle)
+		tok := p.consumeToken()
+		middle = len(p.correctTokens)
+		p.parseArithmeticExpression()
+		p.addDiagnostic(diag.New(p.line, diag.P017, tok.Value).
+			WithNote("Pascal would write '(%s) and (%s)'; nest two if statements instead", left, right+" "+tok.Value+" "+p.sourceText(middle)))
 	}
-	if p.hasType(token.GREATER_THAN_OR_EQUAL) {
-		p.match(token.GREATER_THAN_OR_EQUAL)
+}
+
+func (p *Parser) parseOperator() {
+	defer p.leave(p.enter("relationalOperator"))
+	if p.cursor.Current().IsRelational() {
+		p.consumeToken()
 		return
 	}
 	tok := p.consumeToken()
-	p.addError(fmt.Sprintf("%s is not a valid operator", tok.Value))
+	p.addError(diag.P008, tok.Value)
 }
 
-func (p *Parser) registerVariable(name string) {
+// registerVariable declares a variable, which takes size slots if it is an
+// array, and returns false if no new variable was added
+func (p *Parser) registerVariable(name string, size int) bool {
 	if param := p.findParameter(name); param != nil {
 		param.IsDeclared = true
-		return
+		return false
 	}
 
 	if dup := p.findDuplicateVariable(name); dup != nil {
-		p.addError(fmt.Sprintf("Variable '%s' has already been declared", name))
-		return
+		p.addDiagnostic(diag.New(p.line, diag.S001, name).WithNote("previously declared on line %d", dup.Line))
+		return false
 	}
 
 	p.variables = append(p.variables, Variable{
@@ -364,13 +887,21 @@ func (p *Parser) registerVariable(name string) {
 		Procedure:  p.callStack[0],
 		Kind:       0,
 		Type:       "integer",
+		Size:       size,
 		Level:      len(p.callStack),
 		Address:    p.currentVariableAddress + 1,
 		IsDeclared: true,
+		File:       p.file,
+		Line:       p.line,
 	})
 	p.currentVariableAddress++
-
 	p.updateProcedureVariableAddresses()
+
+	if size > 1 {
+		p.currentVariableAddress += size - 1
+		p.updateProcedureVariableAddresses()
+	}
+	return true
 }
 
 func (p *Parser) findDuplicateVariable(name string) *Variable {
@@ -383,40 +914,157 @@ func (p *Parser) findDuplicateVariable(name string) *Variable {
 }
 
 func (p *Parser) findVariable(name string) bool {
+	return p.lookupVariable(name) != nil
+}
+
+// lookupVariable returns the innermost visible variable with the given name
+func (p *Parser) lookupVariable(name string) *Variable {
+	var found *Variable
+	for i, v := range p.variables {
+		if v.Name == name && p.inScope(v.Procedure, v.Level) && (found == nil || v.Level > found.Level) {
+			found = &p.variables[i]
+		}
+	}
+	if found != nil && !found.IsDeclared {
+		p.addError(diag.S004, name)
+	}
+	return found
+}
+
+// inScope reports whether a symbol declared in procedure at level is visible
+// from the current position, i.e. procedure is on the call stack at that level
+func (p *Parser) inScope(procedure string, level int) bool {
+	depth := len(p.callStack)
+	return level >= 1 && level <= depth && p.callStack[depth-level] == procedure
+}
+
+// markAssigned records that v holds a value on the current path
+func (p *Parser) markAssigned(v *Variable) {
+	if v != nil {
+		p.assigned[v.Address] = true
+	}
+}
+
+// checkAssigned warns if v is a local variable of the current procedure that
+// may be read before any assignment on some path
+func (p *Parser) checkAssigned(v *Variable) {
+	if v == nil || p.assigned[v.Address] || p.reported[v.Address] {
+		return
+	}
+	if v.Procedure != p.callStack[0] || v.Level != len(p.callStack) || p.isParameterDeclaration(*v) {
+		return
+	}
+	p.reported[v.Address] = true
+	p.addWarning(p.line, diag.W005, v.Name)
+}
+
+// reportUndefinedForwards reports forward declarations without a definition
+func (p *Parser) reportUndefinedForwards() {
+	for _, proc := range p.procedures {
+		if proc.Forward {
+			d := diag.New(proc.Line, diag.S017, proc.Name)
+			d.Pos.File = proc.File
+			p.report(d)
+		}
+	}
+}
+
+// reportUnusedSymbols warns about variables, parameters and procedures
+// that are never referenced
+func (p *Parser) reportUnusedSymbols() {
 	for _, v := range p.variables {
-		if v.Name == name && v.Level <= len(p.callStack) {
-			if !v.IsDeclared {
-				p.addError(fmt.Sprintf("Variable '%s' has not been declared", name))
+		if v.Kind != 0 || len(v.References) > 0 {
+			continue
+		}
+		if p.isParameterDeclaration(v) {
+			p.addWarningAt(diag.Pos{File: v.File, Line: v.Line}, diag.W002, v.Name, v.Procedure)
+		} else {
+			p.addWarningAt(diag.Pos{File: v.File, Line: v.Line}, diag.W001, v.Name)
+		}
+	}
+
+	reachable := p.reachableProcedures()
+	for _, proc := range p.procedures {
+		if len(proc.References) == 0 {
+			p.addWarningAt(diag.Pos{File: proc.File, Line: proc.Line}, diag.W004, proc.Name)
+		} else if !reachable[proc.Name] {
+			p.addWarningAt(diag.Pos{File: proc.File, Line: proc.Line}, diag.W013, proc.Name)
+		}
+	}
+}
+
+// reachableProcedures returns the procedures the main program calls,
+// directly or through other procedures, by following the call graph from
+// "main" until no more are found
+func (p *Parser) reachableProcedures() map[string]bool {
+	reachable := map[string]bool{"main": true}
+	for found := true; found; {
+		found = false
+		for _, proc := range p.procedures {
+			if !reachable[proc.Name] && slices.ContainsFunc(proc.Callers, func(caller string) bool { return reachable[caller] }) {
+				reachable[proc.Name] = true
+				found = true
 			}
-			return true
 		}
 	}
-	return false
+	return reachable
+}
+
+// isParameterDeclaration reports whether v is the body declaration of a parameter
+func (p *Parser) isParameterDeclaration(v Variable) bool {
+	return IsParameterDeclaration(p.variables, v)
 }
 
-func (p *Parser) registerParameter(name string) {
+// IsParameterDeclaration reports whether v is the body declaration of a
+// parameter listed in variables
+func IsParameterDeclaration(variables []Variable, v Variable) bool {
+	return ParameterOf(variables, v) != nil
+}
+
+// ParameterOf returns the parameter whose body declaration is v, or nil if v
+// is an ordinary variable
+func ParameterOf(variables []Variable, v Variable) *Variable {
+	for i, param := range variables {
+		if param.Kind != 0 && param.Name == "_"+v.Name && param.Procedure == v.Procedure {
+			return &variables[i]
+		}
+	}
+	return nil
+}
+
+// registerParameter declares the parameter of the current procedure and
+// returns false if the name is taken
+func (p *Parser) registerParameter(name string, byReference bool) bool {
 	if dup := p.findDuplicateParameter(name); dup != nil {
-		p.addError(fmt.Sprintf("Parameter '%s' has already been declared", name))
-		return
+		p.addDiagnostic(diag.New(p.line, diag.S002, name).WithNote("previously declared on line %d", dup.Line))
+		return false
+	}
+
+	kind := 1
+	if byReference {
+		kind = 2
 	}
 
 	p.variables = append(p.variables, Variable{
 		Name:       "_" + name,
 		Procedure:  p.callStack[0],
-		Kind:       1,
+		Kind:       kind,
 		Type:       "integer",
 		Level:      len(p.callStack),
 		Address:    p.currentVariableAddress + 1,
 		IsDeclared: false,
+		File:       p.file,
+		Line:       p.line,
 	})
 	p.currentVariableAddress++
 
 	p.updateProcedureVariableAddresses()
+	return true
 }
 
 func (p *Parser) findDuplicateParameter(name string) *Variable {
 	for _, v := range p.variables {
-		if v.Name == name && v.Kind == 1 && v.Procedure == p.callStack[0] {
+		if v.Name == name && v.Kind != 0 && v.Procedure == p.callStack[0] {
 			return &v
 		}
 	}
@@ -425,27 +1073,49 @@ func (p *Parser) findDuplicateParameter(name string) *Variable {
 
 func (p *Parser) findParameter(name string) *Variable {
 	for _, v := range p.variables {
-		if v.Name == name && v.Kind == 1 && v.Level <= len(p.callStack) {
+		if v.Name == name && v.Kind != 0 && v.Level <= len(p.callStack) {
 			return &v
 		}
 	}
 	return nil
 }
 
-func (p *Parser) registerProcedure(name string) {
-	if dup := p.findDuplicateProcedure(name); dup != nil {
-		p.addError(fmt.Sprintf("Procedure '%s' has already been declared", name))
-		return
+// findVarParameter returns the parameter of proc if it is passed by reference
+func (p *Parser) findVarParameter(proc *Procedure) *Variable {
+	if proc == nil {
+		return nil
+	}
+	for i, v := range p.variables {
+		if v.Kind == 2 && v.Procedure == proc.Name {
+			return &p.variables[i]
+		}
 	}
+	return nil
+}
 
-	p.procedures = append(p.procedures, Procedure{
-		Name:                 name,
-		Type:                 "integer",
-		Level:                len(p.callStack) + 1,
-		FirstVariableAddress: -1,
-		LastVariableAddress:  -1,
-	})
+// registerProcedure declares a procedure and enters its scope, returning it
+// or nil if the name is already taken
+func (p *Parser) registerProcedure(name string, resultType string) *Procedure {
+	// The body is still parsed in the procedure's scope so the call stack
+	// stays balanced when the name is rejected
+	var proc *Procedure
+	if dup := p.findDuplicateProcedure(name); dup != nil {
+		p.addDiagnostic(diag.New(p.line, diag.S006, name).WithNote("previously declared on line %d", dup.Line))
+	} else {
+		p.procedures = append(p.procedures, Procedure{
+			Name:                 name,
+			Type:                 resultType,
+			Level:                len(p.callStack) + 1,
+			FirstVariableAddress: -1,
+			LastVariableAddress:  -1,
+			Parent:               p.callStack[0],
+			File:                 p.file,
+			Line:                 p.line,
+		})
+		proc = &p.procedures[len(p.procedures)-1]
+	}
 	p.callStack = append([]string{name}, p.callStack...)
+	return proc
 }
 
 func (p *Parser) findDuplicateProcedure(name string) *Procedure {
@@ -458,12 +1128,16 @@ func (p *Parser) findDuplicateProcedure(name string) *Procedure {
 }
 
 func (p *Parser) findProcedure(name string) bool {
-	for _, proc := range p.procedures {
+	return p.lookupProcedure(name) != nil
+}
+
+func (p *Parser) lookupProcedure(name string) *Procedure {
+	for i, proc := range p.procedures {
 		if proc.Name == name && proc.Level <= len(p.callStack)+1 {
-			return true
+			return &p.procedures[i]
 		}
 	}
-	return false
+	return nil
 }
 
 func (p *Parser) updateProcedureVariableAddresses() {
@@ -483,140 +1157,335 @@ func (p *Parser) hasType(expectation token.TokenType) bool {
 	return expectation == p.cursor.Current().Type
 }
 
-func (p *Parser) match(expectation token.TokenType, message ...string) token.Token {
+// match consumes the expected token, reporting an error if it is missing.
+// A custom diagnostic code without arguments may replace the generic one.
+func (p *Parser) match(expectation token.TokenType, custom ...diag.Code) token.Token {
 	if !p.hasType(expectation) {
-		msg := fmt.Sprintf("Expect %s, but got '%s'",
-			translateToken(expectation),
-			p.cursor.Current().Value)
-		if len(message) > 0 {
-			msg = message[0]
+		if p.isMisspelledKeyword(expectation) {
+			// Recover by treating the identifier as the keyword
+			p.addError(diag.P015, p.cursor.Current().Value, translateToken(expectation))
+		} else if len(custom) > 0 {
+			p.addError(custom[0])
+		} else {
+			code, ok := expectationCodes[expectation]
+			if !ok {
+				code = diag.P001
+			}
+			d := diag.New(p.line, code, translateToken(expectation), p.cursor.Current().Value)
+			if expectation == token.END && p.hasType(token.END_OF_FILE) {
+				d = d.WithFixes(p.insertAfter("\nend")...) // the last block is not closed
+			}
+			p.addDiagnostic(d)
 		}
-		p.addError(msg)
 	}
 	return p.consumeToken()
 }
 
+// matchSemicolon matches ';'. If it is missing but the next token already
+// starts a declaration, an execution or one of the given follow tokens, the
+// semicolon is assumed to be forgotten and nothing is consumed.
+func (p *Parser) matchSemicolon(follow ...token.TokenType) {
+	if p.hasType(token.SEMICOLON) {
+		p.match(token.SEMICOLON)
+		return
+	}
+	if p.hasType(token.INTEGER) || p.hasType(token.PROCEDURE) || p.startsExecution() || slices.Contains(follow, p.cursor.Current().Type) {
+		d := diag.New(p.line, diag.P014, translateToken(token.SEMICOLON), p.cursor.Current().Value)
+		p.addDiagnostic(d.WithFixes(p.insertAfter(";")...))
+		return
+	}
+	p.match(token.SEMICOLON)
+}
+
+// startsExecution reports whether the current token unambiguously begins an execution
+func (p *Parser) startsExecution() bool {
+	switch p.cursor.Current().Type {
+	case token.READ, token.WRITE, token.WRITELN, token.IF, token.CASE, token.WHILE, token.REPEAT, token.FOR:
+		return true
+	case token.IDENTIFIER:
+		return p.peekType() == token.ASSIGN || p.peekType() == token.LEFT_BRACKET || p.atCall()
+	}
+	return false
+}
+
+// atCall reports whether the current token names a procedure that is called
+func (p *Parser) atCall() bool {
+	return p.hasType(token.IDENTIFIER) && p.peekType() == token.LEFT_PARENTHESES &&
+		(p.fragment || p.findProcedure(p.cursor.Current().Value))
+}
+
+// peekType returns the type of the token after the current one, skipping line breaks
+func (p *Parser) peekType() token.TokenType {
+	for k := 1; ; k++ {
+		tok, ok := p.cursor.PeekN(k)
+		if !ok {
+			return token.END_OF_FILE
+		}
+		if !tok.Type.IsLayout() {
+			return tok.Type
+		}
+	}
+}
+
+// matchDeclaredName matches the name in a declaration, reporting reserved
+// words specifically. It returns false if the name is not a valid identifier.
+func (p *Parser) matchDeclaredName() (token.Token, bool) {
+	if p.cursor.Current().IsKeyword() {
+		p.addError(diag.P016, p.cursor.Current().Value)
+		return p.consumeToken(), false
+	}
+	tok := p.match(token.IDENTIFIER)
+	return tok, tok.Type == token.IDENTIFIER
+}
+
+// isMisspelledKeyword reports whether the current token is an unknown
+// identifier close enough to the expected keyword to be a typo of it
+func (p *Parser) isMisspelledKeyword(expectation token.TokenType) bool {
+	current := p.cursor.Current()
+	if !expectation.IsKeyword() || current.Type != token.IDENTIFIER {
+		return false
+	}
+	if p.findVariable(current.Value) || p.findProcedure(current.Value) {
+		return false
+	}
+
+	keyword := expectation.String()
+	limit := 2
+	if len(keyword) <= 3 {
+		limit = 1
+	}
+	distance := editDistance(strings.ToLower(current.Value), keyword)
+	return distance >= 1 && distance <= limit
+}
+
 func (p *Parser) consumeToken() token.Token {
+	select {
+	case <-p.ctx.Done():
+		panic(errCanceled)
+	default:
+	}
 	p.goToNextLine()
+	if p.cursor.AtEnd() {
+		return p.cursor.Current()
+	}
+	column := p.column()
+	if p.cursor.Current().Type == token.IDENTIFIER {
+		current := p.cursor.Current()
+		p.consumed = Use{Name: current.Value, Token: p.cursor.Mark(), File: p.file, Line: p.line, Column: column}
+	}
 	tok := p.cursor.Consume()
+	p.end = diag.Pos{File: p.file, Line: p.line}
+	if column > 0 {
+		p.end.Column = column + utf8.RuneCountInString(tok.Value)
+	}
 	p.correctTokens = append(p.correctTokens, tok)
+	p.addLeaf(tok)
 	p.goToNextLine()
 	return tok
 }
 
+// sourceText joins the values of the tokens accepted since correctTokens[start]
+func (p *Parser) sourceText(start int) string {
+	var values []string
+	for _, tok := range p.correctTokens[start:] {
+		if !tok.Type.IsLayout() {
+			values = append(values, tok.Value)
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// goToNextLine skips line breaks and the markers of included files,
+// keeping track of the current position
 func (p *Parser) goToNextLine() {
-	for p.cursor.IsOpen() && p.hasType(token.END_OF_LINE) {
+	for p.cursor.IsOpen() && p.cursor.Current().Type.IsLayout() {
 		tok := p.cursor.Consume()
 		p.correctTokens = append(p.correctTokens, tok)
-		p.line++
 		p.shouldAddError = true
+		if path, line, ok := token.ParseSourceMarker(tok); ok {
+			p.file, p.line = path, line
+			if path == p.source {
+				p.file = "" // back in the compiled source
+			}
+			continue
+		}
+		p.line++
 	}
 }
 
-func (p *Parser) throwError(error string) {
-	panic(fmt.Errorf("***LINE %d: %s", p.line, error))
+func (p *Parser) throwError(code diag.Code, args ...any) {
+	panic(diag.New(p.line, code, args...))
 }
 
-func (p *Parser) addError(error string) {
+func (p *Parser) addError(code diag.Code, args ...any) {
+	p.addDiagnostic(diag.New(p.line, code, args...))
+}
+
+// addDiagnostic reports d unless an error was already reported on this
+// line. The fixes of d still apply then, so that error carries them, as
+// when the 'end' missing at the end of the file follows another error.
+func (p *Parser) addDiagnostic(d diag.Diagnostic) {
 	if !p.shouldAddError {
+		if len(d.Fixes) > 0 {
+			p.reporter.AddFixes(p.lineError, d.Fixes...)
+			p.lineFixes += len(d.Fixes)
+		}
 		return
 	}
+	if p.fragment && !isSyntaxError(d) {
+		return // the declarations to check names against are missing
+	}
 	p.shouldAddError = false
-	p.errors = append(p.errors, fmt.Sprintf("***LINE %d: %s", p.line, error))
+	p.lineError, p.lineFixes = p.reporter.Len(), len(d.Fixes)
+	p.report(d)
 }
 
-func translateToken(t token.TokenType) string {
-	tokenTranslation := map[token.TokenType]string{
-		token.BEGIN:                 "'begin'",
-		token.END:                   "'end'",
-		token.INTEGER:               "'integer'",
-		token.IF:                    "'if'",
-		token.THEN:                  "'then'",
-		token.ELSE:                  "'else'",
-		token.FUNCTION:              "'function'",
-		token.READ:                  "'read'",
-		token.WRITE:                 "'write'",
-		token.IDENTIFIER:            "identifier",
-		token.CONSTANT:              "constant",
-		token.EQUAL:                 "'='",
-		token.NOT_EQUAL:             "'<>'",
-		token.LESS_THAN_OR_EQUAL:    "'<='",
-		token.LESS_THAN:             "'<'",
-		token.GREATER_THAN_OR_EQUAL: "'>='",
-		token.GREATER_THAN:          "'>'",
-		token.SUBTRACT:              "'-'",
-		token.MULTIPLY:              "'*'",
-		token.ASSIGN:                "':='",
-		token.LEFT_PARENTHESES:      "'('",
-		token.RIGHT_PARENTHESES:     "')'",
-		token.SEMICOLON:             "';'",
-		token.END_OF_LINE:           "EOLN",
-		token.END_OF_FILE:           "EOF",
-	}
-	return tokenTranslation[t]
-}
-
-func readTokens() []token.Token {
-	data, err := os.ReadFile(config.DYD_PATH)
-	if err != nil {
-		panic(err)
+func (p *Parser) addWarning(line int, code diag.Code, args ...any) {
+	p.addWarningAt(diag.Pos{File: p.file, Line: line}, code, args...)
+}
+
+// addWarningAt reports a warning about a declaration, which may be in
+// another file than the current position
+func (p *Parser) addWarningAt(pos diag.Pos, code diag.Code, args ...any) {
+	if d, ok := diag.NewWarning(pos.Line, code, args...); ok {
+		d.Pos = pos
+		p.report(d)
 	}
-	text := strings.TrimSpace(string(data))
-	tokens := make([]token.Token, 0)
+}
 
-	for _, line := range strings.Split(text, "\n") {
-		parts := strings.Fields(strings.TrimSpace(line))
-		if len(parts) < 2 {
-			continue
+// errTooManyErrors unwinds the parser once --max-errors is reached
+var errTooManyErrors = errors.New("too many errors")
+
+// errCanceled unwinds the parser once its context is done
+var errCanceled = errors.New("parsing canceled")
+
+func (p *Parser) report(d diag.Diagnostic) {
+	p.record(d)
+	if d.Severity == diag.Error && p.reporter.Full() {
+		panic(errTooManyErrors)
+	}
+}
+
+func (p *Parser) record(d diag.Diagnostic) {
+	if d.Pos.File == "" {
+		d.Pos.File = p.file
+	}
+	p.reporter.Report(d)
+	if d.Severity == diag.Error {
+		p.errors++
+		if isSyntaxError(d) {
+			p.syntaxErrors++
 		}
-		value := parts[0]
-		typeVal := parts[1]
-		var t token.TokenType
-		fmt.Sscanf(typeVal, "%d", &t)
-		tokens = append(tokens, token.Token{Type: t, Value: value})
 	}
-	return tokens
 }
 
-func writeCorrectTokens(tokens []token.Token) {
-	var lines []string
-	for _, t := range tokens {
-		line := fmt.Sprintf("%-16s %02d", t.Value, t.Type)
-		lines = append(lines, line)
+// isSyntaxError reports whether d leaves the syntax tree incomplete
+func isSyntaxError(d diag.Diagnostic) bool {
+	return d.Severity == diag.Error && (strings.HasPrefix(string(d.Code), "P") || d.Fatal)
+}
+
+// expectationCodes holds the diagnostic codes for tokens that are commonly forgotten
+var expectationCodes = map[token.TokenType]diag.Code{
+	token.BEGIN:     diag.P009,
+	token.END:       diag.P010,
+	token.THEN:      diag.P011,
+	token.ELSE:      diag.P012,
+	token.ASSIGN:    diag.P013,
+	token.SEMICOLON: diag.P014,
+}
+
+// editDistance returns the optimal string alignment distance between a and b,
+// counting insertions, deletions, substitutions and adjacent transpositions
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
 	}
-	text := strings.Join(lines, "\n")
-	os.WriteFile(config.DYS_PATH, []byte(text), 0644)
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
 }
 
-func writeVariables(variables []Variable) {
-	var lines []string
-	for _, v := range variables {
-		line := fmt.Sprintf("Var\n    Name      = %s\n    Procedure = %s\n    Kind      = %%!s(main.VarKind=%d)\n    Type      = %s\n    Level     = %d\n    Offset    = %d",
-			v.Name, v.Procedure, v.Kind, v.Type, v.Level, v.Address)
-		lines = append(lines, line)
+func translateToken(t token.TokenType) string {
+	switch t {
+	case token.IDENTIFIER, token.CONSTANT, token.STRING, token.END_OF_LINE, token.END_OF_FILE:
+		return diag.Term(t.String())
 	}
-	text := strings.Join(lines, "\n")
-	os.WriteFile(config.VAR_PATH, []byte(text), 0644)
+	return "'" + t.String() + "'"
 }
 
-func writeProcedures(procedures []Procedure) {
-	var lines []string
-	for _, p := range procedures {
-		line := fmt.Sprintf("Proc\n    Name      = %s\n    Type      = %s\n    Level     = %d\n    FirstVar  = %d\n    LastVar   = %d",
-			p.Name, p.Type, p.Level, p.FirstVariableAddress, p.LastVariableAddress)
-		lines = append(lines, line)
+func readTokens() ([]token.Token, error) {
+	data, err := os.ReadFile(config.TokenPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []token.Token
+	if config.TokenFormat == "json" {
+		tokens, err = readJSONTokens(data)
+	} else {
+		tokens, err = readDydTokens(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 || tokens[len(tokens)-1].Type != token.END_OF_FILE {
+		return nil, fmt.Errorf("%s: token stream is truncated, missing final EOF", config.TokenPath())
 	}
-	text := strings.Join(lines, "\n")
-	os.WriteFile(config.PRO_PATH, []byte(text), 0644)
+	return tokens, nil
 }
 
-func writeErrors(errors []string) {
-	text := strings.Join(errors, "\n")
-	os.WriteFile(config.ERR_PATH, []byte(text), 0644)
+func readDydTokens(data []byte) ([]token.Token, error) {
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return make([]token.Token, 0), nil
+	}
+
+	lines := strings.Split(text, "\n")
+	tokens := make([]token.Token, 0, len(lines)) // one token per line
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue // header such as the --input-hash stamp; '#' is never a token
+		}
+		// The type is the last field; only strings and file names may contain spaces
+		line = strings.TrimRight(line, " \r")
+		cut := strings.LastIndexByte(line, ' ')
+		value, typ := strings.TrimRight(line[:max(cut, 0)], " "), line[cut+1:]
+		code, err := strconv.Atoi(typ)
+		if value == "" || (token.TokenType(code) != token.STRING && token.TokenType(code) != token.SOURCE_FILE && strings.Contains(value, " ")) {
+			return nil, fmt.Errorf("%s:%d: expected '<value> <type>', but got '%s'",
+				config.DYD_PATH, i+1, strings.TrimSpace(line))
+		}
+		if err != nil || !token.TokenType(code).IsValid() {
+			return nil, fmt.Errorf("%s:%d: unknown token type '%s' for '%s'",
+				config.DYD_PATH, i+1, typ, value)
+		}
+		tokens = append(tokens, token.Token{Type: token.TokenType(code), Value: value})
+	}
+	return tokens, nil
 }
 
-func (p *Parser) ListErrors() {
-	for i, err := range p.errors {
-		fmt.Printf("Error %d: %s\n", i+1, err)
+func readJSONTokens(data []byte) ([]token.Token, error) {
+	tokens := make([]token.Token, 0)
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %v", config.DYD_JSON_PATH, err)
 	}
+	return tokens, nil
 }
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"compiler/config"
+	"compiler/diag"
+	"compiler/lexer"
+	"compiler/token"
+)
+
+// benchmarkLines is the number of executions of the generated program
+const benchmarkLines = 50000
+
+// largeProgram returns a valid program with the given number of executions
+// after a few declarations
+func largeProgram(executions int) string {
+	var sb strings.Builder
+	sb.WriteString("begin\n  integer k;\n  integer m;\n  integer a[10];\n")
+	sb.WriteString("  integer function F(n);\n  begin\n    integer n;\n    if n <= 0 then F := 1\n    else F := n * F(n - 1)\n  end;\n")
+	sb.WriteString("  read(m);\n  k := 0")
+	for i := range executions {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&sb, ";\n  k := F(m) - k * %d", i%100)
+		case 1:
+			sb.WriteString(";\n  a[3] := k - m")
+		case 2:
+			sb.WriteString(";\n  if k <> a[3] then write(k) else read(m)")
+		case 3:
+			sb.WriteString(";\n  while k > m do k := k - 1")
+		}
+	}
+	sb.WriteString("\nend\n")
+	return sb.String()
+}
+
+// scanTokens returns the tokens the lexer yields for source
+func scanTokens(source string) []token.Token {
+	var tokens []token.Token
+	for tok := range lexer.NewFromReader(strings.NewReader(source), diag.NewReporter(0)).Tokens() {
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+// TestCallStatementNeedsExt checks that a call statement, which only the
+// ext grammar has, is rejected by the smaller dialects
+func TestCallStatementNeedsExt(t *testing.T) {
+	defer func(dialect string) { config.Dialect = dialect }(config.Dialect)
+	const source = "begin\n  integer k;\n  integer function F(n);\n  begin\n    integer n;\n    F := n\n  end;\n  k := 1;\n  F(k)\nend\n"
+	for _, dialect := range config.Dialects {
+		config.Dialect = dialect
+		reporter := diag.NewReporter(0)
+		ok := newParser(scanTokens(source), reporter).Parse()
+		if want := dialect == "ext"; ok != want {
+			diagnostics, _ := reporter.Diagnostics()
+			t.Errorf("under %s Parse() = %v, want %v; diagnostics %v", dialect, ok, want, diagnostics)
+		}
+	}
+}
+
+// BenchmarkReadTokens reads the token file of a large program
+func BenchmarkReadTokens(b *testing.B) {
+	var dyd strings.Builder
+	for _, tok := range scanTokens(largeProgram(benchmarkLines)) {
+		fmt.Fprintf(&dyd, "%-16s %02d\n", tok.Value, tok.Type)
+	}
+	data := []byte(dyd.String())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := readDydTokens(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParse parses the tokens of a large program
+func BenchmarkParse(b *testing.B) {
+	tokens := scanTokens(largeProgram(benchmarkLines))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		reporter := diag.NewReporter(0)
+		if !newParser(tokens, reporter).Parse() {
+			b.Fatal(reporter.Diagnostics())
+		}
+	}
+}
+
+// BenchmarkDocument scans and parses a large program as a Document
+func BenchmarkDocument(b *testing.B) {
+	source := largeProgram(benchmarkLines)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		NewDocument(source)
+	}
+}
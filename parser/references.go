@@ -0,0 +1,91 @@
+package parser
+
+import "unicode/utf8"
+
+// UseAt returns the resolved name that covers the given line and display
+// column of a file, empty for the compiled source
+func (p *Parser) UseAt(file string, line, column int) (Use, bool) {
+	for _, u := range p.uses {
+		if u.File == file && u.Line == line && u.Column > 0 &&
+			column >= u.Column && column < u.Column+utf8.RuneCountInString(u.Name) {
+			return u, true
+		}
+	}
+	return Use{}, false
+}
+
+// Definition returns where the symbol u stands for is declared: the heading
+// for a parameter, whose uses resolve to its declaration in the body, and
+// the forward declaration of a function that has one
+func (p *Parser) Definition(u Use) Use {
+	for _, d := range p.References(u) {
+		if d.Declaration {
+			return d
+		}
+	}
+	return u
+}
+
+// References returns the uses of the symbol u stands for in the order they
+// were parsed, its declarations included. A parameter and its declaration
+// in the body are the same symbol.
+func (p *Parser) References(u Use) []Use {
+	params := p.parameterDeclarations()
+	variable, procedure := symbolOf(u, params)
+	var refs []Use
+	for _, r := range p.uses {
+		if v, proc := symbolOf(r, params); v == variable && proc == procedure {
+			refs = append(refs, r)
+		}
+	}
+	return refs
+}
+
+// KindOf returns the kind of the symbol u stands for, as in the outline,
+// and the procedure it is declared in
+func (p *Parser) KindOf(u Use) (kind, scope string) {
+	variable, procedure := symbolOf(u, p.parameterDeclarations())
+	if procedure >= 0 {
+		proc := p.procedures[procedure]
+		if proc.Type == "void" {
+			return "procedure", proc.Parent
+		}
+		return "function", proc.Parent
+	}
+	v := p.variables[variable]
+	switch {
+	case v.Kind != 0:
+		return "parameter", v.Procedure
+	case v.Size > 0:
+		return "array", v.Procedure
+	}
+	return "variable", v.Procedure
+}
+
+// symbolOf returns the indices of the variable and procedure u stands for,
+// taking the body declaration of a parameter for the parameter
+func symbolOf(u Use, params map[int]int) (variable, procedure int) {
+	if param, ok := params[u.Variable]; ok {
+		return param, -1
+	}
+	return u.Variable, u.Procedure
+}
+
+// parameterDeclarations maps the index of the body declaration of each
+// parameter to the index of the parameter
+func (p *Parser) parameterDeclarations() map[int]int {
+	type key struct{ procedure, name string }
+	parameters := make(map[key]int)
+	for i, v := range p.variables {
+		if _, ok := parameters[key{v.Procedure, v.Name}]; v.Kind != 0 && !ok {
+			parameters[key{v.Procedure, v.Name}] = i
+		}
+	}
+	params := make(map[int]int)
+	for i, v := range p.variables {
+		if param, ok := parameters[key{v.Procedure, "_" + v.Name}]; ok {
+			params[i] = param
+		}
+	}
+	return params
+}
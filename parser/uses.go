@@ -0,0 +1,48 @@
+package parser
+
+// Use is an occurrence of a name in the program that was resolved to the
+// symbol it stands for
+type Use struct {
+	Name        string
+	Token       int    // position of the name in the token stream
+	File        string // included file, empty for the compiled source
+	Line        int
+	Column      int  // display column as in diagnostics, 0 for tokens read from the token file
+	Variable    int  // index into Variables, or -1 for a procedure
+	Procedure   int  // index into Procedures, or -1 for a variable
+	Declaration bool // the symbol is declared, or a forward declared function defined, here
+}
+
+// Uses returns the resolved names in the order they were parsed. Names that
+// are undeclared or declared twice are left out.
+func (p *Parser) Uses() []Use {
+	return p.uses
+}
+
+// useVariable records that the name at u stands for the i-th variable
+func (p *Parser) useVariable(u Use, i int, declaration bool) {
+	u.Variable, u.Procedure, u.Declaration = i, -1, declaration
+	p.uses = append(p.uses, u)
+}
+
+// useProcedure records that the name at u stands for proc, which may be nil
+// if the name is undeclared
+func (p *Parser) useProcedure(u Use, proc *Procedure, declaration bool) {
+	for i := range p.procedures {
+		if &p.procedures[i] == proc {
+			u.Variable, u.Procedure, u.Declaration = -1, i, declaration
+			p.uses = append(p.uses, u)
+			return
+		}
+	}
+}
+
+// variableIndex returns the index of v in the variables, or -1 if v is nil
+func (p *Parser) variableIndex(v *Variable) int {
+	for i := range p.variables {
+		if &p.variables[i] == v {
+			return i
+		}
+	}
+	return -1
+}
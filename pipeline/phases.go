@@ -0,0 +1,289 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+
+	"compiler/ast"
+	"compiler/config"
+	"compiler/diag"
+	"compiler/emit"
+	"compiler/grammar"
+	"compiler/lexer"
+	"compiler/lr"
+	"compiler/parser"
+	"compiler/report"
+	"compiler/sourcefile"
+	"compiler/token"
+)
+
+// lexPhase scans the source, streaming its tokens to the token file
+type lexPhase struct{}
+
+func (lexPhase) Name() string { return "lexer" }
+
+func (lexPhase) Run(ctx context.Context, c *Compilation) error {
+	lex, err := lexer.New(c.Reporter)
+	if err != nil {
+		return err
+	}
+	c.Lexer = lex
+	err = errors.Join(writeTokens(ctx, c), writeStats(c))
+	if lex.Err() != nil {
+		return lex.Err()
+	}
+	return err // parsing a stale token file would report misleading errors
+}
+
+func (lexPhase) stats(c *Compilation) []any {
+	if c.Lexer == nil {
+		return nil
+	}
+	return []any{"tokens", c.tokens, "errors", c.Lexer.ErrorCount()}
+}
+
+// parsePhase reads the token file back and parses it with the parser
+// selected by --parser, even after lexer errors, so that both phases
+// contribute to one report. The recursive descent parser also checks the
+// semantics while parsing.
+type parsePhase struct{}
+
+func (parsePhase) Name() string { return "parser" }
+
+func (parsePhase) Run(ctx context.Context, c *Compilation) error {
+	pars, err := parser.New(c.Reporter)
+	if err != nil {
+		return err
+	}
+	c.Parser = pars
+	pars.SetColumns(c.columns)
+	if config.Parser != "lr" {
+		_, err := pars.ParseContext(ctx)
+		return err
+	}
+	c.table = lr.Build(grammar.Active())
+	_, err = pars.ParseLRContext(ctx, c.table)
+	return err
+}
+
+func (parsePhase) stats(c *Compilation) []any {
+	if c.Parser == nil {
+		return nil
+	}
+	return []any{"tokens", len(c.Parser.CorrectTokens()), "variables", len(c.Parser.Variables()), "procedures", len(c.Parser.Procedures())}
+}
+
+// outputPhase writes the artifacts of the parser, and the tables of the LR
+// parser to output.lr
+type outputPhase struct{}
+
+func (outputPhase) Name() string { return "output" }
+
+func (outputPhase) Run(ctx context.Context, c *Compilation) error {
+	var tablesErr error
+	if c.table != nil {
+		tablesErr = emit.File(config.LR_PATH, c.table.Write)
+	}
+	return errors.Join(tablesErr, writeParserArtifacts(c.Parser), writeHighlight(c), writeFixed(c), writeProtobuf(c))
+}
+
+// writeProtobuf writes the tokens, tree, symbol tables and diagnostics of
+// the compilation as protocol buffers if --emit=pb is given
+func writeProtobuf(c *Compilation) error {
+	if !config.Emits("pb") {
+		return nil
+	}
+	diagnostics, _ := c.Reporter.Diagnostics()
+	return emit.File(config.PB_PATH, func(w io.Writer) error {
+		return emit.Protobuf(w, c.kept, c.Parser.AST(), c.Parser.Variables(), c.Parser.Procedures(), diagnostics)
+	})
+}
+
+// writeHighlight writes the source colored by the kinds of its tokens if
+// --emit=highlight-html is given
+func writeHighlight(c *Compilation) error {
+	if !config.Emits("highlight-html") {
+		return nil
+	}
+	source, err := sourcefile.Text(config.SOURCE_PATH)
+	if err != nil {
+		return err
+	}
+	spans := report.Classify(source, c.Parser.Uses(), c.Parser.Variables(), c.Parser.Procedures())
+	return emit.File(config.HL_PATH, func(w io.Writer) error {
+		return report.WriteHighlight(w, config.SOURCE_PATH, source, spans)
+	})
+}
+
+// writeFixed writes the source with the fixes of its diagnostics applied
+// if --fix is given. Fixes are applied from the end of the source, and those
+// of a diagnostic that overlap the ones of another are left out.
+func writeFixed(c *Compilation) error {
+	if !config.Fix {
+		return nil
+	}
+	source, err := sourcefile.Text(config.SOURCE_PATH)
+	if err != nil {
+		return err
+	}
+	diagnostics, _ := c.Reporter.Diagnostics()
+	return emit.File(config.FIXED_PATH, func(w io.Writer) error {
+		_, err := io.WriteString(w, applyFixes(source, diagnostics))
+		return err
+	})
+}
+
+// applyFixes returns source with the fixes of the diagnostics in it applied
+func applyFixes(source string, diagnostics []diag.Diagnostic) string {
+	starts := sourcefile.LineStarts(source)
+	offset := func(pos diag.Pos) int {
+		if pos.Line > len(starts) {
+			return len(source)
+		}
+		return starts[pos.Line-1] + sourcefile.ColumnOffset(source[starts[pos.Line-1]:], pos.Column)
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	for _, d := range diagnostics {
+		var fixes []edit
+		for _, fix := range d.Fixes {
+			if fix.Range.Start.File != "" || fix.Range.End.File != "" {
+				fixes = nil // an included file is not rewritten
+				break
+			}
+			fixes = append(fixes, edit{offset(fix.Range.Start), offset(fix.Range.End), fix.NewText})
+		}
+		overlaps := slices.ContainsFunc(fixes, func(f edit) bool {
+			return slices.ContainsFunc(edits, func(e edit) bool {
+				return (f.start < e.end && e.start < f.end) || f.start == e.start
+			})
+		})
+		if !overlaps {
+			edits = append(edits, fixes...)
+		}
+	}
+
+	slices.SortStableFunc(edits, func(a, b edit) int { return b.start - a.start })
+	for _, e := range edits {
+		source = source[:e.start] + e.text + source[e.end:]
+	}
+	return source
+}
+
+// writeTokens runs the lexer of c, streaming its tokens to the token file
+// and counting them. The token file has no columns, so they are kept for
+// the highlighted source and the fixes of the diagnostics.
+func writeTokens(ctx context.Context, c *Compilation) error {
+	emitter, err := emit.New(config.TokenFormat)
+	if err != nil {
+		return err
+	}
+	keepColumns := config.Emits("highlight-html") || config.Fix
+	keepTokens := config.Emits("pb")
+	if config.Stats != "" {
+		c.stats = emit.NewTokenStats()
+	}
+	counted := func(yield func(token.Token) bool) {
+		for tok := range c.Lexer.TokensContext(ctx) {
+			c.tokens++
+			if keepColumns {
+				c.columns = append(c.columns, tok.Column)
+			}
+			if keepTokens {
+				c.kept = append(c.kept, tok)
+			}
+			if c.stats != nil {
+				c.stats.Add(tok)
+			}
+			if !yield(tok) {
+				return
+			}
+		}
+	}
+	return emit.File(config.TokenPath(), func(w io.Writer) error {
+		return emitter.EmitTokens(w, counted)
+	})
+}
+
+// writeStats writes the statistics of the tokens in the format selected by
+// --stats, if given
+func writeStats(c *Compilation) error {
+	if c.stats == nil {
+		return nil
+	}
+	write := c.stats.WriteText
+	if config.Stats == "json" {
+		write = c.stats.WriteJSON
+	}
+	return emit.File(config.StatsPath(), write)
+}
+
+// writeParserArtifacts writes the accepted tokens, the symbol tables and the
+// cross-reference listing
+func writeParserArtifacts(pars *parser.Parser) error {
+	emitter, err := emit.New(config.SymbolsFormat)
+	if err != nil {
+		return err
+	}
+	varPath, proPath := config.SymbolPaths()
+
+	errs := []error{
+		emit.File(config.DYS_PATH, func(w io.Writer) error {
+			return emit.Text{}.EmitTokens(w, slices.Values(pars.CorrectTokens()))
+		}),
+		emit.File(varPath, func(variables io.Writer) error {
+			return emit.File(proPath, func(procedures io.Writer) error {
+				return emitter.EmitSymbols(variables, procedures, pars.Variables(), pars.Procedures())
+			})
+		}),
+		emit.File(config.XRF_PATH, func(w io.Writer) error {
+			return emit.CrossReference(w, pars.Variables(), pars.Procedures())
+		}),
+	}
+	// A program with syntax errors has no tree, and neither has one with
+	// unchecked or wrong names for an artifact that needs them right
+	checked := config.Parser != "lr" && !pars.HasSemanticErrors()
+	for _, kind := range config.EmitKinds {
+		artifact, ok := treeArtifacts[kind]
+		if !ok || !config.Emits(kind) {
+			continue
+		}
+		errs = append(errs, emit.File(artifact.path, func(w io.Writer) error {
+			if tree := pars.Tree(); tree != nil && (checked || !artifact.checked) {
+				return artifact.write(w, tree)
+			}
+			if artifact.placeholder != nil {
+				return artifact.placeholder(w)
+			}
+			return nil
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// treeArtifacts holds the --emit artifacts drawn from the concrete syntax
+// tree. A checked artifact is only written for a program whose semantics the
+// recursive descent parser checked without errors, as the Go translation
+// wouldn't compile otherwise. Without a tree an artifact is left empty, or
+// gets its placeholder.
+var treeArtifacts = map[string]struct {
+	path        string
+	write       func(io.Writer, *parser.Node) error
+	checked     bool
+	placeholder func(io.Writer) error
+}{
+	"derivation": {path: config.DRV_PATH, write: emit.Derivation},
+	"cst-dot":    {path: config.CST_DOT_PATH, write: emit.CSTDot},
+	"ast-json": {path: config.AST_PATH, write: func(w io.Writer, tree *parser.Node) error {
+		return ast.WriteJSON(w, parser.ToAST(tree))
+	}},
+	"go": {path: config.GO_PATH, write: func(w io.Writer, tree *parser.Node) error {
+		return emit.GoSource(w, parser.ToAST(tree))
+	}, checked: true, placeholder: emit.GoPlaceholder},
+}
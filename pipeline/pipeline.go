@@ -0,0 +1,90 @@
+// Package pipeline runs a compilation as a sequence of phases. The built-in
+// phases scan the source, parse it and write the artifacts; other code can
+// register its own phases between them, such as a custom lint that reports
+// to the same diagnostics.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"compiler/diag"
+	"compiler/emit"
+	"compiler/lexer"
+	"compiler/lr"
+	"compiler/parser"
+	"compiler/token"
+)
+
+// Compilation is the state shared by the phases of one compilation
+type Compilation struct {
+	Reporter *diag.Reporter
+	Lexer    *lexer.Lexer   // set by the lexer phase
+	Parser   *parser.Parser // set by the parser phase
+
+	tokens  int              // tokens written to the token file
+	columns []int            // columns of the tokens, kept for --emit=highlight-html and --fix
+	kept    []token.Token    // the tokens themselves, kept for --emit=pb
+	stats   *emit.TokenStats // statistics of the tokens, counted for --stats
+	table   *lr.Table        // tables of --parser=lr, written by the output phase
+}
+
+// Phase is a step of a compilation. Run reports problems with the program
+// to c.Reporter and returns an error only if the compilation cannot go on,
+// such as when a file cannot be written or ctx is done.
+type Phase interface {
+	Name() string
+	Run(ctx context.Context, c *Compilation) error
+}
+
+// phases are the registered phases in the order they run
+var phases = []Phase{lexPhase{}, parsePhase{}, outputPhase{}}
+
+// Register inserts phase right after the phase named after, or before all
+// phases if after is empty. It panics if there is no such phase or the name
+// of phase is taken, so it is meant to be called from init functions.
+func Register(after string, phase Phase) {
+	if slices.ContainsFunc(phases, func(p Phase) bool { return p.Name() == phase.Name() }) {
+		panic(fmt.Sprintf("pipeline: phase %s registered twice", phase.Name()))
+	}
+	i := 0
+	if after != "" {
+		i = slices.IndexFunc(phases, func(p Phase) bool { return p.Name() == after })
+		if i < 0 {
+			panic(fmt.Sprintf("pipeline: no phase %s to register %s after", after, phase.Name()))
+		}
+		i++
+	}
+	phases = slices.Insert(phases, i, phase)
+}
+
+// Phases returns the names of the registered phases in the order they run
+func Phases() []string {
+	names := make([]string, len(phases))
+	for i, p := range phases {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Run runs the registered phases on c, stopping at the first that fails,
+// and returns its error. Each phase is logged with its wall time.
+func Run(ctx context.Context, c *Compilation) error {
+	for _, phase := range phases {
+		slog.Info("phase started", "phase", phase.Name())
+		start := time.Now()
+		err := phase.Run(ctx, c)
+		attrs := []any{"phase", phase.Name(), "elapsed", time.Since(start)}
+		if s, ok := phase.(interface{ stats(c *Compilation) []any }); ok {
+			attrs = append(attrs, s.stats(c)...)
+		}
+		slog.Info("phase finished", attrs...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package pointer
 
 // Cursor represents a cursor over a collection of type T
 type Cursor[T any] struct {
-	position   int
-	collection []T
+	position    int
+	collection  []T
+	sentinel    T
+	hasSentinel bool
 }
 
 // NewCursor creates a new cursor for the given collection
@@ -14,15 +16,80 @@ func NewCursor[T any](collection []T) *Cursor[T] {
 	}
 }
 
-// Current returns the current element in the collection
+// NewSentinelCursor creates a new cursor that yields sentinel instead of
+// panicking once the collection is exhausted
+func NewSentinelCursor[T any](collection []T, sentinel T) *Cursor[T] {
+	return &Cursor[T]{
+		position:    0,
+		collection:  collection,
+		sentinel:    sentinel,
+		hasSentinel: true,
+	}
+}
+
+// Current returns the current element in the collection, or the sentinel
+// if the cursor has one and is at the end
 func (c *Cursor[T]) Current() T {
+	if current, ok := c.TryCurrent(); ok {
+		return current
+	}
+	if c.hasSentinel {
+		return c.sentinel
+	}
 	return c.collection[c.position]
 }
 
-// Consume returns the current element and moves the cursor forward
+// TryCurrent returns the current element and whether the cursor is still open
+func (c *Cursor[T]) TryCurrent() (T, bool) {
+	if c.position < len(c.collection) {
+		return c.collection[c.position], true
+	}
+	var zero T
+	return zero, false
+}
+
+// Peek returns the element after the current one and whether it exists
+func (c *Cursor[T]) Peek() (T, bool) {
+	return c.PeekN(1)
+}
+
+// PeekN returns the element k positions after the current one and whether
+// it exists; past the end it returns the sentinel, or the zero value if the
+// cursor has none. PeekN(0) is equivalent to TryCurrent.
+func (c *Cursor[T]) PeekN(k int) (T, bool) {
+	if index := c.position + k; k >= 0 && index < len(c.collection) {
+		return c.collection[index], true
+	}
+	var zero T
+	if c.hasSentinel {
+		return c.sentinel, false
+	}
+	return zero, false
+}
+
+// Mark returns the current position so it can be restored with Reset.
+// Marks may be nested; resetting to an outer mark discards inner ones.
+func (c *Cursor[T]) Mark() int {
+	return c.position
+}
+
+// Reset moves the cursor back (or forward) to a position returned by Mark
+func (c *Cursor[T]) Reset(mark int) {
+	c.position = min(max(mark, 0), len(c.collection))
+}
+
+// Len returns the number of elements that have not been consumed yet
+func (c *Cursor[T]) Len() int {
+	return len(c.collection) - c.position
+}
+
+// Consume returns the current element and moves the cursor forward.
+// A cursor at the end stays there.
 func (c *Cursor[T]) Consume() T {
 	current := c.Current()
-	c.position++
+	if c.IsOpen() {
+		c.position++
+	}
 	return current
 }
 
@@ -30,3 +97,9 @@ func (c *Cursor[T]) Consume() T {
 func (c *Cursor[T]) IsOpen() bool {
 	return c.position < len(c.collection)
 }
+
+// AtEnd returns true once every element has been consumed, i.e. when
+// Current yields the sentinel
+func (c *Cursor[T]) AtEnd() bool {
+	return !c.IsOpen()
+}
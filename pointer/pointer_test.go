@@ -0,0 +1,88 @@
+package pointer
+
+import "testing"
+
+// sentinel is yielded by the cursors of the tests past their elements
+const sentinel = -1
+
+func TestNestedMarks(t *testing.T) {
+	c := NewSentinelCursor([]int{1, 2, 3, 4, 5}, sentinel)
+	c.Consume()
+	outer := c.Mark()
+	c.Consume()
+	inner := c.Mark()
+	c.Consume()
+	c.Consume()
+
+	c.Reset(inner)
+	if got := c.Current(); got != 3 {
+		t.Errorf("Current after resetting to the inner mark = %d, want 3", got)
+	}
+	c.Consume()
+	c.Reset(outer)
+	if got := c.Current(); got != 2 {
+		t.Errorf("Current after resetting to the outer mark = %d, want 2", got)
+	}
+	if got := c.Len(); got != 4 {
+		t.Errorf("Len after resetting to the outer mark = %d, want 4", got)
+	}
+}
+
+func TestResetAfterSentinel(t *testing.T) {
+	c := NewSentinelCursor([]int{1, 2}, sentinel)
+	mark := c.Mark()
+	for range 4 {
+		c.Consume()
+	}
+	if !c.AtEnd() || c.Current() != sentinel || c.Len() != 0 {
+		t.Fatalf("cursor past its elements: AtEnd %v, Current %d, Len %d", c.AtEnd(), c.Current(), c.Len())
+	}
+	c.Reset(mark)
+	if !c.IsOpen() || c.Current() != 1 {
+		t.Errorf("after Reset: IsOpen %v, Current %d, want true and 1", c.IsOpen(), c.Current())
+	}
+	if got := c.Len(); got != 2 {
+		t.Errorf("Len after Reset = %d, want 2", got)
+	}
+}
+
+func TestResetClampsMark(t *testing.T) {
+	c := NewSentinelCursor([]int{1, 2, 3}, sentinel)
+	c.Reset(10)
+	if c.Len() != 0 || c.Current() != sentinel {
+		t.Errorf("Reset past the end: Len %d, Current %d, want 0 and the sentinel", c.Len(), c.Current())
+	}
+	c.Reset(-1)
+	if c.Len() != 3 || c.Current() != 1 {
+		t.Errorf("Reset before the start: Len %d, Current %d, want 3 and 1", c.Len(), c.Current())
+	}
+}
+
+func TestPeekN(t *testing.T) {
+	c := NewSentinelCursor([]int{1, 2, 3}, sentinel)
+	c.Consume()
+	tests := []struct {
+		k    int
+		want int
+		ok   bool
+	}{
+		{0, 2, true},
+		{1, 3, true},
+		{2, sentinel, false},
+		{100, sentinel, false},
+		{-1, sentinel, false},
+	}
+	for _, test := range tests {
+		if got, ok := c.PeekN(test.k); got != test.want || ok != test.ok {
+			t.Errorf("PeekN(%d) = %d, %v, want %d, %v", test.k, got, ok, test.want, test.ok)
+		}
+	}
+	if got := c.Current(); got != 2 {
+		t.Errorf("PeekN moved the cursor to %d", got)
+	}
+
+	// Without a sentinel the zero value comes back
+	if got, ok := NewCursor([]int{1}).PeekN(5); got != 0 || ok {
+		t.Errorf("PeekN past the end without a sentinel = %d, %v, want 0, false", got, ok)
+	}
+}
@@ -0,0 +1,57 @@
+package pointer
+
+import (
+	"bufio"
+	"io"
+)
+
+// RuneStream represents a cursor over runes read incrementally from a reader
+type RuneStream struct {
+	reader  *bufio.Reader
+	current rune
+	open    bool
+	err     error
+}
+
+// NewRuneStream creates a new stream that buffers reads from r
+func NewRuneStream(r io.Reader) *RuneStream {
+	s := &RuneStream{reader: bufio.NewReader(r)}
+	s.advance()
+	return s
+}
+
+// Current returns the current rune in the stream
+func (s *RuneStream) Current() rune {
+	return s.current
+}
+
+// Consume returns the current rune and moves the stream forward
+func (s *RuneStream) Consume() rune {
+	current := s.current
+	s.advance()
+	return current
+}
+
+// IsOpen returns true if the stream hasn't reached the end of the input
+func (s *RuneStream) IsOpen() bool {
+	return s.open
+}
+
+// Err returns the first read error other than io.EOF, if any
+func (s *RuneStream) Err() error {
+	return s.err
+}
+
+func (s *RuneStream) advance() {
+	ch, _, err := s.reader.ReadRune()
+	if err != nil {
+		s.open = false
+		s.current = 0
+		if err != io.EOF {
+			s.err = err
+		}
+		return
+	}
+	s.current = ch
+	s.open = true
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"compiler/config"
+	"compiler/diag"
+	"compiler/lexer"
+	"compiler/parser"
+	"compiler/sourcefile"
+)
+
+// rename renames the symbol named old, or the one whose name is at
+// line:column, to name everywhere it is used and rewrites the file. It
+// refuses if name would be declared twice or change what another name
+// refers to, which it finds by parsing the renamed program again.
+func rename(args []string) int {
+	if len(args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: rename file.pas old|line:column new")
+		return EXIT_IO
+	}
+	path, old, name := args[0], args[1], args[2]
+	if err := renameFile(path, old, name); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	return EXIT_SUCCESS
+}
+
+func renameFile(path, old, name string) error {
+	source, err := sourcefile.Text(path)
+	if err != nil {
+		return err
+	}
+	// The file is rewritten from its own bytes, so that its line breaks and
+	// byte order mark stay as they are
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	raw, bom := strings.CutPrefix(string(data), "\uFEFF")
+	doc := parser.NewDocumentAt(path, source)
+	pars := doc.Parser()
+	target, err := renameTarget(pars, path, old)
+	if err != nil {
+		return err
+	}
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	refs := pars.References(target)
+	for _, r := range refs {
+		if r.File != "" {
+			return fmt.Errorf("cannot rename %s: it is used in the included file %s", target.Name, r.File)
+		}
+	}
+	names := make(map[parser.Use]string)
+	for _, r := range refs {
+		names[r] = name
+	}
+	renamed := replaceNames(raw, names)
+
+	check := parser.NewDocumentAt(path, renamed)
+	if u, ok := changedUse(pars.Uses(), check.Parser().Uses()); ok && u.Declaration {
+		return fmt.Errorf("cannot rename %s to %s: %s would be declared twice, at %s",
+			target.Name, name, name, position(path, u.Line, u.Column))
+	} else if ok {
+		return fmt.Errorf("cannot rename %s to %s: the name at %s would no longer refer to the same symbol",
+			target.Name, name, position(path, u.Line, u.Column))
+	}
+	if !sameDiagnostics(doc, check) {
+		return fmt.Errorf("cannot rename %s to %s: the renamed program has other diagnostics", target.Name, name)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if bom {
+		renamed = "\uFEFF" + renamed
+	}
+	if err := os.WriteFile(path, []byte(renamed), info.Mode().Perm()); err != nil {
+		return err
+	}
+	kind, scope := pars.KindOf(target)
+	fmt.Printf("renamed %s %s in %s to %s in %d places\n", kind, target.Name, scope, name, len(refs))
+	return nil
+}
+
+// renameTarget returns the declaration of the symbol named old, which must
+// be the only one by that name, or of the one whose name is at line:column
+func renameTarget(pars *parser.Parser, path, old string) (parser.Use, error) {
+	if _, line, column, err := parsePosition(path + ":" + old); err == nil {
+		u, ok := pars.UseAt("", line, column)
+		if !ok {
+			return parser.Use{}, fmt.Errorf("no declared name at %s", position(path, line, column))
+		}
+		return pars.Definition(u), nil
+	}
+
+	var found []parser.Use
+	for _, u := range pars.Uses() {
+		if u.Name == old && u.Declaration && !slices.ContainsFunc(found, func(d parser.Use) bool {
+			return slices.Contains(pars.References(d), u)
+		}) {
+			found = append(found, u)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return parser.Use{}, fmt.Errorf("%s is not declared", old)
+	case 1:
+		return found[0], nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s names several symbols; give the line:column of the one to rename:", old)
+	for _, d := range found {
+		kind, scope := pars.KindOf(d)
+		fmt.Fprintf(&sb, "\n  %s %s in %s at %s", kind, d.Name, scope, position(d.File, d.Line, d.Column))
+	}
+	return parser.Use{}, fmt.Errorf("%s", sb.String())
+}
+
+// checkName returns an error if name would not be scanned as an identifier
+func checkName(name string) error {
+	runes := []rune(name)
+	switch {
+	case len(runes) == 0 || !unicode.IsLetter(runes[0]) ||
+		slices.ContainsFunc(runes, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }):
+		return fmt.Errorf("%q is not an identifier", name)
+	case lexer.IsKeyword(name):
+		return fmt.Errorf("%s is a keyword", name)
+	case len(runes) > config.MaxIdentLength:
+		return fmt.Errorf("%s is longer than %d characters", name, config.MaxIdentLength)
+	}
+	return nil
+}
+
+// replaceNames replaces the identifier at each use with its new name in
+// names, keeping the rest of the source, line breaks included, as it is
+func replaceNames(source string, names map[parser.Use]string) string {
+	starts := sourcefile.LineStarts(source)
+
+	// Replacing from the end leaves the offsets of the earlier names valid
+	uses := slices.Collect(maps.Keys(names))
+	slices.SortFunc(uses, func(a, b parser.Use) int {
+		if a.Line != b.Line {
+			return b.Line - a.Line
+		}
+		return b.Column - a.Column
+	})
+	for _, u := range uses {
+		start := starts[u.Line-1] + sourcefile.ColumnOffset(source[starts[u.Line-1]:], u.Column)
+		end := start
+		for end < len(source) {
+			r, size := utf8.DecodeRuneInString(source[end:])
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			end += size
+		}
+		source = source[:start] + names[u] + source[end:]
+	}
+	return source
+}
+
+// changedUse returns the first name that resolves differently in after than
+// in before, which are the uses of the same program before and after a
+// rename
+func changedUse(before, after []parser.Use) (parser.Use, bool) {
+	for i, u := range before {
+		if i >= len(after) || after[i].Token != u.Token || after[i].Variable != u.Variable ||
+			after[i].Procedure != u.Procedure || after[i].Declaration != u.Declaration {
+			return u, true
+		}
+	}
+	if len(after) > len(before) {
+		return after[len(before)], true
+	}
+	return parser.Use{}, false
+}
+
+// sameDiagnostics reports whether two documents have diagnostics with the
+// same codes on the same lines
+func sameDiagnostics(a, b *parser.Document) bool {
+	return slices.EqualFunc(a.Diagnostics(), b.Diagnostics(), func(x, y diag.Diagnostic) bool {
+		return x.Code == y.Code && x.Pos.Line == y.Pos.Line
+	})
+}
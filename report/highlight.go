@@ -0,0 +1,213 @@
+package report
+
+import (
+	"html"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+
+	"compiler/config"
+	"compiler/lexer"
+	"compiler/parser"
+)
+
+// Class is the kind of a token for highlighting, which doubles as its CSS
+// class in the HTML pages
+type Class string
+
+const (
+	Keyword   Class = "kw"
+	Number    Class = "num"
+	String    Class = "str"
+	Operator  Class = "op"
+	Directive Class = "dir"   // an include directive
+	Name      Class = "id"    // a name that was not resolved
+	Variable  Class = "var"   // a scalar variable
+	Array     Class = "arr"   // an array variable
+	Parameter Class = "param" // a parameter or its declaration in the body
+	Procedure Class = "proc"  // a procedure without a result
+	Function  Class = "func"
+)
+
+// Span is a classified token of the source, as an editor colors it. Columns
+// and lengths are counted in characters.
+type Span struct {
+	Line   int
+	Column int
+	Length int
+	Class  Class
+}
+
+// Classify splits the source into classified tokens. Names are classified by
+// the symbols uses resolves them to, so that a variable, a parameter and a
+// function look different; names in included files are not resolved.
+func Classify(source string, uses []parser.Use, variables []parser.Variable, procedures []parser.Procedure) []Span {
+	type position struct{ line, column int }
+	resolved := make(map[position]parser.Use)
+	for _, u := range uses {
+		if u.File == "" && u.Column > 0 {
+			resolved[position{u.Line, u.Column}] = u
+		}
+	}
+
+	var spans []Span
+	for i, text := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+		line := scanLine(text, i+1)
+		columns := displayColumns(text)
+		for j, span := range line {
+			if u, ok := resolved[position{span.Line, columns[span.Column-1]}]; ok && span.Class == Name {
+				line[j].Class = symbolClass(u, variables, procedures)
+			}
+		}
+		spans = append(spans, line...)
+	}
+	return spans
+}
+
+// symbolClass returns the class of the symbol u stands for
+func symbolClass(u parser.Use, variables []parser.Variable, procedures []parser.Procedure) Class {
+	if u.Procedure >= 0 {
+		if procedures[u.Procedure].Type == "void" {
+			return Procedure
+		}
+		return Function
+	}
+	v := variables[u.Variable]
+	switch {
+	case v.Kind != 0 || parser.IsParameterDeclaration(variables, v):
+		return Parameter
+	case v.Size > 0:
+		return Array
+	}
+	return Variable
+}
+
+// displayColumns returns the display column of each character of a line,
+// which moves to the next tab stop after a tab as in diagnostics
+func displayColumns(text string) []int {
+	var columns []int
+	column := 1
+	for _, r := range text {
+		columns = append(columns, column)
+		if r == '\t' {
+			column = (column-1)/config.TabWidth*config.TabWidth + config.TabWidth + 1
+		} else {
+			column++
+		}
+	}
+	return columns
+}
+
+// operators lists the symbols that are two characters long
+var operators = []string{":=", "<=", ">=", "<>"}
+
+// scanLine splits a source line into the words, numbers and symbols the
+// lexer would scan, classifying names as unresolved
+func scanLine(text string, line int) []Span {
+	var spans []Span
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		var class Class
+		switch r := runes[i]; {
+		case unicode.IsLetter(r):
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			class = Name
+			if lexer.IsKeyword(string(runes[i:j])) {
+				class = Keyword
+			}
+		case unicode.IsDigit(r):
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			class = Number
+		case r == '\'':
+			// A doubled quote splits the literal into adjacent spans that look the same
+			for j < len(runes) && runes[j] != '\'' {
+				j++
+			}
+			j = min(j+1, len(runes))
+			class = String
+		case r == '{' && j < len(runes) && runes[j] == '$':
+			for j < len(runes) && runes[j] != '}' {
+				j++
+			}
+			j = min(j+1, len(runes))
+			class = Directive
+		case !unicode.IsSpace(r):
+			if j < len(runes) && slices.Contains(operators, string(runes[i:j+1])) {
+				j++
+			}
+			class = Operator
+		}
+
+		if class != "" {
+			spans = append(spans, Span{Line: line, Column: i + 1, Length: j - i, Class: class})
+		}
+		i = j
+	}
+	return spans
+}
+
+// render wraps the spans of a source line in HTML elements of their class
+func render(text string, spans []Span) template.HTML {
+	var sb strings.Builder
+	runes := []rune(text)
+	i := 0
+	for _, span := range spans {
+		start, end := span.Column-1, span.Column-1+span.Length
+		sb.WriteString(html.EscapeString(string(runes[i:start])))
+		sb.WriteString(`<span class="` + string(span.Class) + `">` + html.EscapeString(string(runes[start:end])) + `</span>`)
+		i = end
+	}
+	sb.WriteString(html.EscapeString(string(runes[i:])))
+	return template.HTML(sb.String())
+}
+
+// WriteHighlight renders the source as a self-contained HTML page colored by
+// the classified spans
+func WriteHighlight(w io.Writer, path, source string, spans []Span) error {
+	byLine := make(map[int][]Span)
+	for _, span := range spans {
+		byLine[span.Line] = append(byLine[span.Line], span)
+	}
+	var lines []sourceLine
+	for i, text := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+		lines = append(lines, sourceLine{Number: i + 1, Code: render(text, byLine[i+1])})
+	}
+	return highlightPage.Execute(w, map[string]any{"Path": path, "Lines": lines})
+}
+
+var highlightPage = template.Must(template.New("highlight").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Path}}</title>
+<style>
+body { margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+td { font-family: monospace; white-space: pre; padding: 0 10px; }
+.ln a { color: #999; text-decoration: none; }
+tr:target { background: #fff8c5; }
+.kw { color: #8250df; font-weight: bold; }
+.num { color: #0550ae; }
+.str { color: #0a3069; }
+.op { color: #555; }
+.dir { color: #6e7781; font-style: italic; }
+.var { color: #953800; }
+.arr { color: #953800; text-decoration: underline dotted; }
+.param { color: #116329; }
+.proc, .func { color: #0969da; font-weight: bold; }
+</style>
+</head>
+<body>
+<table>
+{{range .Lines}}<tr id="L{{.Number}}"><td class="ln"><a href="#L{{.Number}}">{{.Number}}</a></td><td>{{.Code}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"strings"
+
+	"compiler/diag"
+	"compiler/parser"
+)
+
+// Data holds everything shown in a compilation report
+type Data struct {
+	SourcePath  string
+	Source      string
+	Diagnostics []diag.Diagnostic
+	Variables   []parser.Variable
+	Procedures  []parser.Procedure
+}
+
+type sourceLine struct {
+	Number      int
+	Code        template.HTML
+	Diagnostics []diag.Diagnostic
+}
+
+// WriteHTML renders data as a single self-contained HTML page
+func WriteHTML(w io.Writer, data Data) error {
+	byLine := make(map[int][]diag.Diagnostic)
+	for _, d := range data.Diagnostics {
+		if d.Pos.File == "" { // included files are not shown
+			byLine[d.Pos.Line] = append(byLine[d.Pos.Line], d)
+		}
+	}
+
+	var lines []sourceLine
+	for i, text := range strings.Split(strings.TrimSuffix(data.Source, "\n"), "\n") {
+		lines = append(lines, sourceLine{Number: i + 1, Code: render(text, scanLine(text, i+1)), Diagnostics: byLine[i+1]})
+	}
+
+	errors := 0
+	for _, d := range data.Diagnostics {
+		if d.Severity == diag.Error {
+			errors++
+		}
+	}
+
+	return page.Execute(w, map[string]any{
+		"Path":        data.SourcePath,
+		"Success":     errors == 0,
+		"Lines":       lines,
+		"Diagnostics": data.Diagnostics,
+		"Variables":   data.Variables,
+		"Procedures":  data.Procedures,
+	})
+}
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"severity": func(s diag.Severity) string { return s.String() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Compilation report: {{.Path}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+.status.ok { color: #1a7f37; }
+.status.failed { color: #cf222e; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 2px 10px; text-align: left; }
+.tables th { border-bottom: 1px solid #999; }
+.source td { font-family: monospace; white-space: pre; vertical-align: top; }
+.source .ln a { color: #999; text-decoration: none; }
+.source tr:target { background: #fff8c5; }
+.msg td { font-family: sans-serif; white-space: normal; padding-left: 3em; }
+.error { color: #cf222e; }
+.warning { color: #9a6700; }
+.note { color: #0969da; }
+.kw { color: #8250df; font-weight: bold; }
+.num { color: #0550ae; }
+.str { color: #0a3069; }
+.op { color: #555; }
+.dir { color: #6e7781; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Path}}</h1>
+{{if .Success}}<p class="status ok">Compilation successful.</p>{{else}}<p class="status failed">Compilation failed.</p>{{end}}
+
+<h2>Diagnostics</h2>
+{{if .Diagnostics}}<ul>
+{{range .Diagnostics}}<li class="{{severity .Severity}}">{{if .Pos.File}}{{.Pos.File}} line {{.Pos.Line}}{{else}}<a href="#L{{.Pos.Line}}">Line {{.Pos.Line}}</a>{{end}}: {{if .Code}}[{{.Code}}] {{end}}{{.Msg}}{{if .Fatal}} [FATAL]{{end}}</li>
+{{end}}</ul>{{else}}<p>None.</p>{{end}}
+
+<h2>Source</h2>
+<table class="source">
+{{range .Lines}}<tr id="L{{.Number}}"><td class="ln"><a href="#L{{.Number}}">{{.Number}}</a></td><td>{{.Code}}</td></tr>
+{{range .Diagnostics}}<tr class="msg"><td></td><td class="{{severity .Severity}}">{{if .Code}}[{{.Code}}] {{end}}{{.Msg}}{{range .Notes}}<br><span class="note">{{.}}</span>{{end}}</td></tr>
+{{end}}{{end}}</table>
+
+<div class="tables">
+<h2>Variables</h2>
+<table>
+<tr><th>Name</th><th>Procedure</th><th>Kind</th><th>Type</th><th>Level</th><th>Address</th><th>Declared</th><th>References</th></tr>
+{{range .Variables}}<tr><td>{{.Name}}</td><td>{{.Procedure}}</td><td>{{.Kind}}</td><td>{{.Type}}{{if .Size}}[{{.Size}}]{{end}}</td><td>{{.Level}}</td><td>{{.Address}}</td><td><a href="#L{{.Line}}">{{.Line}}</a></td><td>{{range .References}}<a href="#L{{.}}">{{.}}</a> {{end}}</td></tr>
+{{end}}</table>
+
+<h2>Procedures</h2>
+<table>
+<tr><th>Name</th><th>Type</th><th>Level</th><th>First variable</th><th>Last variable</th><th>Declared</th><th>Calls</th></tr>
+{{range .Procedures}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Level}}</td><td>{{.FirstVariableAddress}}</td><td>{{.LastVariableAddress}}</td><td><a href="#L{{.Line}}">{{.Line}}</a></td><td>{{range .References}}<a href="#L{{.}}">{{.}}</a> {{end}}</td></tr>
+{{end}}</table>
+</div>
+</body>
+</html>
+`))
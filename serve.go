@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"compiler/ast"
+	"compiler/config"
+	"compiler/diag"
+	"compiler/emit"
+	"compiler/lexer"
+	"compiler/parser"
+	"compiler/token"
+)
+
+// serve answers POST requests whose body is the text of a program with its
+// tokens, tree or symbols as JSON, for a web playground. Each request has a
+// size and a time limit, and may not include files of the server.
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	timeout := flags.Duration("timeout", 5*time.Second, "time allowed for a request")
+	maxSize := flags.Int64("max-size", 1<<20, "largest program accepted, in bytes")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: serve [--addr host:port] [--timeout d] [--max-size n]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return EXIT_IO
+	}
+	config.NoIncludes = true
+
+	mux := http.NewServeMux()
+	for path, handle := range map[string]func(context.Context, string) any{
+		"/lex":     serveLex,
+		"/parse":   serveParse,
+		"/compile": serveCompile,
+	} {
+		mux.Handle("POST "+path, sourceHandler(handle, *maxSize))
+	}
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           http.TimeoutHandler(mux, *timeout, `{"error":"the request took too long"}`),
+		ReadHeaderTimeout: *timeout,
+	}
+	slog.Info("serving", "addr", *addr)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	return EXIT_SUCCESS
+}
+
+// sourceHandler reads the program a request sends and answers it with the
+// result of handle, encoded as JSON
+func sourceHandler(handle func(ctx context.Context, source string) any, maxSize int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeResponse(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeResponse(w, http.StatusOK, handle(r.Context(), string(data)))
+		slog.Info("request served", "path", r.URL.Path, "bytes", len(data), "elapsed", time.Since(started))
+	})
+}
+
+func writeResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("response not written", "error", err)
+	}
+}
+
+// jsonToken is a token of the /lex response, with the line the token file
+// marks by line breaks
+type jsonToken struct {
+	Type   token.TokenType `json:"type"`
+	Name   string          `json:"name"`
+	Value  string          `json:"value"`
+	Line   int             `json:"line"`
+	Column int             `json:"column"`
+}
+
+// serveLex answers /lex with the tokens of the program and the lexical
+// diagnostics
+func serveLex(ctx context.Context, source string) any {
+	reporter := diag.NewReporter(config.MaxErrors)
+	l := lexer.NewFromReaderAt(strings.NewReader(source), config.SOURCE_PATH, 1, reporter)
+	tokens := []jsonToken{}
+	line := 1
+	for tok := range l.TokensContext(ctx) {
+		switch tok.Type {
+		case token.END_OF_LINE:
+			line++
+		case token.END_OF_FILE:
+		default:
+			tokens = append(tokens, jsonToken{tok.Type, tok.Type.String(), tok.Value, line, tok.Column})
+		}
+	}
+	diagnostics, _ := reporter.Diagnostics()
+	return struct {
+		Tokens      []jsonToken     `json:"tokens"`
+		Diagnostics json.RawMessage `json:"diagnostics"`
+	}{tokens, encodeWith(diag.JSONSink{}.Write, diagnostics)}
+}
+
+// serveParse answers /parse with the abstract syntax tree of the program,
+// null if it has syntax errors, and all its diagnostics with the exit
+// status a compilation would end with. It gives up with an error once the
+// request is canceled.
+func serveParse(ctx context.Context, source string) any {
+	doc, err := parser.NewDocumentContext(ctx, source)
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	diagnostics := doc.Diagnostics()
+	tree := json.RawMessage("null")
+	if doc.AST() != nil {
+		tree = encodeWith(ast.WriteJSON, doc.AST())
+	}
+	return struct {
+		Status      int             `json:"status"`
+		Diagnostics json.RawMessage `json:"diagnostics"`
+		AST         json.RawMessage `json:"ast"`
+	}{exitStatus(diagnostics, false), encodeWith(diag.JSONSink{}.Write, diagnostics), tree}
+}
+
+// serveCompile answers /compile as /parse does, with the symbol tables in
+// place of the tree, and the Go translation of a program without errors
+func serveCompile(ctx context.Context, source string) any {
+	doc, err := parser.NewDocumentContext(ctx, source)
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	diagnostics := doc.Diagnostics()
+	status := exitStatus(diagnostics, false)
+	var variables, procedures bytes.Buffer
+	if err := (emit.JSON{}).EmitSymbols(&variables, &procedures, doc.Parser().Variables(), doc.Parser().Procedures()); err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	var goSource bytes.Buffer
+	if status == EXIT_SUCCESS {
+		if err := emit.GoSource(&goSource, doc.AST()); err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+	}
+	return struct {
+		Status      int             `json:"status"`
+		Diagnostics json.RawMessage `json:"diagnostics"`
+		Variables   json.RawMessage `json:"variables"`
+		Procedures  json.RawMessage `json:"procedures"`
+		Go          string          `json:"go,omitempty"`
+	}{status, encodeWith(diag.JSONSink{}.Write, diagnostics), variables.Bytes(), procedures.Bytes(), goSource.String()}
+}
+
+// encodeWith returns what write writes of v, which is JSON
+func encodeWith[T any](write func(io.Writer, T) error, v T) json.RawMessage {
+	var buf bytes.Buffer
+	if err := write(&buf, v); err != nil {
+		return json.RawMessage("null")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"compiler/ast"
+	"compiler/config"
+	"compiler/diag"
+	"compiler/lexer"
+	"compiler/token"
+	"compiler/winnow"
+)
+
+// submission holds the fingerprints of a program of the set compared
+type submission struct {
+	name   string
+	tokens map[uint64]bool
+	tree   map[uint64]bool // nil if the program has syntax errors
+}
+
+// similarity scores every pair of programs under a directory by how much of
+// their token streams and of their syntax trees they share, with names and
+// values of constants and strings left out of both so that renaming or
+// changing them does not hide copying, and prints the pairs as CSV from the
+// most similar down
+func similarity(args []string) int {
+	flags := flag.NewFlagSet("similarity", flag.ContinueOnError)
+	k := flags.Int("k", 5, "length of the runs of tokens or nodes hashed")
+	window := flags.Int("window", 4, "number of consecutive hashes of which the smallest is kept")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: similarity [--k n] [--window n] dir")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || *k < 1 || *window < 1 {
+		if err == nil {
+			flags.Usage()
+		}
+		return EXIT_IO
+	}
+	dir := flags.Arg(0)
+
+	var submissions []submission
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".pas" {
+			return err
+		}
+		s, err := fingerprint(path, *k, *window)
+		if err != nil {
+			return err
+		}
+		s.name, _ = filepath.Rel(dir, path)
+		submissions = append(submissions, s)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+
+	type pair struct {
+		a, b         string
+		tokens, tree float64
+		score        float64
+		compared     bool // whether both trees were
+	}
+	var pairs []pair
+	for i, a := range submissions {
+		for _, b := range submissions[i+1:] {
+			p := pair{a: a.name, b: b.name, tokens: winnow.Similarity(a.tokens, b.tokens)}
+			p.score = p.tokens
+			if a.tree != nil && b.tree != nil {
+				p.tree = winnow.Similarity(a.tree, b.tree)
+				p.score, p.compared = (p.tokens+p.tree)/2, true
+			}
+			pairs = append(pairs, p)
+		}
+	}
+	slices.SortStableFunc(pairs, func(x, y pair) int {
+		return cmp.Or(cmp.Compare(y.score, x.score), strings.Compare(x.a, y.a), strings.Compare(x.b, y.b))
+	})
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"a", "b", "tokens", "ast", "similarity"})
+	for _, p := range pairs {
+		tree := ""
+		if p.compared {
+			tree = formatScore(p.tree)
+		}
+		w.Write([]string{p.a, p.b, formatScore(p.tokens), tree, formatScore(p.score)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_IO
+	}
+	return EXIT_SUCCESS
+}
+
+// fingerprint winnows the token stream of the program at path, and its
+// syntax tree if it parses. Files it includes count as part of it.
+func fingerprint(path string, k, window int) (submission, error) {
+	doc, err := openDocument(path)
+	if err != nil {
+		return submission{}, err
+	}
+
+	// A program with lexical errors is still compared, by the tokens the
+	// lexer recovered
+	reporter := diag.NewReporter(config.MaxErrors)
+	l := lexer.NewFromReaderAt(strings.NewReader(doc.Text()), path, 1, reporter)
+	var symbols []string
+	for tok := range l.Tokens() {
+		switch tok.Type {
+		case token.END_OF_LINE, token.END_OF_FILE, token.SOURCE_FILE:
+			continue
+		}
+		// The name of the type of a name, constant or string stands for its value
+		symbols = append(symbols, tok.Type.String())
+	}
+	s := submission{tokens: winnow.Fingerprints(symbols, k, window)}
+
+	if tree := doc.AST(); tree != nil {
+		s.tree = winnow.Fingerprints(ast.Labels(tree, false), k, window)
+	}
+	return s, nil
+}
+
+// formatScore writes a similarity with three decimals
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', 3, 64)
+}
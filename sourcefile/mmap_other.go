@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package sourcefile
+
+import (
+	"errors"
+	"os"
+)
+
+// mapFile fails where mapping isn't supported, so that the file is read
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
@@ -0,0 +1,21 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package sourcefile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mapFile maps size bytes of file read-only into memory
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	if size == 0 {
+		return nil, nil, errors.New("cannot map an empty file")
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,110 @@
+// Package sourcefile reads the compiled file. With --mmap the file is mapped
+// into memory, so that very large generated inputs are not also copied onto
+// the heap.
+package sourcefile
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"compiler/config"
+)
+
+// File is the content of a source file, which must be closed when no
+// longer needed
+type File struct {
+	data  []byte
+	unmap func() error // nil if the file was read
+}
+
+// Open returns the content of the file at path, mapping it into memory if
+// --mmap is set and the system allows it, or else reading it
+func Open(path string) (*File, error) {
+	if config.Mmap {
+		if f, err := openMapped(path); err == nil {
+			return f, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{data: data}, nil
+}
+
+func openMapped(path string) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() // the mapping outlives the descriptor
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, unmap, err := mapFile(file, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &File{data: data, unmap: unmap}, nil
+}
+
+// Bytes returns the content, which is read-only if it is mapped
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+// Close releases the content, which must no longer be used
+func (f *File) Close() error {
+	f.data = nil
+	if f.unmap == nil {
+		return nil
+	}
+	unmap := f.unmap
+	f.unmap = nil
+	return unmap()
+}
+
+// Text returns the text of the file at path the way the lexer sees it,
+// without a byte order mark and with LF line breaks
+func Text(path string) (string, error) {
+	file, err := Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	text := strings.TrimPrefix(string(file.Bytes()), "\uFEFF")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+// lineBreak matches the line breaks the lexer accepts
+var lineBreak = regexp.MustCompile(`\r\n|\r|\n`)
+
+// LineStarts returns the byte offset at which each line of text starts
+func LineStarts(text string) []int {
+	starts := []int{0}
+	for _, m := range lineBreak.FindAllStringIndex(text, -1) {
+		starts = append(starts, m[1])
+	}
+	return starts
+}
+
+// ColumnOffset returns the byte offset of a display column in a line, where
+// a tab moves to the next tab stop as in diagnostics
+func ColumnOffset(line string, column int) int {
+	at := 1
+	for i, r := range line {
+		if at >= column {
+			return i
+		}
+		if r == '\t' {
+			at = (at-1)/config.TabWidth*config.TabWidth + config.TabWidth + 1
+		} else {
+			at++
+		}
+	}
+	return len(line)
+}
@@ -0,0 +1,36 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalJSON encodes the token with the same numeric type code used in
+// .dyd files, plus its readable name
+func (t Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  TokenType `json:"type"`
+		Name  string    `json:"name"`
+		Value string    `json:"value"`
+	}{t.Type, t.Type.String(), t.Value})
+}
+
+// UnmarshalJSON decodes a token, rejecting missing fields and unknown type codes
+func (t *Token) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type  *TokenType `json:"type"`
+		Value *string    `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Type == nil || raw.Value == nil {
+		return fmt.Errorf("token %s is missing its type or value", data)
+	}
+	if !raw.Type.IsValid() {
+		return fmt.Errorf("unknown token type %d", int(*raw.Type))
+	}
+	t.Type = *raw.Type
+	t.Value = *raw.Value
+	return nil
+}
@@ -1,38 +1,155 @@
 package token
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:generate stringer -type=TokenType -linecomment
+
 // TokenType represents the type of token
 type TokenType int
 
 const (
-	BEGIN TokenType = iota + 1
-	END
-	INTEGER
-	IF
-	THEN
-	ELSE
-	FUNCTION
-	READ
-	WRITE
-	IDENTIFIER
-	CONSTANT
-	EQUAL
-	NOT_EQUAL
-	LESS_THAN_OR_EQUAL
-	LESS_THAN
-	GREATER_THAN_OR_EQUAL
-	GREATER_THAN
-	SUBTRACT
-	MULTIPLY
-	ASSIGN
-	LEFT_PARENTHESES
-	RIGHT_PARENTHESES
-	SEMICOLON
-	END_OF_LINE
-	END_OF_FILE
+	BEGIN                 TokenType = iota + 1 // begin
+	END                                        // end
+	INTEGER                                    // integer
+	IF                                         // if
+	THEN                                       // then
+	ELSE                                       // else
+	FUNCTION                                   // function
+	READ                                       // read
+	WRITE                                      // write
+	IDENTIFIER                                 // identifier
+	CONSTANT                                   // constant
+	EQUAL                                      // =
+	NOT_EQUAL                                  // <>
+	LESS_THAN_OR_EQUAL                         // <=
+	LESS_THAN                                  // <
+	GREATER_THAN_OR_EQUAL                      // >=
+	GREATER_THAN                               // >
+	SUBTRACT                                   // -
+	MULTIPLY                                   // *
+	ASSIGN                                     // :=
+	LEFT_PARENTHESES                           // (
+	RIGHT_PARENTHESES                          // )
+	SEMICOLON                                  // ;
+	END_OF_LINE                                // EOLN
+	END_OF_FILE                                // EOF
+
+	// Extensions to the course's token table, numbered after it so that
+	// the codes above keep their values
+	MOD           // mod
+	DIV           // div
+	COMMA         // ,
+	WRITELN       // writeln
+	STRING        // string
+	VAR           // var
+	PROCEDURE     // procedure
+	LEFT_BRACKET  // [
+	RIGHT_BRACKET // ]
+	REPEAT        // repeat
+	UNTIL         // until
+	FOR           // for
+	TO            // to
+	DO            // do
+	WHILE         // while
+	CASE          // case
+	OF            // of
+	COLON         // :
+	FORWARD       // forward
+	SOURCE_FILE   // FILE
 )
 
 // Token represents a token with its type and value
 type Token struct {
-	Type  TokenType
-	Value string
+	Type   TokenType
+	Value  string
+	Column int // display column of its first character as in diagnostics, 0 if unknown
+}
+
+// IsValid returns true if the token type is one of the declared constants
+func (t TokenType) IsValid() bool {
+	return t >= BEGIN && int(t-BEGIN) < len(_TokenType_index)-1
+}
+
+// IsKeyword returns true if the token type is a reserved word
+func (t TokenType) IsKeyword() bool {
+	switch t {
+	case BEGIN, END, INTEGER, IF, THEN, ELSE, FUNCTION, READ, WRITE, MOD, DIV, WRITELN, VAR, PROCEDURE,
+		REPEAT, UNTIL, FOR, TO, DO, WHILE, CASE, OF, FORWARD:
+		return true
+	}
+	return false
+}
+
+// IsRelational returns true if the token type compares two expressions
+func (t TokenType) IsRelational() bool {
+	switch t {
+	case EQUAL, NOT_EQUAL, LESS_THAN_OR_EQUAL, LESS_THAN, GREATER_THAN_OR_EQUAL, GREATER_THAN:
+		return true
+	}
+	return false
+}
+
+// IsMultiplicative returns true if the token type combines two factors of a term
+func (t TokenType) IsMultiplicative() bool {
+	switch t {
+	case MULTIPLY, MOD, DIV:
+		return true
+	}
+	return false
+}
+
+// IsOperator returns true if the token type is an arithmetic, relational
+// or assignment operator
+func (t TokenType) IsOperator() bool {
+	switch t {
+	case SUBTRACT, ASSIGN:
+		return true
+	}
+	return t.IsRelational() || t.IsMultiplicative()
+}
+
+// IsLayout returns true if the token type only tracks the position in the
+// source, like the end of a line or the switch to an included file
+func (t TokenType) IsLayout() bool {
+	return t == END_OF_LINE || t == SOURCE_FILE
+}
+
+// IsKeyword returns true if the token is a reserved word
+func (t Token) IsKeyword() bool {
+	return t.Type.IsKeyword()
+}
+
+// IsRelational returns true if the token is a relational operator
+func (t Token) IsRelational() bool {
+	return t.Type.IsRelational()
+}
+
+// IsMultiplicative returns true if the token is a multiplying operator
+func (t Token) IsMultiplicative() bool {
+	return t.Type.IsMultiplicative()
+}
+
+// IsOperator returns true if the token is an operator
+func (t Token) IsOperator() bool {
+	return t.Type.IsOperator()
+}
+
+// SourceMarker returns the SOURCE_FILE token saying that the following
+// tokens start at line of the file at path
+func SourceMarker(path string, line int) Token {
+	return Token{Type: SOURCE_FILE, Value: fmt.Sprintf("%s:%d", path, line)}
+}
+
+// ParseSourceMarker returns the path and line of a SOURCE_FILE token
+func ParseSourceMarker(t Token) (path string, line int, ok bool) {
+	i := strings.LastIndexByte(t.Value, ':')
+	if t.Type != SOURCE_FILE || i < 0 {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(t.Value[i+1:])
+	return t.Value[:i], line, err == nil
 }
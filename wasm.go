@@ -0,0 +1,31 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"syscall/js"
+
+	"compiler/config"
+)
+
+// In a browser, built with GOOS=js GOARCH=wasm, the compiler exports to
+// JavaScript compile(source), which returns the JSON the /compile request of
+// the serve command answers with. A browser has no files to include.
+func init() {
+	playground = func() {
+		config.NoIncludes = true
+		js.Global().Set("compile", js.FuncOf(func(this js.Value, args []js.Value) any {
+			if len(args) != 1 || args[0].Type() != js.TypeString {
+				return `{"error":"compile takes the source of a program"}`
+			}
+			data, err := json.Marshal(serveCompile(context.Background(), args[0].String()))
+			if err != nil {
+				data, _ = json.Marshal(map[string]string{"error": err.Error()})
+			}
+			return string(data)
+		}))
+		select {} // the page calls compile until it is closed
+	}
+}
@@ -0,0 +1,65 @@
+// Package winnow selects fingerprints of sequences by winnowing (Schleimer,
+// Wilkerson and Aiken, SIGMOD 2003), so that sequences sharing long enough
+// runs share fingerprints wherever the runs are
+package winnow
+
+import "hash/fnv"
+
+// Fingerprints hashes every run of k symbols of a sequence and keeps the
+// smallest hash of each window of w consecutive ones. Any run of at least w+k-1 symbols two sequences share
+// then yields a fingerprint of both. A sequence shorter than k is hashed
+// whole.
+func Fingerprints(symbols []string, k, w int) map[uint64]bool {
+	hashes := grams(symbols, k)
+	prints := make(map[uint64]bool)
+	if len(hashes) <= w {
+		if len(hashes) > 0 {
+			prints[minimum(hashes)] = true
+		}
+		return prints
+	}
+	for i := 0; i+w <= len(hashes); i++ {
+		prints[minimum(hashes[i:i+w])] = true
+	}
+	return prints
+}
+
+// grams returns the hash of every run of k symbols
+func grams(symbols []string, k int) []uint64 {
+	k = max(1, min(k, len(symbols)))
+	var hashes []uint64
+	for i := 0; i+k <= len(symbols); i++ {
+		h := fnv.New64a()
+		for _, s := range symbols[i : i+k] {
+			h.Write([]byte(s))
+			h.Write([]byte{0})
+		}
+		hashes = append(hashes, h.Sum64())
+	}
+	return hashes
+}
+
+// minimum returns the smallest hash
+func minimum(hashes []uint64) uint64 {
+	m := hashes[0]
+	for _, h := range hashes[1:] {
+		m = min(m, h)
+	}
+	return m
+}
+
+// Similarity returns the Jaccard index of two sets of fingerprints: the
+// share of the fingerprints of either that both have, 0 if both are empty
+func Similarity(a, b map[uint64]bool) float64 {
+	shared := 0
+	for h := range a {
+		if b[h] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}